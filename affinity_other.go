@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func setCPUAffinity(cpus []int) error {
+	return errors.New(msg("affinity_unsupported"))
+}
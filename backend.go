@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	backendNames   = flag.String("backend", "ollama", "要测试的后端, 多个用逗号分隔 (ollama,ollama-chat,vllm), 多个后端时输出对比表")
+	ollamaEndpoint = flag.String("ollama-endpoint", apiEndpoint, "ollama 原生接口地址")
+	numPredict     = flag.Int("num-predict", 0, "每个请求最多生成的 token 数 (ollama 的 num_predict / OpenAI 的 max_tokens), 0 表示不限制")
+	httpMethod     = flag.String("method", http.MethodPost, "发送请求使用的 HTTP 方法")
+	requestPath    = flag.String("path", "", "覆盖后端接口地址中的路径 (可带查询参数), 如 /v2/infer; 为空时使用后端默认路径")
+	contentType    = flag.String("content-type", "application/json", "请求体的 Content-Type, 配合 -body-template 发送表单或纯文本")
+	bodyTemplate   = flag.String("body-template", "", "原样发送的请求体模板, 替代后端构造的 JSON; 可用 {{model}} {{prompt}} {{prompt_json}} (JSON 字符串转义, 不含引号) {{prompt_url}} (URL 编码)")
+	responseFormat = flag.String("response-format", "json", "响应体格式: json 由后端解析; text 将整个响应体作为生成的文本 (不统计 token)")
+	vllmEndpoint   = flag.String("vllm-endpoint", "http://localhost:8000/v1/completions", "vLLM (OpenAI 兼容) 接口地址, 需用 --served-model-name 暴露与 ollama 相同的模型名")
+)
+
+// Backend 描述一种推理服务的接口地址、请求体构造方式和响应解析方式
+type Backend interface {
+	Name() string
+	Endpoint() string
+	RequestBody(model, prompt string) map[string]interface{}
+	// ResponseText 从解码后的响应中取出生成的文本, 没有时返回 nil
+	ResponseText(response map[string]interface{}) interface{}
+	// ErrorMessage 从非 200 响应体中取出服务端给出的错误信息, 无法解析时返回空字符串
+	ErrorMessage(body []byte) string
+	// CompletionTokens 返回服务端报告的生成 token 数, 响应中没有时返回 false
+	CompletionTokens(response map[string]interface{}) (int, bool)
+}
+
+// batchBackend 由支持在一个请求中发送多条提示词的后端实现
+type batchBackend interface {
+	BatchRequestBody(model string, prompts []string) map[string]interface{}
+}
+
+// promptTokenCounter 由能在响应中报告输入 token 数的后端实现
+type promptTokenCounter interface {
+	PromptTokens(response map[string]interface{}) (int, bool)
+}
+
+// modelUnloader 由支持主动卸载模型的后端实现, 用于测量冷启动耗时
+type modelUnloader interface {
+	Unload(client *http.Client, model string) error
+}
+
+// ollamaBackend 对应 ollama 的 /api/generate 接口
+type ollamaBackend struct {
+	endpoint string
+}
+
+func (b ollamaBackend) Name() string     { return "ollama" }
+func (b ollamaBackend) Endpoint() string { return b.endpoint }
+
+func (b ollamaBackend) RequestBody(model, prompt string) map[string]interface{} {
+	body := map[string]interface{}{
+		"model":  model,
+		"prompt": prompt,
+		"stream": false,
+	}
+	if options := ollamaOptions(); options != nil {
+		body["options"] = options
+	}
+	return body
+}
+
+func (b ollamaBackend) ResponseText(response map[string]interface{}) interface{} {
+	return response["response"]
+}
+
+func (b ollamaBackend) CompletionTokens(response map[string]interface{}) (int, bool) {
+	n, ok := response["eval_count"].(float64)
+	return int(n), ok
+}
+
+func (b ollamaBackend) PromptTokens(response map[string]interface{}) (int, bool) {
+	n, ok := response["prompt_eval_count"].(float64)
+	return int(n), ok
+}
+
+// Unload 通过 keep_alive: 0 让 ollama 立即从显存中卸载模型
+func (b ollamaBackend) Unload(client *http.Client, model string) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"model":      model,
+		"keep_alive": 0,
+	})
+
+	resp, err := client.Post(b.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf(msg("status_error"), resp.StatusCode)
+	}
+	return nil
+}
+
+// ErrorMessage 解析 ollama 的 {"error": "..."} 错误体
+func (b ollamaBackend) ErrorMessage(body []byte) string {
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &e) != nil {
+		return ""
+	}
+	return e.Error
+}
+
+// openAIBackend 对应 OpenAI 兼容的 /v1/completions 接口 (vLLM 等)
+type openAIBackend struct {
+	name     string
+	endpoint string
+}
+
+func (b openAIBackend) Name() string     { return b.name }
+func (b openAIBackend) Endpoint() string { return b.endpoint }
+
+func (b openAIBackend) RequestBody(model, prompt string) map[string]interface{} {
+	body := map[string]interface{}{
+		"model":  model,
+		"prompt": prompt,
+		"stream": false,
+	}
+	if *numPredict > 0 {
+		body["max_tokens"] = *numPredict
+	}
+	return body
+}
+
+// BatchRequestBody 利用 /v1/completions 的 prompt 数组一次提交多条提示词
+func (b openAIBackend) BatchRequestBody(model string, prompts []string) map[string]interface{} {
+	body := b.RequestBody(model, "")
+	body["prompt"] = prompts
+	return body
+}
+
+func (b openAIBackend) ResponseText(response map[string]interface{}) interface{} {
+	choices, ok := response["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return nil
+	}
+	choice, ok := choices[0].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return choice["text"]
+}
+
+// CompletionTokens 读取 usage.completion_tokens, 批量请求时为所有提示词的合计
+func (b openAIBackend) CompletionTokens(response map[string]interface{}) (int, bool) {
+	usage, ok := response["usage"].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	n, ok := usage["completion_tokens"].(float64)
+	return int(n), ok
+}
+
+// ErrorMessage 解析 OpenAI 的 {"error": {"message": "..."}} 以及 vLLM 的 {"message": "..."} 错误体
+func (b openAIBackend) ErrorMessage(body []byte) string {
+	var e struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) != nil {
+		return ""
+	}
+	if e.Error.Message != "" {
+		return e.Error.Message
+	}
+	return e.Message
+}
+
+// generatedTextKeys 为各后端响应中存放生成内容的字段, 保留响应元数据时去掉
+// (ollama 的 context 是上下文 token 数组, 同样很大且没有分析价值)
+var generatedTextKeys = []string{"response", "thinking", "context", "message", "text"}
+
+// stripGeneratedText 返回去掉生成内容后的响应副本, OpenAI 兼容接口的 choices 中每项也会去掉文本
+func stripGeneratedText(response map[string]interface{}) map[string]interface{} {
+	meta := make(map[string]interface{}, len(response))
+	for k, v := range response {
+		meta[k] = v
+	}
+	for _, k := range generatedTextKeys {
+		delete(meta, k)
+	}
+
+	if choices, ok := meta["choices"].([]interface{}); ok {
+		stripped := make([]interface{}, len(choices))
+		for i, c := range choices {
+			if choice, ok := c.(map[string]interface{}); ok {
+				c = stripGeneratedText(choice)
+			}
+			stripped[i] = c
+		}
+		meta["choices"] = stripped
+	}
+	return meta
+}
+
+// validateRequestTarget 检查 -method 和 -path 参数
+func validateRequestTarget(method, path string) error {
+	if method == "" || strings.ToUpper(method) != method || strings.ContainsAny(method, " \t/") {
+		return fmt.Errorf(msg("bad_method"), method)
+	}
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return fmt.Errorf(msg("bad_path"), path)
+	}
+	return nil
+}
+
+// validateEndpoints 检查所用后端的接口地址是否为完整的 http(s) 地址
+func validateEndpoints(backends []Backend) error {
+	var errs []error
+	for _, b := range backends {
+		u, err := url.Parse(b.Endpoint())
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf(msg("bad_endpoint"), b.Name(), b.Endpoint()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateRawBody 检查 -body-template 和 -response-format
+func validateRawBody() error {
+	if *responseFormat != "json" && *responseFormat != "text" {
+		return fmt.Errorf(msg("unknown_response_format"), *responseFormat)
+	}
+	if *bodyTemplate != "" && *batchSize > 1 {
+		return errors.New(msg("template_batch"))
+	}
+	return nil
+}
+
+// renderBodyTemplate 用模型和提示词填充 -body-template
+func renderBodyTemplate(model, prompt string) []byte {
+	escaped, _ := json.Marshal(prompt)
+	return []byte(strings.NewReplacer(
+		"{{model}}", model,
+		"{{prompt}}", prompt,
+		"{{prompt_json}}", string(escaped[1:len(escaped)-1]),
+		"{{prompt_url}}", url.QueryEscape(prompt),
+	).Replace(*bodyTemplate))
+}
+
+// encodeRequestBody 返回实际发送的请求体: 指定了 -body-template 时按模板生成, 否则为后端构造的 JSON;
+// image 不为 nil 时附带该图片
+func encodeRequestBody(backend Backend, model string, batch []string, image *requestImage) []byte {
+	if *bodyTemplate != "" {
+		return renderBodyTemplate(model, batch[0])
+	}
+	body := buildRequestBody(backend, model, batch)
+	if image != nil {
+		backend.(imageBackend).AttachImages(body, []string{image.base64})
+	}
+	if *streamMode {
+		enableStreaming(backend, body)
+	}
+	data, _ := json.Marshal(body)
+	return data
+}
+
+// requestURL 返回实际请求的地址: 指定了 -path 时保留接口地址的协议和主机, 替换路径和查询参数
+func requestURL(endpoint string) string {
+	if *requestPath == "" {
+		return endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	ref, err := url.Parse(*requestPath)
+	if err != nil {
+		return endpoint
+	}
+	u.Path, u.RawPath, u.RawQuery = ref.Path, ref.RawPath, ref.RawQuery
+	return u.String()
+}
+
+// buildRequestBody 根据提示词数量构造单条或批量请求体, 调用方需保证批量时后端实现了 batchBackend
+func buildRequestBody(backend Backend, model string, batch []string) map[string]interface{} {
+	if len(batch) == 1 {
+		return backend.RequestBody(model, batch[0])
+	}
+	return backend.(batchBackend).BatchRequestBody(model, batch)
+}
+
+// parseBackends 解析 -backend 参数, 保持用户给定的顺序, 第一个后端作为对比基准
+func parseBackends(spec string) ([]Backend, error) {
+	var backends []Backend
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case "ollama":
+			backends = append(backends, ollamaBackend{endpoint: *ollamaEndpoint})
+		case "ollama-chat":
+			backends = append(backends, ollamaChatBackend{ollamaBackend: ollamaBackend{endpoint: *ollamaChatEndpoint}, system: *systemPrompt})
+		case "vllm":
+			backends = append(backends, openAIBackend{name: "vllm", endpoint: *vllmEndpoint})
+		default:
+			return nil, fmt.Errorf(msg("unknown_backend"), name)
+		}
+	}
+
+	if len(backends) == 0 {
+		return nil, errors.New(msg("no_backend"))
+	}
+	return backends, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+var (
+	backgroundConcurrency = flag.Int("background-concurrency", 0, "每组测试同时运行的后台批量请求数, 用于测量混合负载下前台请求的延迟; 0 表示不启用")
+	backgroundPrompt      = flag.String("background-prompt", "写一篇关于人工智能发展历史的长文, 不少于 2000 字", "后台批量请求使用的提示词 (通常是长生成)")
+)
+
+// backgroundStream 为 true 时 runTestFor 会同时运行后台批量请求
+var backgroundStream bool
+
+// startBackground 启动 -background-concurrency 个后台请求循环, 统计单独记录在 stats 中;
+// 后台请求使用 ctx, 该组测试结束时进行中的长生成会被取消, 不会拖到下一组
+func startBackground(ctx context.Context, wg *sync.WaitGroup, mu *sync.Mutex, stats *cellStats, client *http.Client, backend Backend, model string, base int) {
+	for j := 0; j < *backgroundConcurrency; j++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				outcome, err := sendRequest(ctx, idx, client, backend, model, []string{*backgroundPrompt})
+				if ctx.Err() != nil {
+					// 被提前结束的后台请求不计入统计
+					return
+				}
+				mu.Lock()
+				stats.record(outcome, err)
+				mu.Unlock()
+			}
+		}(base + j)
+	}
+}
+
+// runWithBackground 先单独测试前台请求作为基线, 再在后台批量请求下测试一次, 返回后者并附上基线和延迟增幅
+func runWithBackground(backend Backend, model string, concurrency int) TestResult {
+	baseline := runTest(backend, model, concurrency)
+	coolDown()
+
+	fmt.Printf(msg("background_testing"), backend.Name(), model, concurrency, *backgroundConcurrency)
+	backgroundStream = true
+	result := runTest(backend, model, concurrency)
+	backgroundStream = false
+
+	result.ForegroundBaselineMs = baseline.AvgResponseTime
+	if baseline.AvgResponseTime > 0 && result.AvgResponseTime > 0 {
+		result.BackgroundImpactPct = (result.AvgResponseTime - baseline.AvgResponseTime) / baseline.AvgResponseTime * 100
+	}
+	return result
+}
+
+// printBackground 输出前台延迟受后台负载的影响以及后台请求本身的指标
+func printBackground(results []TestResult) {
+	if len(results) == 0 || results[0].Background == nil {
+		return
+	}
+
+	fmt.Printf(msg("background_title"), *backgroundConcurrency)
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("background_header"))
+	for _, r := range results {
+		bg := r.Background
+		if bg == nil {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%+.1f%%\t%.1f\t%.1f\t%.1f\t%d\t\n",
+			r.Backend, r.Model, r.Concurrency,
+			r.ForegroundBaselineMs, r.AvgResponseTime, r.BackgroundImpactPct,
+			bg.AvgResponseTime, bg.SuccessRate, bg.TokensPerSecond, bg.Requests)
+	}
+	w.Flush()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+var maxMatrixDuration = flag.Duration("max-matrix-duration", 0, "整个测试矩阵的总时长上限 (从程序启动时算起), 如 45m: 剩余时间不足以完成下一组测试 (按已完成组的平均耗时估算) 时不再开始新的组, 输出已完成的结果并列出被跳过的组; 0 表示不限制")
+
+// skippedCell 为因 -max-matrix-duration 而未运行的测试组
+type skippedCell struct {
+	Backend       string `json:"backend"`
+	Model         string `json:"model"`
+	Concurrency   int    `json:"concurrency"`
+	ExecutionMode string `json:"execution_mode,omitempty"`
+}
+
+// budgetSkipped 为因时长上限被跳过的组, 随结果一起输出
+var budgetSkipped []skippedCell
+
+// matrixStart 为程序启动的时间, -max-matrix-duration 从此时算起, 包括 -wait-for-ready 等准备步骤
+var matrixStart = time.Now()
+
+func validateMatrixBudget() error {
+	if *maxMatrixDuration < 0 {
+		return errors.New(msg("bad_matrix_duration"))
+	}
+	return nil
+}
+
+// budgetAllows 判断在 -max-matrix-duration 内是否还来得及完成一组预计耗时 estimate 的测试
+func budgetAllows(estimate time.Duration) bool {
+	return *maxMatrixDuration == 0 || time.Since(matrixStart)+estimate <= *maxMatrixDuration
+}
+
+// skipForBudget 记录一组被跳过的测试, 第一次跳过时输出提示
+func skipForBudget(cell skippedCell) {
+	if len(budgetSkipped) == 0 {
+		fmt.Printf(msg("budget_exhausted"), *maxMatrixDuration, time.Since(matrixStart).Round(time.Second))
+	}
+	budgetSkipped = append(budgetSkipped, cell)
+}
+
+// printBudgetSkipped 列出因时长上限被跳过的组
+func printBudgetSkipped() {
+	if len(budgetSkipped) == 0 {
+		return
+	}
+	fmt.Printf(msg("budget_skipped_title"), len(budgetSkipped), *maxMatrixDuration)
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("budget_skipped_header"))
+	for _, c := range budgetSkipped {
+		fmt.Fprintf(w, "%s\t%s\t%d\t\n", c.Backend, c.Model+modeSuffix(c.ExecutionMode), c.Concurrency)
+	}
+	w.Flush()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+var (
+	cacheCheck    = flag.Bool("cache-check", false, "每个模型测试前检测服务端是否缓存了相同的请求: 分别发送若干个完全相同和附加随机串的请求, 相同请求明显更快或耗时几乎不变时发出警告")
+	cacheRequests = flag.Int("cache-check-requests", 5, "-cache-check 每种请求发送的个数")
+	promptNonce   = flag.Bool("prompt-nonce", false, "在每个提示词末尾附加随机串, 使每个请求都不同, 避免服务端缓存相同请求的响应; 模型仍可复用相同前缀的 KV 缓存")
+)
+
+// 判定存在缓存的阈值: 相同请求的中位耗时不到附加随机串时的一半, 或相同请求耗时的变异系数低于 cacheFlatCV 且明显快于附加随机串时
+const (
+	cacheSpeedupRatio = 0.5
+	cacheFlatCV       = 0.02
+	cacheFlatRatio    = 0.8
+)
+
+// cacheCheckResult 为 -cache-check 的测量结果
+type cacheCheckResult struct {
+	IdenticalMs float64 `json:"identical_ms"` // 相同请求 (不含第一个) 的中位耗时
+	UniqueMs    float64 `json:"unique_ms"`    // 附加随机串的请求的中位耗时
+	IdenticalCV float64 `json:"identical_cv"` // 相同请求耗时的变异系数
+	Suspected   bool    `json:"suspected"`    // 疑似存在响应缓存
+}
+
+// validateCacheCheck 检查 -cache-check-requests, 至少需要 2 个请求才能判断耗时是否恒定
+func validateCacheCheck() error {
+	if *cacheCheck && *cacheRequests < 2 {
+		return errors.New(msg("bad_cache_requests"))
+	}
+	return nil
+}
+
+// nonce 返回一个随机的十六进制串
+func nonce() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withNonce 在 -prompt-nonce 开启时为提示词附加随机串
+func withNonce(prompt string) string {
+	if !*promptNonce {
+		return prompt
+	}
+	return prompt + "\n\n[" + nonce() + "]"
+}
+
+// measureCache 先发送一个请求让服务端有机会缓存, 再依次发送相同请求和附加随机串的请求各 -cache-check-requests 个;
+// 请求失败时返回 nil
+func measureCache(backend Backend, model string) *cacheCheckResult {
+	client := &http.Client{Timeout: timeouts.forModel(model), Transport: requestTransport()}
+	prompt := prompts[0]
+	send := func(text string) (time.Duration, bool) {
+		outcome, err := sendRequest(shutdownCtx, 0, client, backend, model, []string{text})
+		if err != nil {
+			fmt.Println(msg("cache_check_failed"), err)
+			return 0, false
+		}
+		return outcome.elapsed, true
+	}
+
+	if _, ok := send(prompt); !ok {
+		return nil
+	}
+	var identical, unique []time.Duration
+	for i := 0; i < *cacheRequests; i++ {
+		d, ok := send(prompt)
+		if !ok {
+			return nil
+		}
+		identical = append(identical, d)
+	}
+	for i := 0; i < *cacheRequests; i++ {
+		d, ok := send(prompt + "\n\n[" + nonce() + "]")
+		if !ok {
+			return nil
+		}
+		unique = append(unique, d)
+	}
+
+	r := &cacheCheckResult{
+		IdenticalMs: percentileMs(identical, 50),
+		UniqueMs:    percentileMs(unique, 50),
+	}
+	r.IdenticalCV = variationCoefficient(identical)
+	if r.UniqueMs > 0 {
+		ratio := r.IdenticalMs / r.UniqueMs
+		r.Suspected = ratio < cacheSpeedupRatio || r.IdenticalCV < cacheFlatCV && ratio < cacheFlatRatio
+	}
+
+	if r.Suspected {
+		fmt.Printf(msg("cache_suspected"), backend.Name(), model, r.IdenticalMs, r.UniqueMs, r.IdenticalCV*100)
+	} else {
+		fmt.Printf(msg("cache_not_detected"), backend.Name(), model, r.IdenticalMs, r.UniqueMs)
+	}
+	return r
+}
+
+// variationCoefficient 返回耗时的标准差与平均值之比
+func variationCoefficient(ds []time.Duration) float64 {
+	var mean float64
+	for _, d := range ds {
+		mean += d.Seconds() / float64(len(ds))
+	}
+	if mean == 0 {
+		return 0
+	}
+	var variance float64
+	for _, d := range ds {
+		variance += (d.Seconds() - mean) * (d.Seconds() - mean) / float64(len(ds))
+	}
+	return math.Sqrt(variance) / mean
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+)
+
+var capacityThreshold = flag.Float64("capacity-threshold", 99, "判断模型容量上限的成功率(%): 并发数从低到高, 第一个成功率低于该值的并发即为容量上限")
+
+// capacityLimit 为一个 后端+模型 在并发扫描中的容量上限
+type capacityLimit struct {
+	Backend string `json:"backend"`
+	Model   string `json:"model"`
+	// MaxHealthy 为容量上限之前最高的并发数, 最低并发就已失败时为 0
+	MaxHealthy int `json:"max_healthy_concurrency"`
+	// Limit 为第一个成功率低于 -capacity-threshold 的并发数, 测到的并发都满足时为 0
+	Limit       int     `json:"limit_concurrency,omitempty"`
+	SuccessRate float64 `json:"limit_success_rate,omitempty"` // 容量上限处的成功率
+	Tested      int     `json:"max_tested_concurrency"`
+}
+
+// capacityLimits 按并发数从低到高找出每个 后端+模型 成功率开始低于阈值的并发;
+// 只统计测试了多个并发数的普通测试组, 复杂度分档、只用 CPU 和混合测试的结果不参与
+func capacityLimits(results []TestResult) []capacityLimit {
+	type modelKey struct{ backend, model string }
+
+	var order []modelKey
+	sweeps := make(map[modelKey][]TestResult)
+	for _, r := range results {
+		if r.Tier != "" || comparisonMode(r.ExecutionMode) || r.Mix != "" {
+			continue
+		}
+		key := modelKey{r.Backend, r.Model}
+		if _, ok := sweeps[key]; !ok {
+			order = append(order, key)
+		}
+		sweeps[key] = append(sweeps[key], r)
+	}
+
+	var limits []capacityLimit
+	for _, key := range order {
+		sweep := sweeps[key]
+		if len(sweep) < 2 {
+			continue
+		}
+		slices.SortStableFunc(sweep, func(a, b TestResult) int { return a.Concurrency - b.Concurrency })
+
+		c := capacityLimit{Backend: key.backend, Model: key.model, Tested: sweep[len(sweep)-1].Concurrency}
+		for _, r := range sweep {
+			if r.SuccessRate < *capacityThreshold {
+				c.Limit, c.SuccessRate = r.Concurrency, r.SuccessRate
+				break
+			}
+			c.MaxHealthy = r.Concurrency
+		}
+		limits = append(limits, c)
+	}
+	return limits
+}
+
+// printCapacity 输出每个模型的容量上限
+func printCapacity(results []TestResult) {
+	limits := capacityLimits(results)
+	if len(limits) == 0 {
+		return
+	}
+
+	fmt.Printf(msg("capacity_title"), *capacityThreshold)
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("capacity_header"))
+	for _, c := range limits {
+		limit, rate := fmt.Sprintf(msg("capacity_none"), c.Tested), "-"
+		if c.Limit > 0 {
+			limit, rate = strconv.Itoa(c.Limit), fmt.Sprintf("%.1f", c.SuccessRate)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t\n", c.Backend, c.Model, c.MaxHealthy, limit, rate)
+	}
+	w.Flush()
+}
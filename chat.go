@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ollamaChatEndpoint = flag.String("ollama-chat-endpoint", "http://localhost:11434/api/chat", "ollama 对话接口地址 (-backend ollama-chat)")
+	systemPrompt       = flag.String("system", "", "对话接口 (ollama-chat) 每个请求附带的系统提示词, 并统计其带来的输入 token 开销")
+)
+
+// ollamaChatBackend 对应 ollama 的 /api/chat 接口, 单轮对话, 每个请求都带上系统提示词;
+// 错误体、生成 token 数和卸载方式与 /api/generate 相同
+type ollamaChatBackend struct {
+	ollamaBackend
+	system     string // 系统提示词, 为空时不带
+	numPredict int    // 大于 0 时覆盖 -num-predict, 用于只关心输入 token 的探测请求
+}
+
+func (b ollamaChatBackend) Name() string { return "ollama-chat" }
+
+func (b ollamaChatBackend) RequestBody(model, prompt string) map[string]interface{} {
+	var messages []map[string]string
+	if b.system != "" {
+		messages = append(messages, map[string]string{"role": "system", "content": b.system})
+	}
+	messages = append(messages, map[string]string{"role": "user", "content": prompt})
+
+	body := map[string]interface{}{
+		"model":    model,
+		"messages": messages,
+		"stream":   false,
+	}
+	options := ollamaOptions()
+	if b.numPredict > 0 {
+		if options == nil {
+			options = make(map[string]interface{})
+		}
+		options["num_predict"] = b.numPredict
+	}
+	if options != nil {
+		body["options"] = options
+	}
+	return body
+}
+
+func (b ollamaChatBackend) ResponseText(response map[string]interface{}) interface{} {
+	message, ok := response["message"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return message["content"]
+}
+
+// validateSystemPrompt 检查 -system 只用于支持系统提示词的后端
+func validateSystemPrompt(backends []Backend) error {
+	if *systemPrompt == "" {
+		return nil
+	}
+	if *bodyTemplate != "" {
+		// 模板替换了整个请求体, 系统提示词不会被发送
+		return errors.New(msg("system_template"))
+	}
+	for _, b := range backends {
+		if _, ok := b.(ollamaChatBackend); !ok {
+			return fmt.Errorf(msg("system_unsupported"), b.Name())
+		}
+	}
+	return nil
+}
+
+// measureSystemTokens 分别发送不带和带系统提示词的同一请求, 以服务端报告的输入 token 数之差估算系统提示词的 token 数;
+// 先发不带系统提示词的请求, 避免 ollama 复用缓存的前缀而少报输入 token. 两个请求都不附带图片, 以免差值受图片影响
+func measureSystemTokens(backend Backend, model string) int {
+	chat, ok := backend.(ollamaChatBackend)
+	if !ok {
+		return 0
+	}
+	client := &http.Client{Timeout: timeouts.forModel(model), Transport: requestTransport()}
+
+	count := func(system string) (int, error) {
+		probe := chat
+		probe.system = system
+		// 只关心输入 token, 生成一个 token 即可
+		probe.numPredict = 1
+		outcome, err := sendRequestImage(shutdownCtx, 0, client, probe, model, prompts[:1], nil)
+		if err != nil {
+			return 0, err
+		}
+		if !outcome.promptTokensKnown {
+			return 0, errors.New(msg("no_prompt_tokens"))
+		}
+		return outcome.promptTokens, nil
+	}
+
+	without, err := count("")
+	if err != nil {
+		fmt.Println(msg("system_tokens_failed"), err)
+		return 0
+	}
+	with, err := count(chat.system)
+	if err != nil {
+		fmt.Println(msg("system_tokens_failed"), err)
+		return 0
+	}
+
+	tokens := max(0, with-without)
+	fmt.Printf(msg("system_tokens_done"), backend.Name(), model, tokens)
+	return tokens
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestMeasureSystemTokens(t *testing.T) {
+	const system = "你是一个严谨的助手"
+	var mu sync.Mutex
+	var bodies []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		// 每条消息计 10 个输入 token, 带系统提示词时多 10 个
+		n := len(body["messages"].([]interface{})) * 10
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":           map[string]string{"role": "assistant", "content": "好"},
+			"prompt_eval_count": n,
+			"eval_count":        1,
+		})
+	}))
+	defer srv.Close()
+
+	saved := *quietMode
+	*quietMode = true
+	t.Cleanup(func() { *quietMode = saved })
+
+	backend := ollamaChatBackend{ollamaBackend: ollamaBackend{endpoint: srv.URL}, system: system}
+	if got := measureSystemTokens(backend, "m"); got != 10 {
+		t.Errorf("measureSystemTokens() = %d, want 10", got)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(bodies))
+	}
+	for i, wantMessages := range []int{1, 2} {
+		if n := len(bodies[i]["messages"].([]interface{})); n != wantMessages {
+			t.Errorf("request %d has %d messages, want %d", i, n, wantMessages)
+		}
+		options, _ := bodies[i]["options"].(map[string]interface{})
+		if options["num_predict"] != float64(1) {
+			t.Errorf("request %d options = %v, want num_predict 1", i, options)
+		}
+	}
+	// 测量不改动后端本身的系统提示词
+	if body := backend.RequestBody("m", "hi"); len(body["messages"].([]map[string]string)) != 2 {
+		t.Errorf("backend lost its system prompt: %v", body)
+	}
+}
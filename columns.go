@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+
+	"model-test/internal/monitor"
+)
+
+var columnSpec = flag.String("columns", "", "结果中的列及其顺序, 逗号分隔, 如 model,concurrency,p95,rps,success, 对表格和 CSV 输出生效; JSON 中另以 columns 键输出只含所选列的结果, results 仍为完整结果; 空表示显示全部列, 表格超出终端宽度时改为逐组纵向显示")
+
+// resultColumn 为结果中的一列, 表格、CSV 和 JSON 的 columns 共用
+type resultColumn struct {
+	key    string // -columns 中使用的名称, 也是 CSV 表头和 JSON columns 中的键
+	header string
+	value  func(TestResult) string      // 表格中显示的值
+	raw    func(TestResult) interface{} // CSV 和 JSON columns 中的原始值, 无法采集的指标为 nil
+}
+
+// selectedColumns 为 -columns 选择的列, 为 nil 时显示全部列
+var selectedColumns []resultColumn
+
+// resultColumnKeys 与 results_header 中的列一一对应
+var resultColumnKeys = []string{
+	"backend", "model", "concurrency", "cpu", "gpu", "vram", "vram_pct", "vram_delta", "memory",
+	"avg", "max", "min", "success", "oversized", "cpu_offload", "saved", "cold_start",
+	"empty", "tokens", "capped", "queue",
+}
+
+// metricRaw 返回一项资源指标的原始值, 整组都无法采集时返回 nil
+func metricRaw(r TestResult, name string, value float64) interface{} {
+	if slices.Contains(r.UnavailableMetrics, name) {
+		return nil
+	}
+	return value
+}
+
+// resultColumns 返回结果的全部列, -percentiles 的各列插在最小响应之后;
+// extra 为 true 时追加默认不显示、只能通过 -columns 选择的列
+func resultColumns(extra bool) []resultColumn {
+	headers := strings.Split(strings.TrimSuffix(msg("results_header"), "\t"), "\t")
+	f1 := func(v float64) string { return fmt.Sprintf("%.1f", v) }
+	// number 为普通数值列, 表格中保留一位小数; 以下辅助函数只填写 value 和 raw
+	number := func(get func(TestResult) float64) resultColumn {
+		return resultColumn{
+			value: func(r TestResult) string { return f1(get(r)) },
+			raw:   func(r TestResult) interface{} { return get(r) },
+		}
+	}
+	count := func(get func(TestResult) int) resultColumn {
+		return resultColumn{
+			value: func(r TestResult) string { return strconv.Itoa(get(r)) },
+			raw:   func(r TestResult) interface{} { return get(r) },
+		}
+	}
+	metric := func(name, format string, get func(TestResult) float64) resultColumn {
+		return resultColumn{
+			value: func(r TestResult) string { return metricCell(r, name, format, get(r)) },
+			raw:   func(r TestResult) interface{} { return metricRaw(r, name, get(r)) },
+		}
+	}
+	text := func(get func(TestResult) string) resultColumn {
+		return resultColumn{value: get, raw: func(r TestResult) interface{} { return get(r) }}
+	}
+	values := []resultColumn{
+		text(func(r TestResult) string { return r.Backend }),
+		text(modelCell),
+		count(func(r TestResult) int { return r.Concurrency }),
+		metric(monitor.NameCPULoad, "%.1f", func(r TestResult) float64 { return r.CPULoad }),
+		metric(monitor.NameGPULoad, "%.1f", func(r TestResult) float64 { return r.GPULoad }),
+		metric(monitor.NameGPUMemoryUsed, "%.0f", func(r TestResult) float64 { return r.GPUMemoryUsed }),
+		{
+			value: func(r TestResult) string {
+				if r.GPUMemoryPercent == 0 {
+					return "-"
+				}
+				return f1(r.GPUMemoryPercent)
+			},
+			raw: func(r TestResult) interface{} {
+				if r.GPUMemoryPercent == 0 {
+					return nil
+				}
+				return r.GPUMemoryPercent
+			},
+		},
+		metric(monitor.NameGPUMemoryUsed, "%.0f", func(r TestResult) float64 { return r.GPUMemoryDelta }),
+		metric(monitor.NameMemoryUsed, "%.1f", func(r TestResult) float64 { return r.MemoryUsed }),
+		number(func(r TestResult) float64 { return r.AvgResponseTime }),
+		number(func(r TestResult) float64 { return r.MaxResponseTime }),
+		number(func(r TestResult) float64 { return r.MinResponseTime }),
+		number(func(r TestResult) float64 { return r.SuccessRate }),
+		count(func(r TestResult) int { return r.OversizedCount }),
+		{
+			value: func(r TestResult) string {
+				if r.CPUOffloaded {
+					return msg("yes")
+				}
+				return msg("no")
+			},
+			raw: func(r TestResult) interface{} { return r.CPUOffloaded },
+		},
+		number(func(r TestResult) float64 { return float64(r.BytesSaved) / 1024 }),
+		number(func(r TestResult) float64 { return r.ColdStartMs }),
+		count(func(r TestResult) int { return r.EmptyResponses }),
+		{value: tokenCell, raw: func(r TestResult) interface{} { return r.AvgCompletionTokens }},
+		number(func(r TestResult) float64 { return r.CappedRate }),
+		number(func(r TestResult) float64 { return r.EstQueueWaitMs }),
+	}
+	column := func(key, header string, c resultColumn) resultColumn {
+		c.key, c.header = key, header
+		return c
+	}
+
+	var columns []resultColumn
+	for i, key := range resultColumnKeys {
+		columns = append(columns, column(key, headers[i], values[i]))
+		if key == "min" {
+			for _, p := range reportedPercentiles {
+				label := percentileLabel(p)
+				columns = append(columns, resultColumn{
+					key:    label,
+					header: label + "(ms)",
+					value:  func(r TestResult) string { return percentileCell(r, p) },
+					raw: func(r TestResult) interface{} {
+						if v, ok := r.Percentiles[label]; ok {
+							return v
+						}
+						return nil
+					},
+				})
+			}
+		}
+	}
+	if !extra {
+		return columns
+	}
+
+	if !slices.Contains(reportedPercentiles, 95) {
+		columns = append(columns, column("p95", "p95(ms)", number(func(r TestResult) float64 { return r.P95ResponseTime })))
+	}
+	return append(columns,
+		column("rps", msg("column_rps"), number(requestsPerSecond)),
+		column("busy_cores", msg("column_busy_cores"),
+			metric(monitor.NameCPUCores, "%.0f", func(r TestResult) float64 { return float64(r.CPUCoresBusy) })),
+		column("hot_core", msg("column_hot_core"),
+			metric(monitor.NameCPUCores, "%.1f", func(r TestResult) float64 { return r.CPUHottestCore })),
+	)
+}
+
+// parseColumns 解析 -columns, 空字符串返回 nil
+func parseColumns(spec string) ([]resultColumn, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	all := resultColumns(true)
+	var columns []resultColumn
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+		i := slices.IndexFunc(all, func(c resultColumn) bool { return c.key == key })
+		if i < 0 {
+			keys := make([]string, len(all))
+			for j, c := range all {
+				keys[j] = c.key
+			}
+			return nil, fmt.Errorf(msg("unknown_column"), key, strings.Join(keys, ","))
+		}
+		columns = append(columns, all[i])
+	}
+	return columns, nil
+}
+
+// fprintResults 输出结果表; 未设置 -columns 且表格宽于 maxWidth (大于 0 时) 时改为逐组纵向显示
+func fprintResults(out io.Writer, results []TestResult, maxWidth int) {
+	columns := selectedColumns
+	if columns == nil {
+		columns = resultColumns(false)
+	}
+
+	var b strings.Builder
+	w := newTableWriter(&b)
+	for _, c := range columns {
+		fmt.Fprintf(w, "%s\t", c.header)
+	}
+	fmt.Fprintln(w)
+	for _, r := range results {
+		for _, c := range columns {
+			fmt.Fprintf(w, "%s\t", c.value(r))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	if selectedColumns == nil && maxWidth > 0 && len(results) > 0 && tableWidth(b.String()) > maxWidth {
+		fprintResultsVertical(out, results, columns)
+		return
+	}
+	io.WriteString(out, b.String())
+}
+
+// fprintResultsVertical 逐组以 名称: 值 的形式输出结果, 用于终端放不下整张表时
+func fprintResultsVertical(out io.Writer, results []TestResult, columns []resultColumn) {
+	for i, r := range results {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "[%s] %s ×%d\n", r.Backend, modelCell(r), r.Concurrency)
+		w := newTableWriter(out)
+		for _, c := range columns {
+			switch c.key {
+			case "backend", "model", "concurrency":
+				continue
+			}
+			fmt.Fprintf(w, "  %s:\t%s\n", c.header, c.value(r))
+		}
+		w.Flush()
+	}
+}
+
+// tableWidth 返回已对齐表格中最长一行的显示宽度
+func tableWidth(table string) int {
+	width := 0
+	for _, line := range strings.Split(table, "\n") {
+		width = max(width, runewidth.StringWidth(strings.TrimRight(line, " ")))
+	}
+	return width
+}
+
+// terminalWidth 返回标准输出所在终端的列数, 非终端或无法获取时返回 0; 优先使用 COLUMNS 环境变量
+func terminalWidth() int {
+	if !isTerminal() {
+		return 0
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return ttyWidth(os.Stdout)
+}
+
+// outputColumns 返回 CSV 输出使用的列: -columns 选择的列, 未设置时为结果表的全部列
+func outputColumns() []resultColumn {
+	if selectedColumns != nil {
+		return selectedColumns
+	}
+	return resultColumns(false)
+}
+
+// columnRow 按所选列的顺序以 JSON 输出一组结果
+type columnRow struct {
+	columns []resultColumn
+	result  TestResult
+}
+
+func (row columnRow) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, c := range row.columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(c.key)
+		value, err := json.Marshal(c.raw(row.result))
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// columnsView 为 JSON 中 -columns 选择的视图, 与完整的 results 并列输出;
+// merge 只读取 results, 合并后的结果中没有该视图
+type columnsView struct {
+	Keys      []string    `json:"keys"` // 所选的列, 按 -columns 的顺序
+	Results   []columnRow `json:"results"`
+	Snapshots []columnRow `json:"snapshots,omitempty"`
+}
+
+// newColumnsView 返回 -columns 的 JSON 视图, 未设置 -columns 时返回 nil
+func newColumnsView(results, snapshots []TestResult) *columnsView {
+	if selectedColumns == nil {
+		return nil
+	}
+	rows := func(results []TestResult) []columnRow {
+		if results == nil {
+			return nil
+		}
+		out := make([]columnRow, len(results))
+		for i, r := range results {
+			out[i] = columnRow{selectedColumns, r}
+		}
+		return out
+	}
+	view := &columnsView{Results: rows(results), Snapshots: rows(snapshots)}
+	if view.Results == nil {
+		view.Results = []columnRow{}
+	}
+	for _, c := range selectedColumns {
+		view.Keys = append(view.Keys, c.key)
+	}
+	return view
+}
+
+// writeCSV 以 CSV 输出结果, 表头为列名, 无法采集的指标为空
+func writeCSV(out io.Writer, results []TestResult) error {
+	columns := outputColumns()
+	w := csv.NewWriter(out)
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = c.key
+	}
+	w.Write(record)
+	for _, r := range results {
+		for i, c := range columns {
+			record[i] = csvValue(c.raw(r))
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
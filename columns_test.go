@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"model-test/internal/monitor"
+)
+
+func TestColumnsView(t *testing.T) {
+	columns, err := parseColumns("success,model,vram")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := selectedColumns
+	selectedColumns = columns
+	t.Cleanup(func() { selectedColumns = saved })
+
+	results := []TestResult{
+		{Backend: "ollama", Model: "a", Concurrency: 1, SuccessRate: 100, GPUMemoryUsed: 2048},
+		{Backend: "ollama", Model: "b", Concurrency: 2, SuccessRate: 50, UnavailableMetrics: []string{monitor.NameGPUMemoryUsed}},
+	}
+	doc := resultsDocument{SchemaVersion: resultsSchemaVersion, Results: results, Columns: newColumnsView(results, nil)}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 所选的列按 -columns 的顺序输出, 无法采集的指标为 null
+	want := `"columns":{"keys":["success","model","vram"],"results":[{"success":100,"model":"a","vram":2048},{"success":50,"model":"b","vram":null}]}`
+	if !strings.Contains(string(data), want) {
+		t.Errorf("JSON = %s, want it to contain %s", data, want)
+	}
+
+	// merge 读取的 results 仍为完整结果
+	file := filepath.Join(t.TempDir(), "results.json")
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readResultsDocument(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Results) != 2 || read.Results[1].Concurrency != 2 || read.Results[0].GPUMemoryUsed != 2048 {
+		t.Errorf("readResultsDocument() results = %+v, want the full results", read.Results)
+	}
+
+	selectedColumns = nil
+	if view := newColumnsView(results, nil); view != nil {
+		t.Errorf("newColumnsView() without -columns = %+v, want nil", view)
+	}
+}
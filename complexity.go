@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+var (
+	complexityMode        = flag.String("complexity", "", "按提示词难度分级测试: length 按提示词长度分级, tag 按提示词文件中的 [标签] 分级; 为空时不启用")
+	complexityTiers       = flag.Int("complexity-tiers", 3, "-complexity length 时的分级数, 按长度排序后等分")
+	complexityConcurrency = flag.Int("complexity-concurrency", 1, "难度分级测试和 -compare-prompts 使用的固定并发数")
+	comparePrompts        = flag.Bool("compare-prompts", false, "以固定并发逐条测试每个提示词, 输出各提示词平均响应的最小、最大值和标准差, 衡量模型对提示词的敏感程度")
+)
+
+// promptTier 为一个难度级别及其包含的提示词
+type promptTier struct {
+	name    string
+	prompts []string
+}
+
+func validateComplexityMode(mode string) error {
+	if mode != "" && *comparePrompts {
+		return errors.New(msg("complexity_and_compare"))
+	}
+	switch mode {
+	case "":
+		return nil
+	case "length":
+		if *complexityTiers < 1 {
+			return fmt.Errorf(msg("bad_positive_int"), strconv.Itoa(*complexityTiers))
+		}
+		return nil
+	case "tag":
+		for i, tag := range promptTags {
+			if tag == "" {
+				return fmt.Errorf(msg("untagged_prompt"), prompts[i])
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf(msg("unknown_complexity"), mode)
+}
+
+// buildPromptTiers 将提示词按难度从低到高分级, -compare-prompts 时每条提示词单独一级
+func buildPromptTiers(mode string, texts, tags []string) []promptTier {
+	if *comparePrompts {
+		tiers := make([]promptTier, len(texts))
+		for i, t := range texts {
+			tiers[i] = promptTier{name: promptLabel(t), prompts: []string{t}}
+		}
+		return tiers
+	}
+	if mode == "tag" {
+		return tiersByTag(texts, tags)
+	}
+	return tiersByLength(texts, *complexityTiers)
+}
+
+// tiersByTag 按标签分组; 标签都是数字时按数值排序, 否则按字符串排序
+func tiersByTag(texts, tags []string) []promptTier {
+	groups := make(map[string][]string)
+	var names []string
+	for i, tag := range tags {
+		if _, ok := groups[tag]; !ok {
+			names = append(names, tag)
+		}
+		groups[tag] = append(groups[tag], texts[i])
+	}
+
+	sort.SliceStable(names, func(i, j int) bool {
+		a, errA := strconv.ParseFloat(names[i], 64)
+		b, errB := strconv.ParseFloat(names[j], 64)
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return names[i] < names[j]
+	})
+
+	tiers := make([]promptTier, len(names))
+	for i, name := range names {
+		tiers[i] = promptTier{name: name, prompts: groups[name]}
+	}
+	return tiers
+}
+
+// tiersByLength 按字符数排序后等分为 n 级, 提示词少于 n 条时每条一级
+func tiersByLength(texts []string, n int) []promptTier {
+	sorted := append([]string(nil), texts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return utf8.RuneCountInString(sorted[i]) < utf8.RuneCountInString(sorted[j])
+	})
+	n = min(n, len(sorted))
+
+	var tiers []promptTier
+	for i := 0; i < n; i++ {
+		group := sorted[i*len(sorted)/n : (i+1)*len(sorted)/n]
+		tiers = append(tiers, promptTier{name: fmt.Sprintf("L%d", i+1), prompts: group})
+	}
+	return tiers
+}
+
+// promptLabel 截取提示词开头作为显示名称
+func promptLabel(prompt string) string {
+	const maxRunes = 20
+	runes := []rune(prompt)
+	if len(runes) <= maxRunes {
+		return prompt
+	}
+	return string(runes[:maxRunes]) + "…"
+}
+
+func avgPromptChars(texts []string) float64 {
+	total := 0
+	for _, t := range texts {
+		total += utf8.RuneCountInString(t)
+	}
+	return float64(total) / float64(len(texts))
+}
+
+// runComplexity 以固定并发依次测试每个模型在各难度级别下的响应时间
+func runComplexity(backends []Backend, models []string, meta runMetadata) int {
+	tiers := buildPromptTiers(*complexityMode, prompts, promptTags)
+	allPrompts := prompts
+	defer func() { prompts = allPrompts }()
+
+	var results []TestResult
+tiers:
+	for _, model := range models {
+		for _, backend := range backends {
+			for _, tier := range tiers {
+				if shutdownCtx.Err() != nil {
+					break tiers
+				}
+
+				fmt.Printf(msg("complexity_testing"), backend.Name(), model, tier.name, len(tier.prompts))
+				prompts = tier.prompts
+				result := runTest(backend, model, *complexityConcurrency)
+				result.Tier = tier.name
+				result.TierAvgPromptChars = avgPromptChars(tier.prompts)
+				applyExpectedTPS(&result)
+				results = append(results, result)
+				notifyCell(result)
+				coolDown()
+			}
+		}
+	}
+
+	if err := writeResults(results, nil, meta); err != nil {
+		fmt.Println(msg("output_error"), err)
+		return exitOutput
+	}
+	if shutdownCtx.Err() != nil {
+		return interruptedExitCode()
+	}
+	return exitCode(results)
+}
+
+// printPromptSpread 在 -compare-prompts 时按 后端+模型 输出各提示词平均响应的离散程度
+func printPromptSpread(results []TestResult) {
+	if !*comparePrompts || len(results) == 0 {
+		return
+	}
+
+	type modelKey struct{ backend, model string }
+	var order []modelKey
+	avgs := make(map[modelKey][]float64)
+	for _, r := range results {
+		key := modelKey{r.Backend, r.Model}
+		if _, ok := avgs[key]; !ok {
+			order = append(order, key)
+		}
+		if r.AvgResponseTime > 0 {
+			avgs[key] = append(avgs[key], r.AvgResponseTime)
+		}
+	}
+
+	fmt.Println(msg("prompt_spread_title"))
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("prompt_spread_header"))
+	for _, key := range order {
+		values := avgs[key]
+		if len(values) == 0 {
+			continue
+		}
+		lo, hi, mean := values[0], values[0], 0.0
+		for _, v := range values {
+			lo, hi = min(lo, v), max(hi, v)
+			mean += v / float64(len(values))
+		}
+		variance := 0.0
+		for _, v := range values {
+			variance += (v - mean) * (v - mean) / float64(len(values))
+		}
+		stddev := math.Sqrt(variance)
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			key.backend, key.model, len(values), lo, hi, stddev, stddev/mean*100)
+	}
+	w.Flush()
+}
+
+// printTiers 输出难度分级测试中各级别的响应时间
+func printTiers(results []TestResult) {
+	if len(results) == 0 || results[0].Tier == "" {
+		return
+	}
+
+	fmt.Println(msg("complexity_title"))
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("complexity_header"))
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			r.Backend, r.Model, r.Tier, r.TierAvgPromptChars, r.AvgResponseTime, r.MaxResponseTime, r.SuccessRate, r.AvgCompletionTokens)
+	}
+	w.Flush()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+var compareCPU = flag.Bool("compare-against-cpu", false, "每组测试再以 num_gpu: 0 (只用 CPU) 运行一次, 输出 GPU 相对 CPU 的延迟和吞吐倍数 (仅 ollama)")
+
+// 执行方式, 记录在 TestResult.ExecutionMode 中
+const (
+	modeGPU = "gpu"
+	modeCPU = "cpu"
+)
+
+// forceCPU 为 true 时 ollama 请求带上 num_gpu: 0, 模型全部在 CPU 上运行
+var forceCPU bool
+
+// ollamaOptions 返回 ollama 请求的 options 字段, 没有需要设置的选项时返回 nil
+func ollamaOptions() map[string]interface{} {
+	options := make(map[string]interface{})
+	if *numPredict > 0 {
+		options["num_predict"] = *numPredict
+	}
+	if forceCPU {
+		options["num_gpu"] = 0
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// validateCompareCPU 检查 -compare-against-cpu 只用于 ollama 的接口
+func validateCompareCPU(backends []Backend) error {
+	if !*compareCPU {
+		return nil
+	}
+	if *bodyTemplate != "" {
+		return errors.New(msg("cpu_template"))
+	}
+	for _, b := range backends {
+		switch b.(type) {
+		case ollamaBackend, ollamaChatBackend:
+		default:
+			return fmt.Errorf(msg("cpu_unsupported"), b.Name())
+		}
+	}
+	return nil
+}
+
+// executionModes 返回每组测试需要依次运行的执行方式
+func executionModes() []string {
+	switch {
+	case *compareCPU:
+		return []string{modeGPU, modeCPU}
+	case *compareKeepAlive:
+		return []string{modeKeepAlive, modeNoKeepAlive}
+	}
+	return []string{""}
+}
+
+// comparisonMode 判断执行方式是否只用于对比 (只用 CPU、不复用连接), 这类结果不参与量化、容量和多后端对比
+func comparisonMode(mode string) bool {
+	return mode == modeCPU || mode == modeNoKeepAlive
+}
+
+// modeSuffix 返回对比用执行方式在模型名后的标记
+func modeSuffix(mode string) string {
+	if comparisonMode(mode) {
+		return " [" + mode + "]"
+	}
+	return ""
+}
+
+// modelCell 返回结果表中的模型名, 只用 CPU 或不复用连接运行的结果加上标记
+func modelCell(r TestResult) string {
+	return r.Model + modeSuffix(r.ExecutionMode)
+}
+
+// printModeComparison 输出同一组测试在两种执行方式下的对比, 每对结果由 row 输出一行
+func printModeComparison(results []TestResult, base, other, title, header string, row func(w io.Writer, b, o TestResult)) {
+	paired := make(map[resultKey]TestResult)
+	for _, r := range results {
+		if r.ExecutionMode == other {
+			key := keyOf(r)
+			key.mode = base
+			paired[key] = r
+		}
+	}
+
+	fmt.Println(title)
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, header)
+	for _, b := range results {
+		if o, ok := paired[keyOf(b)]; ok && b.ExecutionMode == base {
+			row(w, b, o)
+		}
+	}
+	w.Flush()
+}
+
+// printCPUComparison 在 -compare-against-cpu 时对比同一组测试在 GPU 和 CPU 上的结果
+func printCPUComparison(results []TestResult) {
+	if !*compareCPU {
+		return
+	}
+	printModeComparison(results, modeGPU, modeCPU, msg("cpu_title"), msg("cpu_header"), func(w io.Writer, g, c TestResult) {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%s\t%.1f\t%.1f\t%s\t\n",
+			g.Backend, g.Model, g.Concurrency,
+			g.AvgResponseTime, c.AvgResponseTime, speedup(c.AvgResponseTime, g.AvgResponseTime),
+			g.TokensPerSecond, c.TokensPerSecond, speedup(g.TokensPerSecond, c.TokensPerSecond))
+	})
+}
+
+// speedup 返回 num/den 的倍数, 无法计算时返回 -
+func speedup(num, den float64) string {
+	if num <= 0 || den <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fx", num/den)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+
+	"model-test/internal/monitor"
+)
+
+var (
+	coreBusyThreshold = flag.Float64("core-busy-threshold", 50, "单个 CPU 核心在整组测试中的平均利用率(%) 超过该值时计为忙碌核心")
+	perCoreCPU        = flag.Bool("per-core-cpu", false, "在 JSON 结果中输出每组测试各 CPU 核心的平均利用率")
+)
+
+// coreLoad 计算整组测试中每个核心的平均利用率, 返回平均利用率超过 threshold 的核心数和最忙核心的平均利用率
+func coreLoad(metrics []monitor.Metrics, threshold float64) (busy int, hottest float64, avg []float64) {
+	samples := 0
+	for _, m := range metrics {
+		if len(m.CPUCores) == 0 {
+			continue
+		}
+		if len(m.CPUCores) > len(avg) {
+			avg = append(avg, make([]float64, len(m.CPUCores)-len(avg))...)
+		}
+		for i, v := range m.CPUCores {
+			avg[i] += v
+		}
+		samples++
+	}
+	if samples == 0 {
+		return 0, 0, nil
+	}
+	for i := range avg {
+		avg[i] /= float64(samples)
+		if avg[i] > threshold {
+			busy++
+		}
+		hottest = max(hottest, avg[i])
+	}
+	return busy, hottest, avg
+}
+
+// warnSingleCore 在负载集中在单个核心时输出警告: 多核机器上只有一个忙碌核心且该核心接近满载,
+// 常见于 CPU 推理的单线程瓶颈, 此时整体 CPU 利用率会显得很低
+func warnSingleCore(r TestResult) {
+	if runtime.NumCPU() > 1 && r.CPUCoresBusy == 1 && r.CPUHottestCore >= 90 {
+		fmt.Printf(msg("single_core_warning"), r.Backend, r.Model, r.Concurrency, r.CPUHottestCore, r.CPULoad)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"model-test/internal/monitor"
+)
+
+func TestCoreLoad(t *testing.T) {
+	samples := []monitor.Metrics{
+		{CPUCores: []float64{100, 10, 0}},
+		{}, // 该次逐核采样失败, 不计入平均
+		{CPUCores: []float64{80, 30, 20}},
+	}
+	busy, hottest, avg := coreLoad(samples, 50)
+	if want := []float64{90, 20, 10}; !slices.Equal(avg, want) {
+		t.Errorf("coreLoad() avg = %v, want %v", avg, want)
+	}
+	if busy != 1 || hottest != 90 {
+		t.Errorf("coreLoad() = busy %d, hottest %v, want 1, 90", busy, hottest)
+	}
+
+	if busy, hottest, avg := coreLoad(nil, 50); busy != 0 || hottest != 0 || avg != nil {
+		t.Errorf("coreLoad(nil) = %d, %v, %v, want zero values", busy, hottest, avg)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	datasetFile    = flag.String("dataset", "", "从 JSONL 文件读取提示词, 每行一个含 prompt 字段的 JSON 对象 (如导出的真实用户请求); 不能与 -prompts-file 或标准输入同时使用")
+	promptSampling = flag.String("prompt-sampling", "random", "每组测试抽取提示词的方式: random 有放回随机抽取, cycle 按顺序循环, shuffle 打乱后不放回抽取 (用完一轮再重新打乱)")
+)
+
+// datasetRecord 为 -dataset 文件中的一行, 其他字段忽略
+type datasetRecord struct {
+	Prompt *string `json:"prompt"`
+}
+
+// loadDataset 读取 -dataset 文件, 忽略空行
+func loadDataset(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readDataset(f, path)
+}
+
+// readDataset 逐行解析 JSONL, 缺少 prompt 字段或 prompt 为空的行视为错误
+func readDataset(r io.Reader, name string) ([]string, error) {
+	var texts []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var rec datasetRecord
+		if err := json.Unmarshal([]byte(text), &rec); err != nil {
+			return nil, fmt.Errorf(msg("dataset_bad_line"), name, line, err)
+		}
+		if rec.Prompt == nil || strings.TrimSpace(*rec.Prompt) == "" {
+			return nil, fmt.Errorf(msg("dataset_no_prompt"), name, line)
+		}
+		texts = append(texts, *rec.Prompt)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(texts) == 0 {
+		return nil, fmt.Errorf(msg("dataset_empty"), name)
+	}
+	return texts, nil
+}
+
+// validatePromptSampling 检查 -prompt-sampling 的取值
+func validatePromptSampling() error {
+	switch *promptSampling {
+	case "random":
+		return nil
+	case "cycle", "shuffle":
+		if *fixedPromptSequence {
+			return errors.New(msg("sampling_and_fixed"))
+		}
+		return nil
+	}
+	return fmt.Errorf(msg("bad_prompt_sampling"), *promptSampling)
+}
+
+// promptSampler 按 -prompt-sampling 为一组测试的所有并发抽取提示词编号
+type promptSampler struct {
+	mode string
+	n    int
+	next atomic.Int64 // cycle 时下一个编号
+
+	mu   sync.Mutex // 保护 shuffle 时的 deck 和 pos
+	deck []int
+	pos  int
+}
+
+func newPromptSampler(mode string, n int) *promptSampler {
+	return &promptSampler{mode: mode, n: n}
+}
+
+// pick 返回下一个要使用的提示词编号
+func (p *promptSampler) pick() int {
+	switch p.mode {
+	case "cycle":
+		return int((p.next.Add(1) - 1) % int64(p.n))
+	case "shuffle":
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.pos == len(p.deck) {
+			if p.deck == nil {
+				p.deck = make([]int, p.n)
+				for i := range p.deck {
+					p.deck[i] = i
+				}
+			}
+			rand.Shuffle(len(p.deck), func(i, j int) { p.deck[i], p.deck[j] = p.deck[j], p.deck[i] })
+			p.pos = 0
+		}
+		p.pos++
+		return p.deck[p.pos-1]
+	}
+	return rand.Intn(p.n)
+}
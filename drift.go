@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"model-test/internal/monitor"
+)
+
+var sampleDriftThreshold = flag.Duration("sample-drift-threshold", 250*time.Millisecond, "相邻两次资源采样的实际间隔偏离计划间隔 (1s) 超过该值时警告资源数据可能不可靠 (压测端占满 CPU 时采样协程得不到及时调度); 0 表示不检查")
+
+// sampleDrift 返回相邻采样实际间隔与计划间隔的最大偏差, 以及偏差超过 -sample-drift-threshold 的间隔数
+func sampleDrift(metrics []monitor.Metrics, interval time.Duration) (maxDrift time.Duration, drifted int) {
+	if *sampleDriftThreshold <= 0 {
+		return 0, 0
+	}
+	for i := 1; i < len(metrics); i++ {
+		drift := metrics[i].Time.Sub(metrics[i-1].Time) - interval
+		drift = max(drift, -drift)
+		maxDrift = max(maxDrift, drift)
+		if drift > *sampleDriftThreshold {
+			drifted++
+		}
+	}
+	return maxDrift, drifted
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	expectedTPS         = flag.String("expected-tps", "", "各模型预期的生成吞吐 (token/秒), 如 deepseek-r1:7b=45,deepseek-r1:14b=25; 结果中给出实际与预期的比例")
+	efficiencyThreshold = flag.Float64("efficiency-threshold", 50, "实际吞吐低于预期的该百分比时标记为性能不足")
+)
+
+// expectedThroughput 为 -expected-tps 解析后的结果
+var expectedThroughput map[string]float64
+
+func parseExpectedTPS(spec string) (map[string]float64, error) {
+	expected := make(map[string]float64)
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		// 模型名本身可能带 : 但不会带 =
+		model, value, ok := strings.Cut(item, "=")
+		tps, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil || tps <= 0 || strings.TrimSpace(model) == "" {
+			return nil, fmt.Errorf(msg("bad_expected_tps"), item)
+		}
+		expected[strings.TrimSpace(model)] = tps
+	}
+	return expected, nil
+}
+
+// applyExpectedTPS 计算实际吞吐相对预期的效率, 没有预期值或服务端未报告 token 数时跳过
+func applyExpectedTPS(r *TestResult) {
+	expected, ok := expectedThroughput[r.Model]
+	if !ok || r.TokensPerSecond == 0 {
+		return
+	}
+	r.ExpectedTPS = expected
+	r.EfficiencyPct = r.TokensPerSecond / expected * 100
+	r.Underperforming = r.EfficiencyPct < *efficiencyThreshold
+}
+
+// printEfficiency 输出实际与预期吞吐的对比
+func printEfficiency(results []TestResult) {
+	if len(expectedThroughput) == 0 {
+		return
+	}
+
+	fmt.Printf(msg("efficiency_title"), *efficiencyThreshold)
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("efficiency_header"))
+	for _, r := range results {
+		if r.ExpectedTPS == 0 {
+			continue
+		}
+		mark := ""
+		if r.Underperforming {
+			mark = msg("underperforming")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%.0f%%\t%s\t\n",
+			r.Backend, r.Model, r.Concurrency, r.TokensPerSecond, r.ExpectedTPS, r.EfficiencyPct, mark)
+	}
+	w.Flush()
+}
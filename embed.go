@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+)
+
+var (
+	embedModel       = flag.String("embed-model", "", "嵌入与生成混合负载测试 (模拟 RAG 服务): 对每个生成模型和 -concurrency 中的每个并发, 分别单独运行和与该嵌入模型的请求同时运行, 输出两种负载在争用下的延迟变化")
+	embedConcurrency = flag.Int("embed-concurrency", 4, "-embed-model 嵌入请求的并发数")
+	embedEndpoint    = flag.String("embed-endpoint", "", "嵌入接口地址, 为空时按后端推断 (ollama 为同一服务的 /api/embed, vllm 为 /v1/embeddings)")
+)
+
+// 混合负载测试中结果的负载类型
+const (
+	workloadGenerate = "generate"
+	workloadEmbed    = "embed"
+)
+
+// workloadName 返回负载类型在表格中的名称
+func workloadName(workload string) string {
+	if workload == workloadEmbed {
+		return msg("workload_embed")
+	}
+	return msg("workload_generate")
+}
+
+// embedBackend 对应 ollama 的 /api/embed 或 OpenAI 兼容的 /v1/embeddings 接口, 两者都以 input 传入文本
+type embedBackend struct {
+	name     string
+	endpoint string
+	openAI   bool
+}
+
+func (b embedBackend) Name() string     { return b.name }
+func (b embedBackend) Endpoint() string { return b.endpoint }
+
+func (b embedBackend) RequestBody(model, prompt string) map[string]interface{} {
+	return map[string]interface{}{"model": model, "input": prompt}
+}
+
+// BatchRequestBody 两种接口的 input 都可以是字符串数组
+func (b embedBackend) BatchRequestBody(model string, prompts []string) map[string]interface{} {
+	return map[string]interface{}{"model": model, "input": prompts}
+}
+
+// ResponseText 返回响应中的向量列表, 没有向量时返回空字符串, 计为空响应
+func (b embedBackend) ResponseText(response map[string]interface{}) interface{} {
+	key := "embeddings"
+	if b.openAI {
+		key = "data"
+	}
+	vectors, ok := response[key].([]interface{})
+	if !ok || len(vectors) == 0 {
+		return ""
+	}
+	return vectors
+}
+
+func (b embedBackend) ErrorMessage(body []byte) string {
+	if b.openAI {
+		return openAIBackend{}.ErrorMessage(body)
+	}
+	return ollamaBackend{}.ErrorMessage(body)
+}
+
+// CompletionTokens 嵌入请求不生成 token
+func (b embedBackend) CompletionTokens(response map[string]interface{}) (int, bool) {
+	return 0, false
+}
+
+// newEmbedBackend 返回与生成后端同一服务的嵌入接口
+func newEmbedBackend(backend Backend) Backend {
+	b := embedBackend{name: backend.Name() + "-embed", endpoint: *embedEndpoint}
+	path := "/api/embed"
+	if _, ok := backend.(openAIBackend); ok {
+		b.openAI, path = true, "/v1/embeddings"
+	}
+	if b.endpoint == "" {
+		if u, err := url.Parse(backend.Endpoint()); err == nil {
+			u.Path, u.RawPath, u.RawQuery = path, "", ""
+			b.endpoint = u.String()
+		}
+	}
+	return b
+}
+
+// validateEmbedContention 检查 -embed-model 的参数; 嵌入请求由程序构造, 不能使用改写请求或响应的参数
+func validateEmbedContention() error {
+	if *embedModel == "" {
+		return nil
+	}
+	if *embedConcurrency < 1 {
+		return errors.New(msg("bad_embed_concurrency"))
+	}
+	if *complexityMode != "" || *comparePrompts || *soakMode || *slaP95 > 0 || *modelSwitch || *mixSpec != "" || *predictSweep != "" {
+		return errors.New(msg("embed_conflict"))
+	}
+	if *bodyTemplate != "" || *requestPath != "" || *streamMode || *imagesDir != "" || *responseFormat != "json" {
+		return errors.New(msg("embed_request_conflict"))
+	}
+	return nil
+}
+
+// runEmbedContention 对每个后端先单独测一次嵌入负载, 再对每个生成模型和并发分别单独运行生成负载、
+// 以及让生成和嵌入负载同时运行, 以单独运行的结果为基准计算争用带来的延迟增加
+func runEmbedContention(backends []Backend, models []string, concurrencies []int, meta runMetadata) int {
+	var results []TestResult
+	record := func(r TestResult, workload string, contended bool) {
+		r.Workload, r.Contended = workload, contended
+		applyExpectedTPS(&r)
+		results = append(results, r)
+		notifyCell(r)
+	}
+
+contention:
+	for _, backend := range backends {
+		if shutdownCtx.Err() != nil {
+			break
+		}
+		embedder := newEmbedBackend(backend)
+		fmt.Printf(msg("embed_testing_alone"), embedder.Name(), *embedModel, *embedConcurrency)
+		record(runTest(embedder, *embedModel, *embedConcurrency), workloadEmbed, false)
+		coolDown()
+
+		for _, model := range models {
+			for _, concurrency := range concurrencies {
+				if shutdownCtx.Err() != nil {
+					break contention
+				}
+				fmt.Printf(msg("testing"), backend.Name(), model, concurrency)
+				record(runTest(backend, model, concurrency), workloadGenerate, false)
+				coolDown()
+
+				if shutdownCtx.Err() != nil {
+					break contention
+				}
+				fmt.Printf(msg("embed_testing_mixed"), backend.Name(), model, concurrency, *embedModel, *embedConcurrency)
+				var generated, embedded TestResult
+				var wg sync.WaitGroup
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					generated = runTest(backend, model, concurrency)
+				}()
+				go func() {
+					defer wg.Done()
+					embedded = runTest(embedder, *embedModel, *embedConcurrency)
+				}()
+				wg.Wait()
+				record(generated, workloadGenerate, true)
+				record(embedded, workloadEmbed, true)
+				coolDown()
+			}
+		}
+	}
+
+	if err := writeResults(results, nil, meta); err != nil {
+		fmt.Println(msg("output_error"), err)
+		return exitOutput
+	}
+	if shutdownCtx.Err() != nil {
+		return interruptedExitCode()
+	}
+	return exitCode(results)
+}
+
+// contentionResult 为混合负载中一种负载与其单独运行时的对比
+type contentionResult struct {
+	Backend      string  `json:"backend"`
+	Workload     string  `json:"workload"`
+	Model        string  `json:"model"`
+	Concurrency  int     `json:"concurrency"`
+	SharedWith   string  `json:"shared_with"` // 同时运行的另一种负载, 如 deepseek-r1:7b×4
+	AloneAvgMs   float64 `json:"alone_avg_ms"`
+	MixedAvgMs   float64 `json:"mixed_avg_ms"`
+	AloneP95Ms   float64 `json:"alone_p95_ms"`
+	MixedP95Ms   float64 `json:"mixed_p95_ms"`
+	AloneRPS     float64 `json:"alone_rps"`
+	MixedRPS     float64 `json:"mixed_rps"`
+	SlowdownPct  float64 `json:"slowdown_pct"` // 混合负载使平均响应增加的比例(%)
+	MixedSuccess float64 `json:"mixed_success_rate"`
+	NoBaseline   bool    `json:"no_baseline,omitempty"` // 单独运行的一组没有成功请求, 无法对比
+}
+
+// embedContention 将每个争用下的结果与同一负载单独运行的结果配对; 混合组按 生成, 嵌入 的顺序成对出现
+func embedContention(results []TestResult) []contentionResult {
+	if *embedModel == "" {
+		return nil
+	}
+	type aloneKey struct {
+		backend, model string
+		concurrency    int
+	}
+	alone := make(map[aloneKey]TestResult)
+	for _, r := range results {
+		if r.Workload != "" && !r.Contended {
+			alone[aloneKey{r.Backend, r.Model, r.Concurrency}] = r
+		}
+	}
+
+	var rows []contentionResult
+	for i := 0; i+1 < len(results); i++ {
+		gen, emb := results[i], results[i+1]
+		if !gen.Contended || gen.Workload != workloadGenerate || !emb.Contended || emb.Workload != workloadEmbed {
+			continue
+		}
+		for _, pair := range [][2]TestResult{{gen, emb}, {emb, gen}} {
+			r, other := pair[0], pair[1]
+			row := contentionResult{
+				Backend: r.Backend, Workload: r.Workload, Model: r.Model, Concurrency: r.Concurrency,
+				SharedWith: fmt.Sprintf("%s×%d", other.Model, other.Concurrency),
+				MixedAvgMs: r.AvgResponseTime, MixedP95Ms: r.P95ResponseTime,
+				MixedRPS: requestsPerSecond(r), MixedSuccess: r.SuccessRate,
+			}
+			base, ok := alone[aloneKey{r.Backend, r.Model, r.Concurrency}]
+			if !ok || base.Successes == 0 {
+				row.NoBaseline = true
+			} else {
+				row.AloneAvgMs, row.AloneP95Ms, row.AloneRPS = base.AvgResponseTime, base.P95ResponseTime, requestsPerSecond(base)
+				if base.AvgResponseTime > 0 {
+					row.SlowdownPct = (r.AvgResponseTime - base.AvgResponseTime) / base.AvgResponseTime * 100
+				}
+			}
+			rows = append(rows, row)
+		}
+		i++
+	}
+	return rows
+}
+
+// printEmbedContention 输出每种负载单独运行和混合运行时的延迟与吞吐对比
+func printEmbedContention(results []TestResult) {
+	rows := embedContention(results)
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println(msg("embed_title"))
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("embed_header"))
+	for _, r := range rows {
+		slowdown := "-"
+		if !r.NoBaseline {
+			slowdown = fmt.Sprintf("%+.1f", r.SlowdownPct)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%.1f\t%.1f\t%s\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			r.Backend, workloadName(r.Workload), r.Model, r.Concurrency, r.SharedWith,
+			r.AloneAvgMs, r.MixedAvgMs, slowdown, r.AloneP95Ms, r.MixedP95Ms, r.AloneRPS, r.MixedRPS)
+	}
+	w.Flush()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errorLogFile = flag.String("error-log", "", "将每个失败请求的时间、后端、模型、并发、并发编号、提示词编号和错误逐行追加到该文件, 标准输出不再打印失败请求; 运行结束时在文件末尾追加各类错误的次数")
+
+// errorLogger 将失败请求写入 -error-log, 并按错误类型计数; 可在多个协程中并发调用
+type errorLogger struct {
+	mu     sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	counts map[string]int
+	err    error // 第一个写入错误
+	closed bool
+}
+
+// errorLog 为当前的错误日志, 未设置 -error-log 时为 nil
+var errorLog *errorLogger
+
+// setupErrorLog 在设置了 -error-log 时以追加方式打开文件
+func setupErrorLog() error {
+	if *errorLogFile == "" {
+		return nil
+	}
+	f, err := os.OpenFile(*errorLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	errorLog = &errorLogger{f: f, w: bufio.NewWriter(f), counts: make(map[string]int)}
+	fmt.Fprintf(errorLog.w, "# %s run started, %s\n", time.Now().Format(time.RFC3339), strings.Join(redactArgs(os.Args), " "))
+	fmt.Printf(msg("error_log_enabled"), *errorLogFile)
+	return nil
+}
+
+// errorKind 将错误归类, 与结果中的各项错误计数一致; 其他非 200 响应按状态码归类
+func errorKind(err error, status int) string {
+	switch {
+	case isConnectionError(err):
+		return "connection"
+	case errors.Is(err, errResponseTooLarge):
+		return "oversized"
+	case isOOMError(err):
+		return "oom"
+	case isTimeoutError(err):
+		return "timeout"
+	case status != 0 && status != 200:
+		return "http_" + strconv.Itoa(status)
+	}
+	return "other"
+}
+
+// log 记录一个失败的请求
+func (l *errorLogger) log(backend Backend, model string, concurrency int, at time.Time, picked []int, outcome requestOutcome, err error) {
+	kind := errorKind(err, outcome.status)
+	ids := make([]string, len(picked))
+	for i, id := range picked {
+		ids[i] = strconv.Itoa(id)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	l.counts[kind]++
+	_, werr := fmt.Fprintf(l.w, "%s backend=%s model=%s concurrency=%d worker=%d prompts=%s kind=%s error=%q\n",
+		at.Format(time.RFC3339Nano), backend.Name(), model, concurrency, outcome.worker, strings.Join(ids, ","), kind, err.Error())
+	if l.err == nil {
+		l.err = werr
+	}
+}
+
+// Close 在文件末尾追加各类错误的次数并关闭文件, 写入失败时输出警告; 重复调用时不做任何事
+func (l *errorLogger) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	l.closed = true
+	kinds := make([]string, 0, len(l.counts))
+	total := 0
+	for k, n := range l.counts {
+		kinds = append(kinds, k)
+		total += n
+	}
+	// 按次数从多到少排列, 次数相同时按名称
+	slices.SortFunc(kinds, func(a, b string) int {
+		if l.counts[a] != l.counts[b] {
+			return l.counts[b] - l.counts[a]
+		}
+		return strings.Compare(a, b)
+	})
+	fmt.Fprintf(l.w, "# summary: %d failed requests\n", total)
+	for _, k := range kinds {
+		fmt.Fprintf(l.w, "#   %s: %d\n", k, l.counts[k])
+	}
+
+	err := l.w.Flush()
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	if l.err != nil {
+		err = l.err
+	}
+	if err != nil {
+		fmt.Println(msg("error_log_failed"), err)
+		return
+	}
+	if total > 0 {
+		fmt.Printf(msg("error_log_summary"), total, *errorLogFile)
+	}
+}
+
+// closeErrorLog 在输出结果前写入错误汇总; 只会生效一次
+func closeErrorLog() {
+	if errorLog != nil {
+		errorLog.Close()
+		errorLog = nil
+	}
+}
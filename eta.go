@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// etaTracker 根据已完成测试组的实际耗时 (含预热、测试、冷却) 估算剩余时间;
+// 不同模型的耗时可能差别很大 (如 -min-samples 延长), 有该模型的记录时按该模型的平均值估算
+type etaTracker struct {
+	remaining map[string]int // 各模型剩余的组数
+	spent     map[string]time.Duration
+	finished  map[string]int
+	last      time.Time // 上一组结束的时间
+}
+
+func newETATracker(remaining map[string]int) *etaTracker {
+	return &etaTracker{
+		remaining: remaining,
+		spent:     make(map[string]time.Duration),
+		finished:  make(map[string]int),
+		last:      time.Now(),
+	}
+}
+
+// done 记录一组测试完成, 耗时为距离上一组结束的时间
+func (e *etaTracker) done(model string) {
+	now := time.Now()
+	e.spent[model] += now.Sub(e.last)
+	e.finished[model]++
+	e.remaining[model]--
+	e.last = now
+}
+
+// skip 记录一组测试未运行而被跳过, 不计入耗时
+func (e *etaTracker) skip(model string) {
+	e.remaining[model]--
+	e.last = time.Now()
+}
+
+// cellEstimate 返回该模型一组测试的预计耗时: 有该模型的记录时取其平均值, 否则取所有组的平均值
+func (e *etaTracker) cellEstimate(model string) time.Duration {
+	if e.finished[model] > 0 {
+		return e.spent[model] / time.Duration(e.finished[model])
+	}
+	var total time.Duration
+	count := 0
+	for _, d := range e.spent {
+		total += d
+	}
+	for _, n := range e.finished {
+		count += n
+	}
+	if count > 0 {
+		return total / time.Duration(count)
+	}
+	return testDuration + *cooldownModel
+}
+
+// estimate 返回剩余的组数和预计剩余时间
+func (e *etaTracker) estimate() (int, time.Duration) {
+	cells, eta := 0, time.Duration(0)
+	for model, n := range e.remaining {
+		if n <= 0 {
+			continue
+		}
+		cells += n
+		eta += time.Duration(n) * e.cellEstimate(model)
+	}
+	return cells, eta
+}
+
+// reportETA 在每组测试结束后输出整体进度和预计完成时间
+func reportETA(e *etaTracker, total int) {
+	left, eta := e.estimate()
+	if left == 0 {
+		return
+	}
+	eta = eta.Round(time.Second)
+	finish := time.Now().Add(eta).Format("15:04:05")
+	if dash != nil {
+		dash.setETA(fmt.Sprintf(msg("tui_eta"), eta, finish))
+		return
+	}
+	fmt.Printf(msg("eta"), total-left, total, eta, finish)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var fanOut = flag.Int("fan-out", 0, "扇出模式 (模拟并行调用工具的智能体): 每个并发每轮同时发出 N 个请求, 全部完成后才开始下一轮, 除逐个请求的延迟外统计每轮以最慢请求为准的完成耗时; 进行中的请求数为 -concurrency 乘以 N; 0 或 1 表示不启用")
+
+// validateFanOut 检查 -fan-out; 扇出需要按并发分轮发送, 不能与 -inflight 同时使用
+func validateFanOut() error {
+	if *fanOut < 0 {
+		return errors.New(msg("bad_fan_out"))
+	}
+	if *fanOut > 1 && *inflightMode {
+		return errors.New(msg("fan_out_inflight"))
+	}
+	return nil
+}
+
+// fanOutRequests 同时调用 n 次 send 并等待全部完成, 返回整轮耗时; 任一请求因中断未完成时 ok 为 false,
+// err 为第一个失败请求的错误
+func fanOutRequests(n int, send func(j int) (bool, error)) (took time.Duration, ok bool, err error) {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done = true
+	)
+	start := time.Now()
+	for j := 0; j < n; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sent, sendErr := send(j)
+			mu.Lock()
+			defer mu.Unlock()
+			done = done && sent
+			if sendErr != nil && err == nil {
+				err = sendErr
+			}
+		}()
+	}
+	wg.Wait()
+	return time.Since(start), done, err
+}
+
+// recordFanOut 累计一轮扇出的结果, 有请求失败的轮次只计数, 不计入完成耗时
+func (s *cellStats) recordFanOut(took time.Duration, err error) {
+	if err != nil {
+		s.fanOutFailed++
+		return
+	}
+	s.fanOutTimes = append(s.fanOutTimes, took)
+}
+
+// printFanOut 输出 -fan-out 时逐个请求与每轮扇出的延迟对比
+func printFanOut(results []TestResult) {
+	if *fanOut <= 1 || len(results) == 0 {
+		return
+	}
+	fmt.Printf(msg("fan_out_title"), *fanOut)
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("fan_out_header"))
+	for _, r := range results {
+		amplification := "-"
+		if r.AvgResponseTime > 0 && r.FanOuts > 0 {
+			amplification = fmt.Sprintf("%.2f", r.FanOutAvgMs/r.AvgResponseTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%s\t\n",
+			r.Backend, modelCell(r), r.Concurrency, r.AvgResponseTime, r.P95ResponseTime,
+			r.FanOuts, r.FanOutFailed, r.FanOutAvgMs, r.FanOutP50Ms, r.FanOutP95Ms, r.FanOutP99Ms, amplification)
+	}
+	w.Flush()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	onlyCells = flag.String("only", "", "只运行匹配的测试组, 条件用逗号连接表示同时满足, 多组条件用分号分隔, 如 model=deepseek-r1:7b,concurrency=4;backend=vllm")
+	skipCells = flag.String("skip", "", "跳过匹配的测试组, 语法同 -only")
+)
+
+// cellPredicate 为一组同时满足的条件, 空字段表示不限制
+type cellPredicate struct {
+	backend     string
+	model       string
+	concurrency int
+}
+
+func (p cellPredicate) match(backend, model string, concurrency int) bool {
+	return (p.backend == "" || p.backend == backend) &&
+		(p.model == "" || p.model == model) &&
+		(p.concurrency == 0 || p.concurrency == concurrency)
+}
+
+// cellFilter 为 -only 和 -skip 解析后的结果
+type cellFilter struct {
+	only []cellPredicate
+	skip []cellPredicate
+}
+
+// cells 为当前生效的测试组过滤条件
+var cells cellFilter
+
+// selected 判断一组测试是否需要运行: 没有 -only 或匹配任一 -only, 且不匹配任何 -skip
+func (f cellFilter) selected(backend, model string, concurrency int) bool {
+	for _, p := range f.skip {
+		if p.match(backend, model, concurrency) {
+			return false
+		}
+	}
+	if len(f.only) == 0 {
+		return true
+	}
+	for _, p := range f.only {
+		if p.match(backend, model, concurrency) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCellFilter(only, skip string) (cellFilter, error) {
+	var f cellFilter
+	var err error
+	if f.only, err = parseCellPredicates(only); err != nil {
+		return f, err
+	}
+	f.skip, err = parseCellPredicates(skip)
+	return f, err
+}
+
+func parseCellPredicates(spec string) ([]cellPredicate, error) {
+	var preds []cellPredicate
+	for _, group := range strings.Split(spec, ";") {
+		if strings.TrimSpace(group) == "" {
+			continue
+		}
+
+		var p cellPredicate
+		for _, cond := range strings.Split(group, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(cond), "=")
+			value = strings.TrimSpace(value)
+			if !ok || value == "" {
+				return nil, fmt.Errorf(msg("bad_cell_filter"), cond)
+			}
+
+			switch strings.TrimSpace(key) {
+			case "backend":
+				p.backend = value
+			case "model":
+				p.model = value
+			case "concurrency":
+				n, err := strconv.Atoi(value)
+				if err != nil || n <= 0 {
+					return nil, fmt.Errorf(msg("bad_cell_filter"), cond)
+				}
+				p.concurrency = n
+			default:
+				return nil, fmt.Errorf(msg("bad_cell_filter"), cond)
+			}
+		}
+		preds = append(preds, p)
+	}
+	return preds, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+// GC 停顿明显影响测量的阈值: 最长一次停顿超过平均响应的 5%, 或停顿总时长超过测试时长的 1%
+const (
+	gcMaxPauseRatio   = 0.05
+	gcTotalPauseRatio = 0.01
+)
+
+// clientGC 为一组测试期间压测端自身的内存和 GC 统计, 用于区分客户端造成的延迟和服务端的性能
+type clientGC struct {
+	NumGC        uint32  `json:"num_gc"`
+	PauseTotalMs float64 `json:"pause_total_ms"`
+	MaxPauseMs   float64 `json:"max_pause_ms"`   // 期间最长的一次停顿 (runtime 只保留最近 256 次)
+	HeapAllocMB  float64 `json:"heap_alloc_mb"`  // 测试结束时的堆大小
+	HeapDeltaMB  float64 `json:"heap_delta_mb"`  // 测试期间堆大小的变化
+	TotalAllocMB float64 `json:"total_alloc_mb"` // 测试期间累计分配的内存
+}
+
+func readMemStats() runtime.MemStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m
+}
+
+// gcDelta 计算 before 和 after 两次采样之间的 GC 统计
+func gcDelta(before, after runtime.MemStats) *clientGC {
+	const mb = 1024 * 1024
+	g := &clientGC{
+		NumGC:        after.NumGC - before.NumGC,
+		PauseTotalMs: float64(after.PauseTotalNs-before.PauseTotalNs) / 1e6,
+		HeapAllocMB:  float64(after.HeapAlloc) / mb,
+		HeapDeltaMB:  (float64(after.HeapAlloc) - float64(before.HeapAlloc)) / mb,
+		TotalAllocMB: float64(after.TotalAlloc-before.TotalAlloc) / mb,
+	}
+	// PauseNs 为环形缓冲, 第 n 次 GC 的停顿在 (n+255)%256
+	n := min(g.NumGC, uint32(len(after.PauseNs)))
+	for i := uint32(0); i < n; i++ {
+		pause := after.PauseNs[(after.NumGC-i+255)%uint32(len(after.PauseNs))]
+		g.MaxPauseMs = max(g.MaxPauseMs, float64(pause)/1e6)
+	}
+	return g
+}
+
+// warnClientGC 在压测端 GC 停顿相对响应时间不可忽略时输出警告
+func warnClientGC(r TestResult, elapsed time.Duration) {
+	g := r.ClientGC
+	if g == nil || g.NumGC == 0 {
+		return
+	}
+	if g.MaxPauseMs > r.AvgResponseTime*gcMaxPauseRatio || g.PauseTotalMs > elapsed.Seconds()*1000*gcTotalPauseRatio {
+		fmt.Printf(msg("client_gc_warning"), r.Backend, r.Model, r.Concurrency, g.NumGC, g.PauseTotalMs, g.MaxPauseMs, r.AvgResponseTime)
+	}
+}
+
+// printClientGC 在 -verbose 时输出每组测试期间压测端的 GC 次数、停顿和堆内存变化
+func printClientGC(results []TestResult) {
+	if len(results) == 0 {
+		return
+	}
+	fmt.Println(msg("client_gc_title"))
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("client_gc_header"))
+	for _, r := range results {
+		g := r.ClientGC
+		if g == nil {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.2f\t%.3f\t%.1f\t%+.1f\t%.1f\t\n",
+			r.Backend, modelCell(r), r.Concurrency, g.NumGC, g.PauseTotalMs, g.MaxPauseMs, g.HeapAllocMB, g.HeapDeltaMB, g.TotalAllocMB)
+	}
+	w.Flush()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	verbose          = flag.Bool("verbose", false, "输出更详细的信息, 如每组测试的响应时间分布直方图")
+	histogramBuckets = flag.String("histogram-buckets", "auto", "响应时间直方图的分桶上界(ms), 逗号分隔, 如 100,500,1000; auto 表示在最小和最大值之间等分")
+)
+
+// autoBucketCount 为自动分桶时的桶数
+const autoBucketCount = 10
+
+// histogramBounds 为解析后的分桶上界, nil 表示自动分桶
+var histogramBounds []float64
+
+// histogramBucket 为直方图中的一个桶, 统计 [LowerMs, UpperMs) 内的请求数, 最后一个桶包含上界
+type histogramBucket struct {
+	LowerMs float64 `json:"lower_ms"`
+	UpperMs float64 `json:"upper_ms"`
+	Count   int     `json:"count"`
+}
+
+func parseHistogramBuckets(spec string) ([]float64, error) {
+	if spec == "auto" || spec == "" {
+		return nil, nil
+	}
+
+	var bounds []float64
+	for _, item := range strings.Split(spec, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf(msg("bad_histogram_buckets"), spec)
+		}
+		bounds = append(bounds, v)
+	}
+	sort.Float64s(bounds)
+	return bounds, nil
+}
+
+// buildHistogram 统计响应时间分布; 指定了分桶上界时, 超过最后一个上界的请求归入 [上界, 最大值] 的桶
+func buildHistogram(durations []time.Duration, bounds []float64) []histogramBucket {
+	if len(durations) == 0 {
+		return nil
+	}
+
+	values := make([]float64, len(durations))
+	for i, d := range durations {
+		values[i] = d.Seconds() * 1000
+	}
+	sort.Float64s(values)
+	lo, hi := values[0], values[len(values)-1]
+
+	var buckets []histogramBucket
+	if bounds == nil {
+		width := (hi - lo) / autoBucketCount
+		if width == 0 {
+			return []histogramBucket{{LowerMs: lo, UpperMs: hi, Count: len(values)}}
+		}
+		for i := 0; i < autoBucketCount; i++ {
+			buckets = append(buckets, histogramBucket{LowerMs: lo + width*float64(i), UpperMs: lo + width*float64(i+1)})
+		}
+	} else {
+		lower := 0.0
+		for _, b := range bounds {
+			buckets = append(buckets, histogramBucket{LowerMs: lower, UpperMs: b})
+			lower = b
+		}
+		if hi >= lower {
+			buckets = append(buckets, histogramBucket{LowerMs: lower, UpperMs: hi})
+		}
+	}
+
+	for _, v := range values {
+		i := sort.Search(len(buckets), func(i int) bool { return v < buckets[i].UpperMs })
+		if i == len(buckets) {
+			i-- // 等于最大值的请求归入最后一个桶
+		}
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+// fprintHistogram 以 ASCII 条形图输出直方图
+func fprintHistogram(w io.Writer, buckets []histogramBucket) {
+	const width = 40
+
+	peak := 0
+	for _, b := range buckets {
+		peak = max(peak, b.Count)
+	}
+	if peak == 0 {
+		return
+	}
+
+	for _, b := range buckets {
+		n := b.Count * width / peak
+		if b.Count > 0 && n == 0 {
+			n = 1
+		}
+		fmt.Fprintf(w, "  %9.1f - %9.1f ms | %-*s %d\n", b.LowerMs, b.UpperMs, width, strings.Repeat("#", n), b.Count)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var imagesDir = flag.String("images-dir", "", "多模态模型测试: 每个请求随机附带该目录中的一张图片 (png/jpg/jpeg/webp/gif, ollama 的 images 字段), 并按图片统计平均响应")
+
+// requestImage 为 -images-dir 中的一张图片
+type requestImage struct {
+	name   string
+	bytes  int64
+	base64 string
+}
+
+// images 为 -images-dir 加载的图片, 为空时请求不带图片
+var images []requestImage
+
+// imageBackend 由支持在请求中附带图片的后端实现
+type imageBackend interface {
+	AttachImages(body map[string]interface{}, images []string)
+}
+
+// AttachImages 使用 /api/generate 顶层的 images 字段
+func (b ollamaBackend) AttachImages(body map[string]interface{}, images []string) {
+	body["images"] = images
+}
+
+// AttachImages 将图片附加到最后一条 (用户) 消息上
+func (b ollamaChatBackend) AttachImages(body map[string]interface{}, images []string) {
+	messages, ok := body["messages"].([]map[string]string)
+	if !ok || len(messages) == 0 {
+		return
+	}
+	withImages := make([]map[string]interface{}, len(messages))
+	for i, m := range messages {
+		withImages[i] = map[string]interface{}{"role": m["role"], "content": m["content"]}
+	}
+	withImages[len(withImages)-1]["images"] = images
+	body["messages"] = withImages
+}
+
+// imageExts 为 -images-dir 中会被加载的图片扩展名
+var imageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".webp": true, ".gif": true}
+
+// loadImages 读取目录中的图片并编码为 base64, 按文件大小排序
+func loadImages(dir string) ([]requestImage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var loaded []requestImage
+	for _, e := range entries {
+		if e.IsDir() || !imageExts[strings.ToLower(filepath.Ext(e.Name()))] {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, requestImage{
+			name:   e.Name(),
+			bytes:  int64(len(data)),
+			base64: base64.StdEncoding.EncodeToString(data),
+		})
+	}
+	if len(loaded) == 0 {
+		return nil, fmt.Errorf(msg("no_images"), dir)
+	}
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].bytes < loaded[j].bytes })
+	return loaded, nil
+}
+
+// validateImages 检查 -images-dir 只用于支持图片的后端, 且每个请求只有一条提示词
+func validateImages(backends []Backend) error {
+	if *imagesDir == "" {
+		return nil
+	}
+	if *bodyTemplate != "" || *batchSize > 1 {
+		return errors.New(msg("images_conflict"))
+	}
+	for _, b := range backends {
+		if _, ok := b.(imageBackend); !ok {
+			return fmt.Errorf(msg("images_unsupported"), b.Name())
+		}
+	}
+	return nil
+}
+
+// pickImage 随机选择一张图片, 没有加载图片时返回 nil
+func pickImage() *requestImage {
+	if len(images) == 0 {
+		return nil
+	}
+	return &images[rand.Intn(len(images))]
+}
+
+// imageLatency 为一组测试中附带某张图片的请求的统计
+type imageLatency struct {
+	Image    string  `json:"image"`
+	Bytes    int64   `json:"bytes"`
+	Requests int     `json:"requests"` // 成功的请求数
+	AvgMs    float64 `json:"avg_ms"`
+}
+
+// buildImageLatency 按图片大小从小到大汇总各图片的平均响应
+func buildImageLatency(times map[string][]time.Duration) []imageLatency {
+	if len(times) == 0 {
+		return nil
+	}
+	var stats []imageLatency
+	for _, img := range images {
+		ds := times[img.name]
+		if len(ds) == 0 {
+			continue
+		}
+		avg, _, _ := calculateStats(ds)
+		stats = append(stats, imageLatency{Image: img.name, Bytes: img.bytes, Requests: len(ds), AvgMs: avg})
+	}
+	return stats
+}
+
+// printImageLatency 在 -images-dir 时输出每组测试中各图片的平均响应, 观察图片大小对延迟的影响
+func printImageLatency(results []TestResult) {
+	if *imagesDir == "" {
+		return
+	}
+	for _, r := range results {
+		if len(r.ImageLatency) == 0 {
+			continue
+		}
+		fmt.Printf(msg("image_title"), r.Backend, r.Model, r.Concurrency)
+		w := newTableWriter(os.Stdout)
+		fmt.Fprintln(w, msg("image_header"))
+		for _, img := range r.ImageLatency {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t\n", img.Image, formatBytes(img.Bytes), img.Requests, img.AvgMs)
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInflightKeepsConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	active, peak := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		peak = max(peak, active)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"response": "好", "eval_count": 1})
+	}))
+	defer srv.Close()
+
+	savedInflight, savedQuiet, savedMax := *inflightMode, *quietMode, *maxRequests
+	*inflightMode, *quietMode, *maxRequests = true, true, 40
+	t.Cleanup(func() { *inflightMode, *quietMode, *maxRequests = savedInflight, savedQuiet, savedMax })
+
+	r := runTestFor(ollamaBackend{endpoint: srv.URL}, "m", 4, 10*time.Second, 0, nil)
+	if r.Requests != 40 || r.Successes != 40 {
+		t.Errorf("runTestFor() sent %d requests, %d succeeded, want 40", r.Requests, r.Successes)
+	}
+	if peak != 4 {
+		t.Errorf("peak in-flight requests = %d, want 4", peak)
+	}
+}
+
+func TestValidateInflight(t *testing.T) {
+	savedInflight, savedPerWorker, savedFailures := *inflightMode, *perWorker, *workerMaxFailures
+	t.Cleanup(func() {
+		*inflightMode, *perWorker, *workerMaxFailures = savedInflight, savedPerWorker, savedFailures
+	})
+
+	tests := []struct {
+		inflight, perWorker bool
+		failures            int
+		wantErr             bool
+	}{
+		{inflight: false, perWorker: true, failures: 3},
+		{inflight: true},
+		{inflight: true, perWorker: true, wantErr: true},
+		{inflight: true, failures: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		*inflightMode, *perWorker, *workerMaxFailures = tt.inflight, tt.perWorker, tt.failures
+		if err := validateInflight(); (err != nil) != tt.wantErr {
+			t.Errorf("validateInflight() with -inflight=%v -per-worker=%v -worker-max-failures=%d: error = %v, wantErr %v",
+				tt.inflight, tt.perWorker, tt.failures, err, tt.wantErr)
+		}
+	}
+}
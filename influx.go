@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	influxURL   = flag.String("influx-url", "", "测试结束后将结果以 InfluxDB 行协议推送到该写入地址, 如 http://localhost:8086/api/v2/write?org=o&bucket=b&precision=ns")
+	influxToken = flag.String("influx-token", "", "推送到 InfluxDB 时使用的 API token")
+)
+
+const (
+	influxMeasurement = "model_test"
+	// influxBatchLines 为每次写入请求包含的最大行数
+	influxBatchLines = 5000
+)
+
+// influxTagEscaper 转义标签值中行协议的分隔符; : 和 / 在标签值中是合法的, 模型名等标签保留原样而不用 sanitizeModelName,
+// 因为后者会把 a:b 和 a/b 变成同一个值, 两组结果就成了同一个 series, 会互相覆盖
+var influxTagEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// influxLines 将结果转换为 InfluxDB 行协议, 每组测试一行, -background-concurrency 时后台请求单独一行;
+// 时间戳为这组测试的结束时间, 旧的结果文件中没有时使用运行结束时间
+func influxLines(results []TestResult, meta runMetadata) []string {
+	lines := make([]string, 0, len(results))
+	for _, r := range results {
+		lines = append(lines, influxLine(r, meta, r.Workload))
+		if r.Background != nil {
+			lines = append(lines, influxLine(*r.Background, meta, "background"))
+		}
+	}
+	return lines
+}
+
+// influxLine 返回一组结果的一行; 同一 series 同一时间戳的点会互相覆盖, 因此区分各组的字段都作为标签,
+// 为空的标签省略
+func influxLine(r TestResult, meta runMetadata, workload string) string {
+	ts := r.FinishedAt
+	if ts.IsZero() {
+		ts = meta.FinishedAt
+	}
+	tags := fmt.Sprintf("%s,backend=%s,model=%s,concurrency=%d,host=%s",
+		influxMeasurement,
+		influxTagEscaper.Replace(r.Backend),
+		influxTagEscaper.Replace(r.Model),
+		r.Concurrency,
+		influxTagEscaper.Replace(meta.Hostname))
+	for _, tag := range [][2]string{{"execution_mode", r.ExecutionMode}, {"tier", r.Tier}, {"mix", r.Mix}, {"workload", workload}} {
+		if tag[1] != "" {
+			tags += "," + tag[0] + "=" + influxTagEscaper.Replace(tag[1])
+		}
+	}
+	fields := strings.Join([]string{
+		"avg_response_ms=" + influxFloat(r.AvgResponseTime),
+		"max_response_ms=" + influxFloat(r.MaxResponseTime),
+		"min_response_ms=" + influxFloat(r.MinResponseTime),
+		"success_rate=" + influxFloat(r.SuccessRate),
+		"tokens_per_second=" + influxFloat(r.TokensPerSecond),
+		"items_per_second=" + influxFloat(r.ItemsPerSecond),
+		"requests=" + strconv.Itoa(r.Requests) + "i",
+		"gpu_load=" + influxFloat(r.GPULoad),
+		"gpu_memory_used_mb=" + influxFloat(r.GPUMemoryUsed),
+		"cpu_load=" + influxFloat(r.CPULoad),
+		"cpu_cores_busy=" + strconv.Itoa(r.CPUCoresBusy) + "i",
+		"cpu_hottest_core=" + influxFloat(r.CPUHottestCore),
+	}, ",")
+	if r.GPUMemoryPercent > 0 {
+		fields += ",gpu_memory_percent=" + influxFloat(r.GPUMemoryPercent)
+	}
+	for _, p := range reportedPercentiles {
+		label := percentileLabel(p)
+		if v, ok := r.Percentiles[label]; ok {
+			fields += "," + label + "_response_ms=" + influxFloat(v)
+		}
+	}
+	return fmt.Sprintf("%s %s %d", tags, fields, ts.UnixNano())
+}
+
+func influxFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func writeInflux(out io.Writer, results []TestResult, meta runMetadata) error {
+	for _, line := range influxLines(results, meta) {
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushInflux 分批将结果写入 -influx-url
+func pushInflux(results []TestResult, meta runMetadata) error {
+	lines := influxLines(results, meta)
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	for start := 0; start < len(lines); start += influxBatchLines {
+		end := min(start+influxBatchLines, len(lines))
+		body := strings.Join(lines[start:end], "\n") + "\n"
+
+		req, err := http.NewRequest(http.MethodPost, *influxURL, bytes.NewBufferString(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		if *influxToken != "" {
+			req.Header.Set("Authorization", "Token "+*influxToken)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		// InfluxDB 写入成功时返回 204
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf(msg("status_error_msg"), resp.StatusCode, strings.TrimSpace(string(data)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+// Package monitor 定期采样本机的 CPU、内存、GPU 负载和显存占用
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// Metrics 为一次资源采样的结果
+type Metrics struct {
+	Time          time.Time `json:"time"`
+	CPULoad       float64   `json:"cpu_load"`
+	GPULoad       float64   `json:"gpu_load"`
+	GPUMemoryUsed float64   `json:"gpu_memory_used_mb"`
+	MemoryUsed    float64   `json:"memory_used"`
+	// CPUCores 为每个逻辑核心的利用率(%), 下标为核心编号
+	CPUCores []float64 `json:"cpu_cores,omitempty"`
+	// Custom 为通过 Register 注册的自定义采集器的结果, 以采集器给出的名称为键
+	Custom map[string]float64 `json:"custom,omitempty"`
+	// Missing 为本次采样失败的指标名称, 对应字段为 0 但不代表实际占用为 0
+	Missing []string `json:"missing,omitempty"`
+}
+
+// 内置采集器的名称, 对应 Metrics 中的固定字段
+const (
+	NameCPULoad       = "cpu_load"
+	NameMemoryUsed    = "memory_used"
+	NameGPULoad       = "gpu_load"
+	NameGPUMemoryUsed = "gpu_memory_used_mb"
+	NameCPUCores      = "cpu_cores"
+)
+
+// Collector 为一项资源指标的采集器, 每次采样调用一次 Sample;
+// 本次无法采集时 value 返回 NaN, 该项会被跳过
+type Collector interface {
+	Sample() (name string, value float64)
+}
+
+// CollectorFunc 让普通函数可以作为 Collector 使用
+type CollectorFunc func() (string, float64)
+
+func (f CollectorFunc) Sample() (string, float64) { return f() }
+
+// set 将一项采样写入对应字段, 非内置名称写入 Custom
+func (m *Metrics) set(name string, value float64) {
+	switch name {
+	case NameCPULoad:
+		m.CPULoad = value
+	case NameMemoryUsed:
+		m.MemoryUsed = value
+	case NameGPULoad:
+		m.GPULoad = value
+	case NameGPUMemoryUsed:
+		m.GPUMemoryUsed = value
+	default:
+		if m.Custom == nil {
+			m.Custom = make(map[string]float64)
+		}
+		m.Custom[name] = value
+	}
+}
+
+// Monitor 按固定间隔采样资源占用
+type Monitor struct {
+	interval   time.Duration
+	collectors []Collector
+	// sampleMu 保证同时运行多个 Start 时每轮采样不交错, GPU 负载和显存共用一次查询的结果
+	sampleMu sync.Mutex
+
+	// OnUnavailable 在某项指标第一次采样失败时调用 (每项只调用一次), 可用于输出警告
+	OnUnavailable func(name string)
+	warnMu        sync.Mutex
+	warned        map[string]bool
+
+	// QueryGPU 执行 nvidia-smi 查询并返回其输出, 测试时可替换为假实现
+	QueryGPU func(args ...string) ([]byte, error)
+}
+
+// New 创建一个每隔 interval 采样一次的 Monitor
+func New(interval time.Duration) *Monitor {
+	m := &Monitor{
+		interval: interval,
+		QueryGPU: nvidiaSMI,
+	}
+	// GPU 负载和显存来自同一次 nvidia-smi 查询: 采集器按顺序调用, 负载采集器查询后把显存留给下一个采集器
+	gpuMem := math.NaN()
+	m.collectors = []Collector{
+		CollectorFunc(cpuLoad),
+		CollectorFunc(memoryUsed),
+		CollectorFunc(func() (string, float64) {
+			util, used, err := m.GPUInfo()
+			if err != nil {
+				gpuMem = math.NaN()
+				return NameGPULoad, math.NaN()
+			}
+			gpuMem = used
+			return NameGPULoad, util
+		}),
+		CollectorFunc(func() (string, float64) { return NameGPUMemoryUsed, gpuMem }),
+	}
+	return m
+}
+
+// Interval 返回计划的采样间隔
+func (m *Monitor) Interval() time.Duration {
+	return m.interval
+}
+
+// Register 添加一个自定义采集器, 需在 Start 之前调用
+func (m *Monitor) Register(c Collector) {
+	m.collectors = append(m.collectors, c)
+}
+
+func cpuLoad() (string, float64) {
+	percent, err := cpu.Percent(0, false)
+	if err != nil || len(percent) == 0 {
+		return NameCPULoad, math.NaN()
+	}
+	return NameCPULoad, percent[0]
+}
+
+// cpuCores 返回自上次调用以来每个逻辑核心的利用率; gopsutil 对整体和逐核分别保存上次的 CPU 时间, 与 cpuLoad 互不影响
+func cpuCores() ([]float64, error) {
+	percent, err := cpu.Percent(0, true)
+	if err == nil && len(percent) == 0 {
+		err = fmt.Errorf("no per-core CPU data")
+	}
+	return percent, err
+}
+
+func memoryUsed() (string, float64) {
+	info, err := mem.VirtualMemory()
+	if err != nil {
+		return NameMemoryUsed, math.NaN()
+	}
+	return NameMemoryUsed, info.UsedPercent
+}
+
+func nvidiaSMI(args ...string) ([]byte, error) {
+	return exec.Command("nvidia-smi", args...).Output()
+}
+
+// Start 开始采样, ctx 结束后停止并关闭返回的 channel
+func (m *Monitor) Start(ctx context.Context) <-chan Metrics {
+	metricsChan := make(chan Metrics)
+	go func() {
+		defer close(metricsChan)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				// 记录实际开始采样的时间而不是计划时间, CPU 被占满导致采样协程调度延迟时可以据此发现
+				metrics := Metrics{Time: time.Now()}
+				m.sampleMu.Lock()
+				for _, c := range m.collectors {
+					name, value := c.Sample()
+					if math.IsNaN(value) {
+						metrics.Missing = append(metrics.Missing, name)
+						m.unavailable(name)
+						continue
+					}
+					metrics.set(name, value)
+				}
+				if cores, err := cpuCores(); err == nil {
+					metrics.CPUCores = cores
+				} else {
+					metrics.Missing = append(metrics.Missing, NameCPUCores)
+					m.unavailable(NameCPUCores)
+				}
+				m.sampleMu.Unlock()
+				// 接收方可能已经不再读取, 发送时同样要响应取消, 否则协程会一直阻塞
+				select {
+				case metricsChan <- metrics:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return metricsChan
+}
+
+func (m *Monitor) unavailable(name string) {
+	m.warnMu.Lock()
+	defer m.warnMu.Unlock()
+	if m.warned[name] {
+		return
+	}
+	if m.warned == nil {
+		m.warned = make(map[string]bool)
+	}
+	m.warned[name] = true
+	if m.OnUnavailable != nil {
+		m.OnUnavailable(name)
+	}
+}
+
+// GPUInfo 返回 GPU 利用率(%) 和已用显存(MB), 多卡时只取第一张卡
+func (m *Monitor) GPUInfo() (float64, float64, error) {
+	output, err := m.QueryGPU("--query-gpu=utilization.gpu,memory.used", "--format=csv,noheader,nounits")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	line := strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)[0]
+	fields := strings.Split(line, ",")
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("invalid GPU data")
+	}
+
+	// 不支持的指标 nvidia-smi 输出 [N/A], 不能当作 0
+	util, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid GPU utilization data: %w", err)
+	}
+	mem, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid GPU memory data: %w", err)
+	}
+
+	return util, mem, nil
+}
+
+// GPUTotalMemory 返回显卡总显存(MB)
+func (m *Monitor) GPUTotalMemory() (float64, error) {
+	output, err := m.QueryGPU("--query-gpu=memory.total", "--format=csv,noheader,nounits")
+	if err != nil {
+		return 0, err
+	}
+
+	// 多卡时只取第一张卡, 与 GPUInfo 的已用显存对应
+	line := strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)[0]
+	total, err := strconv.ParseFloat(strings.TrimSpace(line), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid GPU memory data: %w", err)
+	}
+
+	return total, nil
+}
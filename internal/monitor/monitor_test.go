@@ -0,0 +1,237 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGPU 按顺序返回预设的 nvidia-smi 输出, 用完后重复最后一个
+type fakeGPU struct {
+	mu      sync.Mutex
+	outputs []string
+	err     error
+	calls   int
+}
+
+func (f *fakeGPU) query(args ...string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	out := f.outputs[min(f.calls, len(f.outputs))-1]
+	return []byte(out), nil
+}
+
+// collect 读取 n 个采样后停止 Monitor
+func collect(t *testing.T, m *Monitor, n int) []Metrics {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := m.Start(ctx)
+	var samples []Metrics
+	timeout := time.After(5 * time.Second)
+	for len(samples) < n {
+		select {
+		case s := <-ch:
+			samples = append(samples, s)
+		case <-timeout:
+			t.Fatalf("got %d samples, want %d", len(samples), n)
+		}
+	}
+	return samples
+}
+
+func TestGPUInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		err      error
+		wantUtil float64
+		wantMem  float64
+		wantErr  bool
+	}{
+		{name: "normal", output: "45, 2048\n", wantUtil: 45, wantMem: 2048},
+		{name: "no spaces", output: "100,81920", wantUtil: 100, wantMem: 81920},
+		{name: "two GPUs", output: "45, 2048\n90, 70000\n", wantUtil: 45, wantMem: 2048},
+		{name: "garbage", output: "No devices were found\n", wantErr: true},
+		{name: "not supported", output: "[N/A], 2048\n", wantErr: true},
+		{name: "command failed", err: errors.New("exec: \"nvidia-smi\": executable file not found"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(time.Second)
+			m.QueryGPU = (&fakeGPU{outputs: []string{tt.output}, err: tt.err}).query
+			util, mem, err := m.GPUInfo()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GPUInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (util != tt.wantUtil || mem != tt.wantMem) {
+				t.Errorf("GPUInfo() = %v, %v, want %v, %v", util, mem, tt.wantUtil, tt.wantMem)
+			}
+		})
+	}
+}
+
+func TestGPUTotalMemory(t *testing.T) {
+	m := New(time.Second)
+	// 多卡时只取第一张
+	m.QueryGPU = (&fakeGPU{outputs: []string{"24576\n81920\n"}}).query
+	total, err := m.GPUTotalMemory()
+	if err != nil || total != 24576 {
+		t.Errorf("GPUTotalMemory() = %v, %v, want 24576, nil", total, err)
+	}
+
+	m.QueryGPU = (&fakeGPU{outputs: []string{"[N/A]\n"}}).query
+	if _, err := m.GPUTotalMemory(); err == nil {
+		t.Error("GPUTotalMemory() with [N/A] returned no error")
+	}
+}
+
+func TestStartSamplesGPU(t *testing.T) {
+	gpu := &fakeGPU{outputs: []string{"10, 1000", "90, 3000", "50, 2000"}}
+	m := New(5 * time.Millisecond)
+	m.QueryGPU = gpu.query
+
+	samples := collect(t, m, 3)
+	wantLoad := []float64{10, 90, 50}
+	wantMem := []float64{1000, 3000, 2000}
+	for i, s := range samples {
+		// 负载和显存来自同一次查询, 必须属于同一行输出
+		if s.GPULoad != wantLoad[i] || s.GPUMemoryUsed != wantMem[i] {
+			t.Errorf("sample %d: GPU = %v%%, %vMB, want %v%%, %vMB", i, s.GPULoad, s.GPUMemoryUsed, wantLoad[i], wantMem[i])
+		}
+		if slices.Contains(s.Missing, NameGPULoad) || slices.Contains(s.Missing, NameGPUMemoryUsed) {
+			t.Errorf("sample %d: GPU metrics reported missing: %v", i, s.Missing)
+		}
+		if s.Time.IsZero() {
+			t.Errorf("sample %d: no timestamp", i)
+		}
+	}
+	for i := 1; i < len(samples); i++ {
+		if !samples[i].Time.After(samples[i-1].Time) {
+			t.Errorf("sample %d is not later than sample %d", i, i-1)
+		}
+	}
+}
+
+func TestStartGPUUnavailable(t *testing.T) {
+	m := New(5 * time.Millisecond)
+	m.QueryGPU = (&fakeGPU{err: errors.New("nvidia-smi not found")}).query
+	var mu sync.Mutex
+	warned := make(map[string]int)
+	m.OnUnavailable = func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		warned[name]++
+	}
+
+	for i, s := range collect(t, m, 3) {
+		if !slices.Contains(s.Missing, NameGPULoad) || !slices.Contains(s.Missing, NameGPUMemoryUsed) {
+			t.Errorf("sample %d: Missing = %v, want both GPU metrics", i, s.Missing)
+		}
+		if s.GPULoad != 0 || s.GPUMemoryUsed != 0 {
+			t.Errorf("sample %d: GPU = %v, %v, want zero values", i, s.GPULoad, s.GPUMemoryUsed)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range []string{NameGPULoad, NameGPUMemoryUsed} {
+		if warned[name] != 1 {
+			t.Errorf("OnUnavailable(%q) called %d times, want 1", name, warned[name])
+		}
+	}
+}
+
+func TestRegisterCollector(t *testing.T) {
+	m := New(5 * time.Millisecond)
+	m.QueryGPU = (&fakeGPU{outputs: []string{"0, 0"}}).query
+	n := 0
+	m.Register(CollectorFunc(func() (string, float64) {
+		n++
+		if n == 2 {
+			return "npu_load", math.NaN()
+		}
+		return "npu_load", float64(n * 10)
+	}))
+
+	samples := collect(t, m, 3)
+	if v, ok := samples[0].Custom["npu_load"]; !ok || v != 10 {
+		t.Errorf("sample 0: npu_load = %v, %v, want 10", v, ok)
+	}
+	if _, ok := samples[1].Custom["npu_load"]; ok || !slices.Contains(samples[1].Missing, "npu_load") {
+		t.Errorf("sample 1: NaN sample not reported missing: %v, %v", samples[1].Custom, samples[1].Missing)
+	}
+	if v := samples[2].Custom["npu_load"]; v != 30 {
+		t.Errorf("sample 2: npu_load = %v, want 30", v)
+	}
+}
+
+func TestStartStopsOnCancel(t *testing.T) {
+	m := New(5 * time.Millisecond)
+	m.QueryGPU = (&fakeGPU{outputs: []string{"1, 1"}}).query
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := m.Start(ctx)
+	<-ch
+	cancel()
+
+	// 取消后最多还能收到一个已经采集好的样本, 之后 channel 必须关闭
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("channel not closed after cancel")
+		}
+	}
+}
+
+func TestStartNoGoroutineLeak(t *testing.T) {
+	m := New(time.Millisecond)
+	m.QueryGPU = (&fakeGPU{outputs: []string{"1, 1"}}).query
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 50; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := m.Start(ctx)
+		if i%2 == 0 {
+			<-ch
+		}
+		// 接收方不再读取: 采样协程阻塞在发送上, 取消后也必须退出
+		time.Sleep(3 * time.Millisecond)
+		cancel()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		after := runtime.NumGoroutine()
+		if after <= before {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines before %d, after %d: monitor goroutines leaked", before, after)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func ExampleMonitor_Start() {
+	m := New(10 * time.Millisecond)
+	m.QueryGPU = func(args ...string) ([]byte, error) { return []byte("37, 4096\n"), nil }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := <-m.Start(ctx)
+	fmt.Printf("GPU %.0f%%, %.0fMB\n", s.GPULoad, s.GPUMemoryUsed)
+	// Output: GPU 37%, 4096MB
+}
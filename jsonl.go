@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	jsonlFile  = flag.String("jsonl", "", "每完成一组测试就将结果追加到该文件 (JSON Lines, 首行为运行配置), 中断后可用 -resume 继续")
+	resumeFile = flag.String("resume", "", "读取 -jsonl 写入的文件, 跳过其中已完成的测试组, 新的结果继续追加到该文件; 运行配置需与文件首行一致")
+)
+
+// unsavedSettings 为不影响测试结果的参数, 续跑时允许与原来不同
+var unsavedSettings = map[string]bool{
+	"jsonl": true, "resume": true, "validate": true,
+	"output": true, "output-file": true, "quiet": true, "live": true, "tui": true, "lang": true, "verbose": true,
+	"note": true, "only": true, "skip": true, "influx-url": true, "influx-token": true, "shutdown-grace": true, "webhook": true,
+	"error-log": true, "trace-file": true, "output-dir": true, "serve": true, "serve-token": true,
+}
+
+// jsonlHeader 为 JSON Lines 结果文件的首行, 之后每行是一个 TestResult
+type jsonlHeader struct {
+	SchemaVersion int               `json:"schema_version"`
+	Metadata      runMetadata       `json:"metadata"`
+	Settings      map[string]string `json:"settings"` // 影响测试结果的参数 (含环境变量设置的值)
+}
+
+// resultKey 唯一确定一组测试结果, 用于续跑、合并去重和各种对比中的配对
+type resultKey struct {
+	backend     string
+	model       string
+	concurrency int
+	mode        string // 执行方式, -compare-against-cpu、-compare-keepalive 时各执行方式分别是一组
+	tier        string
+	mix         string
+	workload    string
+}
+
+// keyOf 返回一组结果的 resultKey
+func keyOf(r TestResult) resultKey {
+	return resultKey{r.Backend, r.Model, r.Concurrency, r.ExecutionMode, r.Tier, r.Mix, r.Workload}
+}
+
+// resumed 为 -resume 时文件中已完成的测试组
+var resumed map[resultKey]TestResult
+
+// cellPending 判断一组测试以某种执行方式是否需要运行: 被 -only/-skip 选中且不是续跑时已完成的组
+func cellPending(backend, model string, concurrency int, mode string) bool {
+	_, done := resumed[resultKey{backend: backend, model: model, concurrency: concurrency, mode: mode}]
+	return cells.selected(backend, model, concurrency) && !done
+}
+
+// resultLog 在每组测试完成后追加一行结果
+type resultLog struct {
+	f *os.File
+}
+
+// currentSettings 返回当前影响测试结果的参数值; 密钥等敏感参数不写入文件
+func currentSettings() map[string]string {
+	settings := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		if !unsavedSettings[f.Name] && !isSensitiveName(f.Name) {
+			settings[f.Name] = f.Value.String()
+		}
+	})
+	return settings
+}
+
+// settingsMismatch 列出与文件记录不一致的参数
+func settingsMismatch(saved, current map[string]string) error {
+	var diffs []string
+	for name, v := range current {
+		if old, ok := saved[name]; ok && old != v {
+			diffs = append(diffs, fmt.Sprintf("-%s: %q -> %q", name, old, v))
+		}
+	}
+	if len(diffs) == 0 {
+		return nil
+	}
+	sort.Strings(diffs)
+	return fmt.Errorf(msg("resume_mismatch"), strings.Join(diffs, ", "))
+}
+
+// loadResume 读取 -resume 文件, 返回首行配置、已完成的结果以及最后一个完整行之后的偏移量;
+// 中断时最后一行可能只写了一半, 这一行会被忽略并在续写时覆盖
+func loadResume(path string) (jsonlHeader, []TestResult, int64, error) {
+	var header jsonlHeader
+	var results []TestResult
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return header, nil, 0, err
+	}
+
+	var offset int64
+	r := bufio.NewReader(bytes.NewReader(data))
+	for n := 0; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// 没有换行结尾的行是中断时未写完的
+			break
+		}
+		var decodeErr error
+		if n == 0 {
+			decodeErr = json.Unmarshal(line, &header)
+		} else {
+			var result TestResult
+			decodeErr = json.Unmarshal(line, &result)
+			results = append(results, result)
+		}
+		if decodeErr != nil {
+			return header, nil, 0, fmt.Errorf("%s:%d: %w", path, n+1, decodeErr)
+		}
+		offset += int64(len(line))
+	}
+
+	if offset == 0 {
+		return header, nil, 0, fmt.Errorf(msg("resume_empty"), path)
+	}
+	if header.SchemaVersion != resultsSchemaVersion {
+		return header, nil, 0, fmt.Errorf(msg("schema_mismatch"), path, header.SchemaVersion, resultsSchemaVersion)
+	}
+	return header, results, offset, nil
+}
+
+// setupResume 处理 -resume: 校验配置、记录已完成的组, 并沿用原来的开始时间和运行标记
+func setupResume(meta *runMetadata) (int64, error) {
+	header, results, offset, err := loadResume(*resumeFile)
+	if err != nil {
+		return 0, err
+	}
+	if err := settingsMismatch(header.Settings, currentSettings()); err != nil {
+		return 0, err
+	}
+
+	resumed = make(map[resultKey]TestResult, len(results))
+	for _, r := range results {
+		resumed[keyOf(r)] = r
+	}
+	meta.StartedAt, meta.RunID = header.Metadata.StartedAt, header.Metadata.RunID
+	return offset, nil
+}
+
+// resultFile 为当前的 -jsonl / -resume 结果文件, 未设置时为 nil
+var resultFile *resultLog
+
+// openResultLog 打开结果文件: 续跑时截掉未写完的行后追加, 否则新建文件并写入首行配置
+func openResultLog(path string, meta runMetadata, resumeOffset int64) (*resultLog, error) {
+	if resumeOffset > 0 {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.Truncate(resumeOffset); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if _, err := f.Seek(resumeOffset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &resultLog{f: f}, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	log := &resultLog{f: f}
+	header := jsonlHeader{SchemaVersion: resultsSchemaVersion, Metadata: meta, Settings: currentSettings()}
+	if err := log.write(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return log, nil
+}
+
+// Append 追加一组结果并立即落盘, 保证中断后已完成的组不会丢失
+func (l *resultLog) Append(r TestResult) error {
+	return l.write(r)
+}
+
+func (l *resultLog) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := l.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return l.f.Sync()
+}
+
+func (l *resultLog) Close() error {
+	return l.f.Close()
+}
+
+// validateResultLog 检查 -jsonl 与 -resume 的组合
+func validateResultLog() error {
+	if *resumeFile != "" && *jsonlFile != "" && *jsonlFile != *resumeFile {
+		return errors.New(msg("resume_jsonl_conflict"))
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestCurrentSettings(t *testing.T) {
+	savedServe, savedInflux, savedTrace := *serveToken, *influxToken, *traceFile
+	*serveToken, *influxToken, *traceFile = "s3cret", "t0ken", "/tmp/trace.jsonl"
+	t.Cleanup(func() { *serveToken, *influxToken, *traceFile = savedServe, savedInflux, savedTrace })
+
+	settings := currentSettings()
+	for _, name := range []string{"serve-token", "influx-token", "trace-file", "output-dir"} {
+		if v, ok := settings[name]; ok {
+			t.Errorf("currentSettings() saved -%s = %q, want it left out", name, v)
+		}
+	}
+	if _, ok := settings["concurrency"]; !ok {
+		t.Error("currentSettings() left out -concurrency")
+	}
+}
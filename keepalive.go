@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var (
+	noKeepAlive      = flag.Bool("no-keepalive", false, "不复用连接, 每个请求都重新建立 TCP (和 TLS) 连接, 模拟不做连接池的负载均衡; 连接建立的耗时计入响应时间")
+	compareKeepAlive = flag.Bool("compare-keepalive", false, "每组测试分别以复用连接和 -no-keepalive 各运行一次, 输出每个请求因重新建立连接增加的耗时")
+)
+
+// 执行方式, -compare-keepalive 时记录在 TestResult.ExecutionMode 中
+const (
+	modeKeepAlive   = "keepalive"
+	modeNoKeepAlive = "no-keepalive"
+)
+
+// freshConnections 为 true 时当前一组测试不复用连接, 由 -compare-keepalive 按执行方式切换
+var freshConnections bool
+
+// requestTransport 返回测试请求使用的 Transport, 复用连接时为 nil (http.DefaultTransport)
+func requestTransport() http.RoundTripper {
+	if !*noKeepAlive && !freshConnections {
+		return nil
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DisableKeepAlives = true
+	return t
+}
+
+// validateKeepAlive 检查 -compare-keepalive 不与 -no-keepalive 或 -compare-against-cpu 同时使用
+func validateKeepAlive() error {
+	if !*compareKeepAlive {
+		return nil
+	}
+	if *noKeepAlive {
+		return errors.New(msg("keepalive_conflict"))
+	}
+	if *compareCPU {
+		return errors.New(msg("keepalive_cpu_conflict"))
+	}
+	return nil
+}
+
+// printKeepAliveComparison 在 -compare-keepalive 时对比同一组测试复用连接和不复用连接的结果
+func printKeepAliveComparison(results []TestResult) {
+	if !*compareKeepAlive {
+		return
+	}
+	printModeComparison(results, modeKeepAlive, modeNoKeepAlive, msg("keepalive_title"), msg("keepalive_header"), func(w io.Writer, k, f TestResult) {
+		if k.Successes == 0 || f.Successes == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%+.1f\t%.1f\t%.1f\t%+.1f\t%.1f\t%.1f\t\n",
+			k.Backend, k.Model, k.Concurrency,
+			k.AvgResponseTime, f.AvgResponseTime, f.AvgResponseTime-k.AvgResponseTime,
+			k.P95ResponseTime, f.P95ResponseTime, f.P95ResponseTime-k.P95ResponseTime,
+			requestsPerSecond(k), requestsPerSecond(f))
+	})
+}
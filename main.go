@@ -2,247 +2,2369 @@ package main
 
 import (
 	"bytes"
+	"cmp"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"math/rand"
+	"io"
+	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
+	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"text/tabwriter"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/mem"
+	"model-test/internal/monitor"
 )
 
 type TestResult struct {
-	Model           string
-	Concurrency     int
-	CPULoad         float64
-	GPULoad         float64
-	GPUMemoryUsed   float64
-	MemoryUsed      float64
-	AvgResponseTime float64
-	MaxResponseTime float64
-	MinResponseTime float64
-	SuccessRate     float64
+	Backend               string                 `json:"backend"`
+	Model                 string                 `json:"model"`
+	Concurrency           int                    `json:"concurrency"`
+	CPULoad               float64                `json:"cpu_load"`
+	GPULoad               float64                `json:"gpu_load"`
+	GPUMemoryUsed         float64                `json:"gpu_memory_used_mb"`
+	GPUMemoryBaseline     float64                `json:"gpu_memory_baseline_mb,omitempty"` // 该模型第一组测试前 (冷却后) 的显存占用
+	GPUMemoryDelta        float64                `json:"gpu_memory_delta_mb,omitempty"`    // 峰值显存减去基线, 排除前一个模型残留的显存
+	GPUMemoryPercent      float64                `json:"gpu_memory_percent,omitempty"`     // 峰值显存占显卡总显存的百分比, 无法获取总显存时为 0
+	MemoryUsed            float64                `json:"memory_used"`
+	AvgResponseTime       float64                `json:"avg_response_ms"`
+	MaxResponseTime       float64                `json:"max_response_ms"`
+	MinResponseTime       float64                `json:"min_response_ms"`
+	P95ResponseTime       float64                `json:"p95_response_ms"`
+	Percentiles           map[string]float64     `json:"percentiles_ms,omitempty"`      // -percentiles 中各百分位的响应时间, 键如 p99.9
+	Mix                   string                 `json:"mix,omitempty"`                 // -mix 时所在的混合测试窗口, 同一窗口的各组同时运行
+	SharedResources       bool                   `json:"shared_resources,omitempty"`    // 资源占用 (CPU、GPU、显存、内存) 为整个 -mix 窗口共享的整机数值, 不能归到单个模型
+	Workload              string                 `json:"workload,omitempty"`            // -embed-model 时为 generate 或 embed
+	Contended             bool                   `json:"contended,omitempty"`           // -embed-model 时与另一种负载同时运行
+	FinishedAt            time.Time              `json:"finished_at,omitzero"`          // 这组测试 (或稳定性测试的这个时间段) 结束的时间
+	SampleDriftMaxMs      float64                `json:"sample_drift_max_ms,omitempty"` // 资源采样实际间隔与计划间隔的最大偏差
+	DriftedSamples        int                    `json:"drifted_samples,omitempty"`     // 偏差超过 -sample-drift-threshold 的采样间隔数
+	SuccessRate           float64                `json:"success_rate"`
+	Requests              int                    `json:"requests"`                          // 发出的请求数
+	Successes             int                    `json:"successes"`                         // 成功的请求数
+	BytesReceived         int64                  `json:"bytes_received"`                    // 接收的响应体字节数 (解压后)
+	CPUOffloaded          bool                   `json:"cpu_offloaded"`                     // 模型可能超出显存而部分卸载到 CPU
+	ConnErrors            int                    `json:"conn_errors"`                       // 无法连接到接口的请求数
+	OversizedCount        int                    `json:"oversized_count"`                   // 响应体超过 -max-response-bytes 的请求数
+	OOMErrors             int                    `json:"oom_errors"`                        // 服务端报告显存不足或 CUDA 错误的请求数
+	Timeouts              int                    `json:"timeouts"`                          // 超过 -timeout-per-request 的请求数
+	RequestTimeoutSec     float64                `json:"request_timeout_s"`                 // 该模型使用的请求超时时间
+	ErrorCounts           map[string]int         `json:"error_counts,omitempty"`            // 按错误信息统计的失败次数
+	BytesSaved            int64                  `json:"bytes_saved"`                       // -compress 开启时 gzip 节省的传输字节数
+	ColdStartMs           float64                `json:"cold_start_ms,omitempty"`           // -cold-start 测得的模型卸载后首个请求耗时
+	EmptyResponses        int                    `json:"empty_responses"`                   // 返回 200 但生成内容为空的请求数
+	SampleRequest         json.RawMessage        `json:"sample_request,omitempty"`          // 该组测试中有代表性的请求体, 已脱敏
+	BatchSize             int                    `json:"batch_size"`                        // 每个请求包含的提示词数
+	ItemResponseTime      float64                `json:"item_response_ms"`                  // 按提示词摊销的平均响应时间
+	ItemsPerSecond        float64                `json:"items_per_second"`                  // 每秒完成的提示词数
+	NumPredict            int                    `json:"num_predict,omitempty"`             // 请求的生成 token 上限, 0 表示不限制
+	AvgCompletionTokens   float64                `json:"avg_completion_tokens"`             // 服务端报告的平均生成 token 数 (eval_count)
+	EstimatedTokenSamples int                    `json:"estimated_token_samples,omitempty"` // 其中由 -estimate-tokens 在客户端估算 token 数的请求数
+	CappedRate            float64                `json:"capped_rate"`                       // 生成 token 数达到上限 (被截断) 的请求比例(%)
+	Run                   string                 `json:"run,omitempty"`                     // merge 合并后标记结果来自哪次运行
+	FirstError            string                 `json:"first_error,omitempty"`             // 第一个失败请求的错误信息
+	SchemaMismatches      int                    `json:"schema_mismatches,omitempty"`       // -strict-json 时结构与预期不符的成功响应数
+	SchemaError           string                 `json:"schema_error,omitempty"`            // 第一个结构不符的原因
+	ClientGC              *clientGC              `json:"client_gc,omitempty"`               // 该组测试期间压测端自身的 GC 和内存统计
+	ResponseTimesMs       []float64              `json:"response_times_ms,omitempty"`       // -output-dir 时单组文件中逐个成功请求的响应时间
+	ResourceSamples       []monitor.Metrics      `json:"resource_samples,omitempty"`        // -resource-samples 开启时的逐秒资源采样
+	EstQueueWaitMs        float64                `json:"est_queue_wait_ms"`                 // 估计的服务端排队等待时间, 见 estimateQueueWait
+	Histogram             []histogramBucket      `json:"histogram,omitempty"`               // 响应时间分布
+	Tier                  string                 `json:"tier,omitempty"`                    // -complexity 时的难度级别
+	TierAvgPromptChars    float64                `json:"tier_avg_prompt_chars,omitempty"`   // 该难度级别提示词的平均字符数
+	CustomMetrics         map[string]float64     `json:"custom_metrics,omitempty"`          // 自定义采集器 (monitor.Collector) 的峰值
+	SystemPromptTokens    int                    `json:"system_prompt_tokens,omitempty"`    // -system 的系统提示词带来的输入 token 数
+	ResponseMetadata      map[string]interface{} `json:"response_metadata,omitempty"`       // -response-metadata 时一个有代表性的原始响应, 已去掉生成的文本
+	UnavailableMetrics    []string               `json:"unavailable_metrics,omitempty"`     // 整组测试中都无法采集的资源指标, 对应字段的 0 无意义
+	AvgInFlight           float64                `json:"avg_in_flight"`                     // 实际达到的平均进行中请求数 (按时间加权)
+	ModelGroup            string                 `json:"model_group,omitempty"`             // 基础模型分组, 用于对比同一模型的不同量化版本
+	Quantization          string                 `json:"quantization,omitempty"`            // 模型标签中的量化后缀, 如 q4_K_M
+	TokensPerSecond       float64                `json:"tokens_per_second"`                 // 整组测试每秒生成的 token 数 (所有并发合计)
+	ExpectedTPS           float64                `json:"expected_tps,omitempty"`            // -expected-tps 给出的预期吞吐
+	EfficiencyPct         float64                `json:"efficiency_pct,omitempty"`          // 实际吞吐占预期的百分比
+	Underperforming       bool                   `json:"underperforming,omitempty"`         // 效率低于 -efficiency-threshold
+	WorkerAvgMs           []float64              `json:"worker_avg_ms,omitempty"`           // -per-worker 时每个并发的平均响应, 下标为并发编号
+	Stragglers            []int                  `json:"stragglers,omitempty"`              // 平均响应偏离中位数超过 -straggler-threshold 的并发编号
+	StoppedWorkers        int                    `json:"stopped_workers,omitempty"`         // 因 -worker-max-failures 停止的并发数
+	ExecutionMode         string                 `json:"execution_mode,omitempty"`          // -compare-against-cpu 时为 gpu 或 cpu (num_gpu: 0), -compare-keepalive 时为 keepalive 或 no-keepalive
+	ImageLatency          []imageLatency         `json:"image_latency,omitempty"`           // -images-dir 时各图片的平均响应, 按图片大小排序
+	SLABest               bool                   `json:"sla_best,omitempty"`                // -sla-p95 搜索中满足目标且吞吐最高的组
+	TTFTAvgMs             float64                `json:"ttft_avg_ms,omitempty"`             // -stream 时首 token 延迟的平均值
+	TTFTP95Ms             float64                `json:"ttft_p95_ms,omitempty"`             // -stream 时首 token 延迟的 p95
+	FanOut                int                    `json:"fan_out,omitempty"`                 // -fan-out 时每轮同时发出的请求数
+	FanOuts               int                    `json:"fan_outs,omitempty"`                // 全部请求都成功的扇出轮数
+	FanOutFailed          int                    `json:"fan_out_failed,omitempty"`          // 有请求失败的扇出轮数
+	FanOutAvgMs           float64                `json:"fan_out_avg_ms,omitempty"`          // 每轮扇出 (最慢的请求完成) 的平均耗时
+	FanOutP50Ms           float64                `json:"fan_out_p50_ms,omitempty"`
+	FanOutP95Ms           float64                `json:"fan_out_p95_ms,omitempty"`
+	FanOutP99Ms           float64                `json:"fan_out_p99_ms,omitempty"`
+	ITLAvgMs              float64                `json:"itl_avg_ms,omitempty"`             // -stream 时 token 间隔的平均值
+	ITLP95Ms              float64                `json:"itl_p95_ms,omitempty"`             // -stream 时 token 间隔的 p95, 明显高于平均值说明输出不流畅
+	Background            *TestResult            `json:"background,omitempty"`             // -background-concurrency 时后台批量请求的统计
+	ForegroundBaselineMs  float64                `json:"foreground_baseline_ms,omitempty"` // 没有后台负载时前台请求的平均响应
+	BackgroundImpactPct   float64                `json:"background_impact_pct,omitempty"`  // 后台负载使前台平均响应增加的比例(%)
+	DurationSec           float64                `json:"duration_s"`                       // 该组测试实际运行的时长, -min-samples / -max-ci-pct 可能使其长于默认值
+	WarmupAvgMs           float64                `json:"warmup_avg_ms,omitempty"`          // -warmup-split 时前 N 个请求的平均响应
+	SteadyAvgMs           float64                `json:"steady_avg_ms,omitempty"`          // -warmup-split 时其余请求的平均响应
+	WarmupDurationSec     float64                `json:"warmup_duration_s,omitempty"`      // -warmup-until-stable 时正式测试前的预热时长
+	DatasetPrompts        int                    `json:"dataset_prompts,omitempty"`        // -dataset 中的提示词总数
+	CacheCheck            *cacheCheckResult      `json:"cache_check,omitempty"`            // -cache-check 的结果, 同一 后端+模型 的各组相同
+	CPUCoresBusy          int                    `json:"cpu_cores_busy"`                   // 平均利用率超过 -core-busy-threshold 的 CPU 核心数
+	CPUHottestCore        float64                `json:"cpu_hottest_core"`                 // 最忙核心的平均利用率(%)
+	CPUCoreAvg            []float64              `json:"cpu_core_avg,omitempty"`           // -per-core-cpu 时各核心的平均利用率(%)
+	UniquePrompts         int                    `json:"unique_prompts,omitempty"`         // -dataset 时该组测试实际用到的不同提示词数, 时间段快照中不统计
 }
 
-type ResourceMetrics struct {
-	CPULoad       float64
-	GPULoad       float64
-	GPUMemoryUsed float64
-	MemoryUsed    float64
+const (
+	testDuration   = 30 * time.Second
+	apiEndpoint    = "http://localhost:11434/api/generate"
+	requestTimeout = 60 * time.Second // -timeout-per-request 的默认值
+
+	// 显存估算: Ollama 默认 q4 量化下每十亿参数约占 600MB, 另加上下文等固定开销
+	vramPerBillionParamsMB = 600
+	vramOverheadMB         = 500
+	// 峰值显存达到总显存的该比例时, 认为模型已塞满显卡并溢出到 CPU
+	vramFullRatio = 0.95
+)
+
+// 进程退出码, 便于在 CI 中判断测试是否健康
+const (
+	exitOK          = 0   // 所有测试均有成功的请求
+	exitCellFailed  = 1   // 至少一组测试成功率为 0, 或 -strict-json 时出现结构不符的响应
+	exitUnreachable = 2   // 接口无法连接
+	exitUsage       = 3   // 命令行参数错误
+	exitOutput      = 4   // 结果写入失败
+	exitStrict      = 5   // -strict 模式下出现失败请求
+	exitNoResults   = 6   // 没有任何测试组的结果 (全部被过滤、跳过或无法运行)
+	exitBelowTarget = 7   // 至少一组测试的吞吐低于 -expected-tps 的 -efficiency-threshold
+	exitInterrupted = 130 // 被 Ctrl-C 中断, 已输出部分结果
+	exitTerminated  = 143 // 收到 SIGTERM (如 k8s 删除 Pod), 已输出部分结果
+)
+
+var maxResponseBytes = flag.Int64("max-response-bytes", 0, "单个响应体的最大字节数, 超出则计为超大响应 (0 表示不限制)")
+
+var (
+	soakMode        = flag.Bool("soak", false, "长时间稳定性测试: 以固定并发持续运行单个模型, 并定期输出快照")
+	soakModel       = flag.String("soak-model", "deepseek-r1:7b", "稳定性测试使用的模型")
+	soakConcurrency = flag.Int("soak-concurrency", 1, "稳定性测试使用的并发数")
+	soakDuration    = flag.Duration("soak-duration", 2*time.Hour, "稳定性测试总时长")
+	soakInterval    = flag.Duration("soak-interval", 5*time.Minute, "稳定性测试快照间隔")
+)
+
+var (
+	liveStatus   = flag.Bool("live", false, "测试过程中每秒输出一行实时状态 (RPS、滑动窗口平均响应、成功率)")
+	quietMode    = flag.Bool("quiet", false, "不输出每个请求的日志")
+	includeEmpty = flag.Bool("include-empty", false, "空响应的耗时也计入响应时间统计 (默认排除, 避免拉低平均值)")
+	compress     = flag.Bool("compress", false, "使用 gzip 压缩请求体并接受 gzip 响应 (需服务端支持), 统计节省的传输字节数")
+)
+
+var modelList = flag.String("models", "deepseek-r1:1.5b,deepseek-r1:7b,deepseek-r1:8b,deepseek-r1:14b,deepseek-r1:32b", "要测试的模型, 逗号分隔; 可写成 分组=模型 指定基础模型分组, 默认按去掉量化后缀 (如 -q4_K_M) 后的名称分组")
+
+var concurrencySpec = flag.String("concurrency", "1,2,3,4,5,6", "并发数列表, 支持逗号分隔、范围和步长: 1,2,4,8 或 1..32:2 (步长加 2) 或 1..32:*2 (每次乘 2); 展开后最多 1000 个")
+
+// 压测端与推理服务在同一台机器上时, 压测端的调度会与服务争抢 CPU, 可用以下参数限制压测端的 CPU 占用
+var (
+	goMaxProcs  = flag.Int("gomaxprocs", 0, "限制压测端使用的 CPU 数 (GOMAXPROCS), 0 表示使用 Go 默认值")
+	cpuAffinity = flag.String("cpu-affinity", "", "将压测端绑定到指定 CPU, 如 0-3,8, 编号最大为 1023 (仅 Linux)")
+)
+
+var (
+	strictMode  = flag.Bool("strict", false, "出现第一个失败请求时立即停止并以非零退出码退出, 适合配合 -max-requests 1 做健康检查")
+	maxRequests = flag.Int("max-requests", 0, "每组测试最多发送的请求数, 达到后提前结束该组, 0 表示不限制")
+)
+
+var inflightMode = flag.Bool("inflight", false, "由调度循环维持 -concurrency 个进行中的请求, 每完成一个立即补发 (默认每个并发各自循环发送); 不能与 -fan-out、-per-worker 和 -worker-max-failures 同时使用")
+
+var fixedPromptSequence = flag.Bool("fixed-prompt-sequence", false, "每个并发按顺序轮流使用提示词 (默认随机选择), 保证不同机器、不同运行的负载完全相同")
+
+var responseMetadata = flag.Bool("response-metadata", false, "在 JSON 结果中保留每组测试第一个成功响应的完整元数据 (如 ollama 的 total_duration、load_duration、prompt_eval_count), 不含生成的文本")
+
+var resourceSamples = flag.Bool("resource-samples", false, "在 JSON 结果中输出每组测试带时间戳的逐秒资源采样 (会显著增大输出)")
+
+var batchSize = flag.Int("batch-size", 1, "每个请求包含的提示词数, 大于 1 时需要后端支持批量请求 (如 vllm)")
+
+// 样本太少时统计结果不可靠, 以下参数让每组测试在结束时按需延长
+var (
+	minSamples = flag.Int("min-samples", 0, "每组测试至少需要的响应时间样本数, 不足时延长测试 (最长到 -max-cell-duration), 0 表示不检查")
+	maxCIPct   = flag.Float64("max-ci-pct", 0, "平均响应 95% 置信区间半宽占平均值的最大百分比, 超过时延长测试, 0 表示不检查")
+	maxCellDur = flag.Duration("max-cell-duration", 5*time.Minute, "-min-samples / -max-ci-pct 延长测试时每组的最长时长")
+)
+
+var (
+	perWorker          = flag.Bool("per-worker", false, "分别统计每个并发 (C-0, C-1...) 的平均响应, 找出明显偏慢或偏快的并发")
+	stragglerThreshold = flag.Float64("straggler-threshold", 0.5, "-per-worker 时平均响应偏离所有并发中位数超过该比例即标记为异常")
+	workerMaxFailures  = flag.Int("worker-max-failures", 0, "单个并发连续失败 N 次后停止该并发, 避免一条坏掉的连接刷高失败数 (不能与 -inflight 同时使用), 0 表示不停止")
+)
+
+var warmupSplit = flag.Int("warmup-split", 0, "将每组测试最先完成的 N 个成功请求计为预热, 分别统计预热和稳定阶段的平均响应, 0 表示不区分")
+
+var validateOnly = flag.Bool("validate", false, "只检查参数、环境变量、提示词文件和接口地址, 一次列出所有错误, 没有错误时输出 OK 并退出, 不运行测试")
+
+var coldStart = flag.Bool("cold-start", false, "每个模型测试前先卸载模型 (keep_alive: 0), 单独测量冷启动请求耗时, 不计入常规统计")
+
+var (
+	betweenCmd        = flag.String("between-cmd", "", "每组测试之间的冷却期内执行的命令 (通过 sh -c 或 cmd /C 运行), 失败只警告不中断")
+	betweenCmdTimeout = flag.Duration("between-cmd-timeout", time.Minute, "-between-cmd 的超时时间")
+	monitorCooldown   = flag.Bool("monitor-cooldown", false, "冷却期内继续每秒采集显存占用并输出释放曲线, 用于判断冷却时间是否足够")
+	cooldownConc      = flag.Duration("cooldown-concurrency", 10*time.Second, "同一模型已加载时, 两组测试 (不同并发数) 之间的冷却时间")
+	cooldownModel     = flag.Duration("cooldown-model", 10*time.Second, "切换模型 (或 -compare-against-cpu 切换执行方式) 前的冷却时间, 等待前一个模型释放显存; 其他测试模式的组间冷却也使用该值")
+)
+
+// validateCooldown 检查冷却时间不为负数
+func validateCooldown() error {
+	if *cooldownConc < 0 || *cooldownModel < 0 {
+		return errors.New(msg("bad_cooldown"))
+	}
+	return nil
+}
+
+// validateInflight 检查 -inflight 不与按并发统计或停止并发的参数同时使用: 调度循环中的槽位只是空出的编号,
+// 并不是固定的连接或循环
+func validateInflight() error {
+	if !*inflightMode {
+		return nil
+	}
+	if *perWorker {
+		return errors.New(msg("per_worker_inflight"))
+	}
+	if *workerMaxFailures > 0 {
+		return errors.New(msg("worker_max_failures_inflight"))
+	}
+	return nil
+}
+
+// specialMode 返回启用的专项测试模式的参数名, 未启用时返回空字符串; 这些模式各自组织测试并直接退出,
+// 不经过按模型、后端和并发展开的测试组循环
+func specialMode() string {
+	switch {
+	case *soakMode:
+		return "-soak"
+	case *modelSwitch:
+		return "-model-switch"
+	case *mixSpec != "":
+		return "-mix"
+	case *complexityMode != "":
+		return "-complexity"
+	case *comparePrompts:
+		return "-compare-prompts"
+	case *slaP95 > 0:
+		return "-sla-p95"
+	case *predictSweep != "":
+		return "-predict-sweep"
+	case *embedModel != "":
+		return "-embed-model"
+	}
+	return ""
+}
+
+// validateSpecialMode 检查专项测试模式没有与只作用于测试组循环的参数 (-only/-skip、-jsonl/-resume) 同时使用
+func validateSpecialMode() error {
+	mode := specialMode()
+	if mode == "" {
+		return nil
+	}
+	if *onlyCells != "" || *skipCells != "" {
+		return fmt.Errorf(msg("cell_filter_special_mode"), mode)
+	}
+	if *jsonlFile != "" || *resumeFile != "" {
+		return fmt.Errorf(msg("result_log_special_mode"), mode)
+	}
+	// 模型切换的结果是切换代价而不是吞吐, 没有可对比的对象
+	if *modelSwitch && *expectedTPS != "" {
+		return errors.New(msg("expected_tps_model_switch"))
+	}
+	return nil
+}
+
+// liveWindow 为实时状态的滑动窗口长度
+const liveWindow = 10 * time.Second
+
+var errResponseTooLarge error = localizedError("response_too_big")
+
+var shutdownGrace = flag.Duration("shutdown-grace", 20*time.Second, "收到 Ctrl-C 或 SIGTERM 后等待输出已完成结果的最长时间, 超过后强制退出; 应短于 k8s 的 terminationGracePeriodSeconds")
+
+// terminated 在收到 SIGTERM 时置位, 用于区分退出码
+var terminated atomic.Bool
+
+// interruptedExitCode 返回被信号中断时的退出码
+func interruptedExitCode() int {
+	if terminated.Load() {
+		return exitTerminated
+	}
+	return exitInterrupted
+}
+
+// forceExitWait 为强制退出前恢复终端和落盘输出文件的最长时间, 终端或磁盘卡住时也能退出
+const forceExitWait = 2 * time.Second
+
+// forceExit 在宽限期结束时退出: 先离开仪表盘的备用屏幕, 再写完错误日志、结果文件和请求记录中已缓冲的内容
+func forceExit(code int) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if dash != nil {
+			dash.Close()
+		}
+		if errorLog != nil {
+			errorLog.Close()
+		}
+		if resultFile != nil {
+			resultFile.Close()
+		}
+		if tracer != nil {
+			tracer.Sync()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(forceExitWait):
+	}
+	os.Exit(code)
+}
+
+// shutdownCtx 在收到中断信号后取消, 用于尽快结束当前测试、冷却等待和进行中的请求
+var shutdownCtx = context.Background()
+
+// resourceMonitor 每秒采样一次本机资源占用
+var resourceMonitor = newResourceMonitor()
+
+func newResourceMonitor() *monitor.Monitor {
+	m := monitor.New(1 * time.Second)
+	m.OnUnavailable = func(name string) {
+		fmt.Printf(msg("metric_unavailable"), name)
+	}
+	return m
+}
+
+var prompts = []string{
+	"你好",
+	"三角函数是什么",
+	"用HTML写一个简单的webgl 三角型 3D 程序",
+}
+
+// usageErrors 为 -validate 时收集到的参数错误
+var usageErrors []error
+
+// usageError 报告参数错误: 通常直接退出; -validate 时先记下, 检查完所有参数后一并输出
+func usageError(err error) {
+	if !*validateOnly {
+		fmt.Println(msg("usage_error"), err)
+		os.Exit(exitUsage)
+	}
+	usageErrors = append(usageErrors, err)
+}
+
+// reportValidation 输出 -validate 的检查结果并返回退出码
+func reportValidation() int {
+	if len(usageErrors) == 0 {
+		fmt.Println("OK")
+		return exitOK
+	}
+	for _, err := range usageErrors {
+		fmt.Println(msg("usage_error"), err)
+	}
+	return exitUsage
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		os.Exit(runMerge(os.Args[2:]))
+	}
+
+	envErr := applyEnv(flag.CommandLine)
+	flag.Parse()
+	// 先确定输出语言 (-lang 也可能来自环境变量), 之后的参数错误和提示都按该语言输出
+	if err := setupLang(*langFlag); err != nil {
+		usageError(err)
+	}
+	if envErr != nil {
+		usageError(envErr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	shutdownCtx = ctx
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		// 恢复默认行为, 再次中断时直接退出
+		signal.Stop(signals)
+		if sig == syscall.SIGTERM {
+			terminated.Store(true)
+			fmt.Printf(msg("terminated"), *shutdownGrace)
+		} else {
+			fmt.Print(msg("interrupted"))
+		}
+		cancel()
+
+		// 进行中的请求、冷却命令等都会随 shutdownCtx 取消, 宽限期用于兜底, 应短于编排系统的强制终止时间
+		time.AfterFunc(*shutdownGrace, func() {
+			fmt.Printf(msg("grace_expired"), *shutdownGrace)
+			forceExit(interruptedExitCode())
+		})
+	}()
+
+	if *serveAddr != "" && !*validateOnly {
+		os.Exit(serve(*serveAddr))
+	}
+
+	if *goMaxProcs > 0 {
+		runtime.GOMAXPROCS(*goMaxProcs)
+	}
+	if *cpuAffinity != "" {
+		cpus, err := parseCPUList(*cpuAffinity)
+		if err != nil {
+			usageError(err)
+		} else if !*validateOnly {
+			if err := setCPUAffinity(cpus); err != nil {
+				fmt.Println(msg("affinity_failed"), err)
+			}
+		}
+	}
+
+	backends, err := parseBackends(*backendNames)
+	if err != nil {
+		usageError(err)
+	}
+
+	if err := validateEndpoints(backends); err != nil {
+		usageError(err)
+	}
+
+	if err := validateStrictJSON(backends); err != nil {
+		usageError(err)
+	}
+	if err := validateSystemPrompt(backends); err != nil {
+		usageError(err)
+	}
+
+	if err := validateCompareCPU(backends); err != nil {
+		usageError(err)
+	}
+
+	if err := validateImages(backends); err != nil {
+		usageError(err)
+	} else if *imagesDir != "" {
+		if images, err = loadImages(*imagesDir); err != nil {
+			usageError(err)
+		}
+	}
+
+	if *warmupUntilStable && (*warmupWindow < 1 || *warmupTolerance <= 0) {
+		usageError(errors.New(msg("bad_warmup_stable")))
+	}
+
+	if *warmupSplit < 0 {
+		usageError(fmt.Errorf(msg("bad_positive_int"), strconv.Itoa(*warmupSplit)))
+	}
+
+	if *batchSize < 1 {
+		usageError(fmt.Errorf(msg("bad_positive_int"), strconv.Itoa(*batchSize)))
+	}
+	if *batchSize > 1 {
+		for _, b := range backends {
+			if _, ok := b.(batchBackend); !ok {
+				usageError(fmt.Errorf(msg("batch_unsupported"), b.Name()))
+			}
+		}
+	}
+
+	if histogramBounds, err = parseHistogramBuckets(*histogramBuckets); err != nil {
+		usageError(err)
+	}
+
+	if err := validateRequestTarget(*httpMethod, *requestPath); err != nil {
+		usageError(err)
+	}
+
+	if err := validateRawBody(); err != nil {
+		usageError(err)
+	}
+
+	if err := validateStream(); err != nil {
+		usageError(err)
+	}
+
+	if err := validateTokenEstimator(*tokenEstimator); err != nil {
+		usageError(err)
+	}
+
+	if timeouts, err = parseTimeouts(*timeoutSpec); err != nil {
+		usageError(err)
+	}
+
+	if err := validateOutputFormat(*outputFormat); err != nil {
+		usageError(err)
+	}
+
+	switch {
+	case *datasetFile != "" && *promptsFile != "":
+		usageError(errors.New(msg("dataset_and_prompts")))
+	case *datasetFile != "":
+		if prompts, err = loadDataset(*datasetFile); err != nil {
+			usageError(err)
+		}
+		promptTags = make([]string, len(prompts))
+	case *promptsFile != "":
+		if prompts, promptTags, err = loadPromptsFile(*promptsFile); err != nil {
+			usageError(err)
+		}
+	default:
+		// 只有没有给出其他提示词来源时才读取标准输入
+		texts, tags, err := stdinPrompts()
+		if err != nil {
+			usageError(err)
+		}
+		if len(texts) > 0 {
+			prompts, promptTags = texts, tags
+		}
+	}
+
+	if err := validateComplexityMode(*complexityMode); err != nil {
+		usageError(err)
+	}
+	if err := validatePromptSampling(); err != nil {
+		usageError(err)
+	}
+	if err := validateWebhook(); err != nil {
+		usageError(err)
+	}
+	if err := validateCacheCheck(); err != nil {
+		usageError(err)
+	}
+	if err := validateMatrixBudget(); err != nil {
+		usageError(err)
+	}
+	if err := validateFanOut(); err != nil {
+		usageError(err)
+	}
+	if err := validateInflight(); err != nil {
+		usageError(err)
+	}
+	if err := validateCooldown(); err != nil {
+		usageError(err)
+	}
+	if err := validateScoreWeights(); err != nil {
+		usageError(err)
+	}
+	if err := validateKeepAlive(); err != nil {
+		usageError(err)
+	}
+	if reportedPercentiles, err = parsePercentiles(*percentileSpec); err != nil {
+		usageError(err)
+	}
+	if selectedColumns, err = parseColumns(*columnSpec); err != nil {
+		usageError(err)
+	}
+	if *capacityThreshold <= 0 || *capacityThreshold > 100 {
+		usageError(errors.New(msg("bad_capacity_threshold")))
+	}
+
+	if expectedThroughput, err = parseExpectedTPS(*expectedTPS); err != nil {
+		usageError(err)
+	}
+	if cells, err = parseCellFilter(*onlyCells, *skipCells); err != nil {
+		usageError(err)
+	}
+	if err := validateSpecialMode(); err != nil {
+		usageError(err)
+	}
+	if err := validateResultLog(); err != nil {
+		usageError(err)
+	}
+
+	meta := newRunMetadata(backends)
+
+	if !*validateOnly {
+		if err := setupTrace(); err != nil {
+			fmt.Println(msg("output_error"), err)
+			os.Exit(exitOutput)
+		}
+		if err := setupErrorLog(); err != nil {
+			fmt.Println(msg("output_error"), err)
+			os.Exit(exitOutput)
+		}
+	}
+	if *waitForReady > 0 && !*validateOnly && !waitUntilReady(backends, *waitForReady) {
+		if shutdownCtx.Err() != nil {
+			os.Exit(interruptedExitCode())
+		}
+		os.Exit(exitUnreachable)
+	}
+
+	if *soakMode && !*validateOnly {
+		os.Exit(runSoak(backends[0], meta))
+	}
+
+	models, err := parseModelList(*modelList)
+	if err != nil {
+		usageError(err)
+	}
+	models = splitModelGroups(models)
+
+	concurrencies, err := parseConcurrencySpec(*concurrencySpec)
+	if err != nil {
+		usageError(err)
+	}
+
+	// 去重并保持原有顺序, 避免同一组合重复测试
+	models, dupModels := dedupe(models)
+	if len(dupModels) > 0 {
+		fmt.Printf(msg("duplicate_models"), strings.Join(dupModels, ", "))
+	}
+	concurrencies, dupConcurrencies := dedupe(concurrencies)
+	if len(dupConcurrencies) > 0 {
+		fmt.Printf(msg("duplicate_concurrency"), dupConcurrencies)
+	}
+
+	if err := validateSLASearch(); err != nil {
+		usageError(err)
+	}
+
+	if err := validateModelSwitch(models); err != nil {
+		usageError(err)
+	}
+
+	if err := validateMix(); err != nil {
+		usageError(err)
+	}
+
+	if err := validatePredictSweep(); err != nil {
+		usageError(err)
+	}
+
+	if err := validateEmbedContention(); err != nil {
+		usageError(err)
+	}
+
+	if *modelSwitch && !*validateOnly {
+		os.Exit(runModelSwitch(backends, models, meta))
+	}
+	if *mixSpec != "" && !*validateOnly {
+		os.Exit(runMix(backends, concurrencies, meta))
+	}
+	if (*complexityMode != "" || *comparePrompts) && !*validateOnly {
+		os.Exit(runComplexity(backends, models, meta))
+	}
+	if *slaP95 > 0 && !*validateOnly {
+		os.Exit(runSLASearch(backends, models, meta))
+	}
+	if *predictSweep != "" && !*validateOnly {
+		os.Exit(runPredictSweep(backends, models, meta))
+	}
+	if *embedModel != "" && !*validateOnly {
+		os.Exit(runEmbedContention(backends, models, concurrencies, meta))
+	}
+
+	var resumeOffset int64
+	if *resumeFile != "" {
+		if resumeOffset, err = setupResume(&meta); err != nil {
+			usageError(err)
+		}
+	}
+
+	cellCount := 0
+	pending := make(map[string]int)
+	for _, model := range models {
+		for _, backend := range backends {
+			for _, concurrency := range concurrencies {
+				for _, mode := range executionModes() {
+					if cellPending(backend.Name(), model, concurrency, mode) {
+						pending[model]++
+					}
+				}
+			}
+		}
+		cellCount += pending[model]
+	}
+	if cellCount == 0 && len(resumed) == 0 && len(models) > 0 && len(concurrencies) > 0 {
+		usageError(errors.New(msg("no_cells")))
+	}
+	if *validateOnly {
+		if err := validateOutputFile(*outputFile); err != nil {
+			usageError(err)
+		}
+		os.Exit(reportValidation())
+	}
+
+	if len(resumed) > 0 {
+		fmt.Printf(msg("resume_skipping"), len(resumed), cellCount)
+	}
+	if path := cmp.Or(*resumeFile, *jsonlFile); path != "" {
+		if resultFile, err = openResultLog(path, meta, resumeOffset); err != nil {
+			fmt.Println(msg("output_error"), err)
+			os.Exit(exitOutput)
+		}
+		defer resultFile.Close()
+	}
+
+	var results []TestResult
+
+	totalVRAM, err := resourceMonitor.GPUTotalMemory()
+	if err != nil {
+		fmt.Println(msg("no_total_vram"), err)
+	}
+
+	if *tuiMode && isTerminal() {
+		// 仪表盘会整屏重绘, 关闭逐行输出的日志
+		*quietMode = true
+		*liveStatus = false
+		dash = newDashboard(cellCount, totalVRAM)
+	}
+
+	progress := newETATracker(pending)
+matrix:
+	for _, model := range models {
+		estimated, ok := estimateModelVRAM(model)
+		if ok && totalVRAM > 0 && estimated > totalVRAM {
+			fmt.Printf(msg("vram_warning"),
+				model, estimated, totalVRAM)
+		}
+
+		// 前一个模型的显存可能尚未完全释放, 以该模型开始前的占用为基线
+		_, vramBaseline, vramErr := resourceMonitor.GPUInfo()
+
+		// 该模型还要运行的组数, 减到 0 时下一组将切换模型, 改用 -cooldown-model
+		modelCells := pending[model]
+
+		for _, backend := range backends {
+			// 续跑时已完成的组直接沿用文件中的结果
+			for _, c := range concurrencies {
+				for _, mode := range executionModes() {
+					if r, ok := resumed[resultKey{backend: backend.Name(), model: model, concurrency: c, mode: mode}]; ok && cells.selected(backend.Name(), model, c) {
+						results = append(results, r)
+					}
+				}
+			}
+			// 只要还有一种执行方式没有完成, 这个并发就需要运行
+			selected := slices.DeleteFunc(slices.Clone(concurrencies), func(c int) bool {
+				return !slices.ContainsFunc(executionModes(), func(mode string) bool {
+					return cellPending(backend.Name(), model, c, mode)
+				})
+			})
+			if len(selected) == 0 {
+				continue
+			}
+
+			var coldStartMs float64
+			if *coldStart {
+				coldStartMs = measureColdStart(backend, model)
+			}
+			var cache *cacheCheckResult
+			if *cacheCheck {
+				cache = measureCache(backend, model)
+			}
+			var systemTokens int
+			if *systemPrompt != "" {
+				systemTokens = measureSystemTokens(backend, model)
+			}
+
+			for _, concurrency := range selected {
+				if shutdownCtx.Err() != nil {
+					break matrix
+				}
+
+				for _, mode := range executionModes() {
+					if shutdownCtx.Err() != nil {
+						break matrix
+					}
+					if !cellPending(backend.Name(), model, concurrency, mode) {
+						continue
+					}
+					modelCells--
+					if !budgetAllows(progress.cellEstimate(model)) {
+						skipForBudget(skippedCell{backend.Name(), model, concurrency, mode})
+						progress.skip(model)
+						continue
+					}
+					fmt.Printf(msg("testing"), backend.Name(), model, concurrency)
+					switch mode {
+					case modeCPU:
+						fmt.Print(msg("testing_cpu"))
+					case modeNoKeepAlive:
+						fmt.Print(msg("testing_no_keepalive"))
+					}
+					var result TestResult
+					forceCPU, freshConnections = mode == modeCPU, mode == modeNoKeepAlive
+					if *backgroundConcurrency > 0 {
+						result = runWithBackground(backend, model, concurrency)
+					} else {
+						result = runTest(backend, model, concurrency)
+					}
+					forceCPU, freshConnections = false, false
+					result.ExecutionMode = mode
+					result.CPUOffloaded = isLikelyCPUOffloaded(model, result.GPUMemoryUsed, totalVRAM)
+					result.GPUMemoryPercent = vramPercent(result, totalVRAM)
+					result.ColdStartMs = coldStartMs
+					applyExpectedTPS(&result)
+					if !comparisonMode(mode) {
+						// 只用 CPU 或不复用连接的结果不参与量化版本对比
+						result.ModelGroup = modelGroups[model]
+					}
+					_, result.Quantization = splitQuantization(model)
+					if vramErr == nil && !slices.Contains(result.UnavailableMetrics, monitor.NameGPUMemoryUsed) {
+						result.GPUMemoryBaseline = vramBaseline
+						result.GPUMemoryDelta = max(0, result.GPUMemoryUsed-vramBaseline)
+					}
+					result.SystemPromptTokens = systemTokens
+					result.CacheCheck = cache
+					results = append(results, result)
+					if resultFile != nil {
+						if err := resultFile.Append(result); err != nil {
+							fmt.Println(msg("jsonl_failed"), err)
+						}
+					}
+					notifyCell(result)
+					if dash != nil {
+						dash.finishCell(result)
+					}
+					if *strictMode && result.FirstError != "" {
+						if dash != nil {
+							dash.Close()
+						}
+						fmt.Printf(msg("strict_failed"), backend.Name(), model, concurrency, result.FirstError)
+						if err := writeResults(results, nil, meta); err != nil {
+							fmt.Println(msg("output_error"), err)
+						}
+						os.Exit(exitStrict)
+					}
+					// 同一模型换并发数时模型仍在显存中, 只需较短的冷却; 在 GPU 和 CPU 之间切换需要重新加载, 按切换模型处理
+					if modelCells > 0 && !*compareCPU {
+						coolDownFor(*cooldownConc)
+					} else {
+						coolDown()
+					}
+					progress.done(model)
+					reportETA(progress, cellCount)
+				}
+			}
+		}
+	}
+
+	if dash != nil {
+		dash.Close()
+	}
+
+	estimateQueueWait(results)
+	if err := writeResults(results, nil, meta); err != nil {
+		fmt.Println(msg("output_error"), err)
+		os.Exit(exitOutput)
+	}
+	if shutdownCtx.Err() != nil {
+		os.Exit(interruptedExitCode())
+	}
+	os.Exit(exitCode(results))
+}
+
+func parseModelList(spec string) ([]string, error) {
+	var models []string
+	for _, m := range strings.Split(spec, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			models = append(models, m)
+		}
+	}
+
+	if len(models) == 0 {
+		return nil, errors.New(msg("no_models"))
+	}
+	return models, nil
+}
+
+// dedupe 按首次出现的顺序去重, 同时返回被移除的重复项
+func dedupe[T comparable](items []T) (unique, duplicates []T) {
+	seen := make(map[T]bool, len(items))
+	for _, item := range items {
+		if seen[item] {
+			duplicates = append(duplicates, item)
+			continue
+		}
+		seen[item] = true
+		unique = append(unique, item)
+	}
+	return unique, duplicates
+}
+
+// maxConcurrencyLevels 为 -concurrency 展开后最多的并发数个数, 避免误写的范围 (如 1..1000000000) 耗尽内存
+const maxConcurrencyLevels = 1000
+
+// parseConcurrencySpec 解析 -concurrency 参数, 每一项可以是单个数字、a..b、a..b:step 或 a..b:*factor;
+// 步长必须为正整数 (倍数至少为 2), 展开后超过 maxConcurrencyLevels 个时返回错误
+func parseConcurrencySpec(spec string) ([]int, error) {
+	var levels []int
+	add := func(n int) error {
+		if len(levels) >= maxConcurrencyLevels {
+			return fmt.Errorf(msg("too_many_concurrency_levels"), spec, maxConcurrencyLevels)
+		}
+		levels = append(levels, n)
+		return nil
+	}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		lo, hi, found := strings.Cut(item, "..")
+		if !found {
+			n, err := parsePositiveInt(item)
+			if err != nil {
+				return nil, err
+			}
+			if err := add(n); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		hi, stepSpec, hasStep := strings.Cut(hi, ":")
+		start, err := parsePositiveInt(lo)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parsePositiveInt(hi)
+		if err != nil {
+			return nil, err
+		}
+		if start > end {
+			return nil, fmt.Errorf(msg("bad_concurrency_range"), item)
+		}
+
+		multiply := strings.HasPrefix(stepSpec, "*")
+		step := 1
+		if hasStep {
+			step, err = parsePositiveInt(strings.TrimPrefix(stepSpec, "*"))
+			if err != nil {
+				return nil, err
+			}
+			if multiply && step < 2 {
+				return nil, fmt.Errorf(msg("bad_concurrency_range"), item)
+			}
+		}
+
+		for n := start; ; {
+			if err := add(n); err != nil {
+				return nil, err
+			}
+			// 下一个值超过 end 时结束, 先比较再计算以免溢出
+			if multiply {
+				if n > end/step {
+					break
+				}
+				n *= step
+			} else {
+				if n > end-step {
+					break
+				}
+				n += step
+			}
+		}
+	}
+
+	if len(levels) == 0 {
+		return nil, errors.New(msg("no_concurrency"))
+	}
+	return levels, nil
+}
+
+// maxCPUs 为 CPU 亲和性掩码能表示的 CPU 数 (Linux 的 CPU_SETSIZE), 更大的编号会被 sched_setaffinity 忽略
+const maxCPUs = 1024
+
+// parseCPUList 解析 taskset 风格的 CPU 列表, 如 0-3,8; 范围必须有两端, 编号超出 maxCPUs 时返回错误而不是忽略
+func parseCPUList(spec string) ([]int, error) {
+	var cpus []int
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		lo, hi, isRange := strings.Cut(item, "-")
+		if !isRange {
+			hi = lo
+		}
+		start, err1 := strconv.Atoi(lo)
+		end, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || start < 0 || start > end {
+			return nil, fmt.Errorf(msg("bad_cpu_list"), spec)
+		}
+		if end >= maxCPUs {
+			return nil, fmt.Errorf(msg("cpu_id_too_large"), spec, end, maxCPUs-1)
+		}
+		for c := start; c <= end; c++ {
+			cpus = append(cpus, c)
+		}
+	}
+
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf(msg("bad_cpu_list"), spec)
+	}
+	return cpus, nil
+}
+
+func parsePositiveInt(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf(msg("bad_positive_int"), s)
+	}
+	return n, nil
+}
+
+// estimateQueueWait 估算每组测试中服务端排队等待的时间.
+//
+// ollama 对同一模型的请求基本是串行处理的, 并发大于 1 时多出来的延迟主要是排队而不是计算.
+// 以同一后端和模型下并发数最低 (通常为 1) 的一组为基准, 认为它几乎没有排队,
+// 则其他组的排队时间约为 平均响应时间 - 基准平均响应时间, 不足 0 时记为 0.
+func estimateQueueWait(results []TestResult) {
+	type modelKey struct{ backend, model string }
+
+	baseline := make(map[modelKey]TestResult)
+	for _, r := range results {
+		key := modelKey{r.Backend, r.Model}
+		if b, ok := baseline[key]; (!ok || r.Concurrency < b.Concurrency) && r.AvgResponseTime > 0 {
+			baseline[key] = r
+		}
+	}
+
+	for i := range results {
+		b, ok := baseline[modelKey{results[i].Backend, results[i].Model}]
+		if !ok || results[i].AvgResponseTime == 0 {
+			continue
+		}
+		results[i].EstQueueWaitMs = max(0, results[i].AvgResponseTime-b.AvgResponseTime)
+	}
+}
+
+// exitCode 根据测试结果计算进程退出码, 接口不可达优先于普通失败, 普通失败优先于吞吐不达标;
+// -strict-json 时结构不符的响应也计为失败
+func exitCode(results []TestResult) int {
+	if len(results) == 0 {
+		return exitNoResults
+	}
+	code := exitOK
+	for _, r := range results {
+		if r.Underperforming && code == exitOK {
+			code = exitBelowTarget
+		}
+		if r.SchemaMismatches > 0 {
+			code = exitCellFailed
+		}
+		if r.SuccessRate > 0 {
+			continue
+		}
+		if r.ConnErrors > 0 {
+			return exitUnreachable
+		}
+		code = exitCellFailed
+	}
+	return code
+}
+
+// measureColdStart 卸载模型后发送单个请求, 返回其耗时(ms); 后端不支持卸载或请求失败时返回 0
+func measureColdStart(backend Backend, model string) float64 {
+	unloader, ok := backend.(modelUnloader)
+	if !ok {
+		fmt.Printf(msg("cold_start_unsupported"), backend.Name())
+		return 0
+	}
+
+	client := &http.Client{Timeout: timeouts.forModel(model), Transport: requestTransport()}
+	if err := unloader.Unload(client, model); err != nil {
+		fmt.Println(msg("cold_start_failed"), err)
+		return 0
+	}
+
+	outcome, err := sendRequest(shutdownCtx, 0, client, backend, model, prompts[:1])
+	if err != nil {
+		fmt.Println(msg("cold_start_failed"), err)
+		return 0
+	}
+
+	coldStartMs := outcome.elapsed.Seconds() * 1000
+	fmt.Printf(msg("cold_start_done"), backend.Name(), model, coldStartMs)
+	return coldStartMs
+}
+
+// coolDown 在两组测试之间等待 -cooldown-model, 期间执行 -between-cmd
+func coolDown() {
+	coolDownFor(*cooldownModel)
+}
+
+// coolDownFor 等待 period, 期间执行 -between-cmd; -between-cmd 耗时超过 period 时不再额外等待
+func coolDownFor(period time.Duration) {
+	start := time.Now()
+	if *monitorCooldown {
+		ctx, stop := context.WithCancel(shutdownCtx)
+		done := logCooldownVRAM(ctx, start)
+		defer func() {
+			stop()
+			<-done
+		}()
+	}
+	if *betweenCmd != "" {
+		runBetweenCmd(*betweenCmd, *betweenCmdTimeout)
+	}
+	if remaining := period - time.Since(start); remaining > 0 {
+		timer := time.NewTimer(remaining)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-shutdownCtx.Done():
+		}
+	}
+}
+
+// logCooldownVRAM 在冷却期内每秒输出显存占用及相对冷却开始时的变化, 返回的通道在采集结束后关闭
+func logCooldownVRAM(ctx context.Context, start time.Time) <-chan struct{} {
+	done := make(chan struct{})
+	metricsChan := resourceMonitor.Start(ctx)
+	go func() {
+		defer close(done)
+		first := math.NaN()
+		for m := range metricsChan {
+			if slices.Contains(m.Missing, monitor.NameGPUMemoryUsed) {
+				continue
+			}
+			if math.IsNaN(first) {
+				first = m.GPUMemoryUsed
+			}
+			fmt.Printf(msg("cooldown_vram"), time.Since(start).Round(time.Second), m.GPUMemoryUsed, m.GPUMemoryUsed-first)
+		}
+	}()
+	return done
+}
+
+// runBetweenCmd 执行用户命令并把输出写入日志, 失败或超时只输出警告
+func runBetweenCmd(command string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(shutdownCtx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	fmt.Printf(msg("between_cmd_run"), command)
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		fmt.Print(string(output))
+		if !bytes.HasSuffix(output, []byte("\n")) {
+			fmt.Println()
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		fmt.Printf(msg("between_cmd_timeout"), timeout)
+	} else if err != nil {
+		fmt.Println(msg("between_cmd_failed"), err)
+	}
+}
+
+// runSoak 以固定模型和并发数长时间运行, 每个间隔输出该时间段的快照, 最后输出整个过程的汇总
+func runSoak(backend Backend, meta runMetadata) int {
+	fmt.Printf(msg("soak_start"),
+		backend.Name(), *soakModel, *soakConcurrency, *soakDuration, *soakInterval)
+
+	var snapshots []TestResult
+	result := runTestFor(backend, *soakModel, *soakConcurrency, *soakDuration, *soakInterval, func(snap TestResult) {
+		applyExpectedTPS(&snap)
+		snapshots = append(snapshots, snap)
+		notifyCell(snap)
+		if err := writeSoakProgress(snapshots, meta); err != nil {
+			fmt.Println(msg("output_error"), err)
+		}
+		if *outputFormat == "table" {
+			fmt.Printf(msg("soak_snapshot"), len(snapshots), time.Duration(len(snapshots))*(*soakInterval))
+			printResults([]TestResult{snap})
+		}
+	})
+	applyExpectedTPS(&result)
+
+	if *outputFormat == "table" {
+		fmt.Println(msg("soak_summary"))
+	}
+	if err := writeResults([]TestResult{result}, snapshots, meta); err != nil {
+		fmt.Println(msg("output_error"), err)
+		return exitOutput
+	}
+	if shutdownCtx.Err() != nil {
+		return interruptedExitCode()
+	}
+	return exitCode([]TestResult{result})
+}
+
+// cellStats 记录一组测试中的请求计数、响应时间和资源采样
+type cellStats struct {
+	totalRequests    int
+	successCount     int
+	connErrors       int
+	oversizedCount   int
+	oomErrors        int
+	timeouts         int
+	errorCounts      map[string]int
+	bytesSaved       int64
+	bytesReceived    int64
+	emptyResponses   int
+	schemaMismatches int    // -strict-json 时结构不符的成功响应数
+	schemaError      string // 第一个结构不符的原因
+	tokenSamples     int    // 报告了生成 token 数的成功请求数
+	estimatedTokens  int    // 其中由客户端估算 token 数的请求数
+	completionTokens int
+	cappedCount      int
+	stoppedWorkers   int // 因 -worker-max-failures 停止的并发数
+	firstError       string
+	firstErrorAt     time.Time              // 合并各并发的统计时取最早的错误
+	windowError      string                 // 上次快照之后的第一个错误, 快照时清空
+	windowErrorAt    time.Time              // 合并各并发的快照时同样取最早的错误
+	windowSchema     string                 // 上次快照之后第一个结构不符的原因, 快照时清空
+	busy             time.Duration          // 所有请求耗时之和, 除以测试时长即平均进行中的请求数
+	responseMeta     map[string]interface{} // 第一个成功响应的元数据, 仅 -response-metadata 时记录
+	responseTimes    []time.Duration
+	doneAt           []time.Duration            // 与 responseTimes 一一对应的完成时间 (相对测试开始), 合并后按此恢复完成顺序
+	workerTimes      map[int][]time.Duration    // -per-worker 时按并发编号记录的响应时间
+	imageTimes       map[string][]time.Duration // -images-dir 时按图片记录的响应时间
+	ttfts            []time.Duration            // -stream 时每个成功请求的首 token 延迟
+	fanOutTimes      []time.Duration            // -fan-out 时每轮全部成功的扇出耗时
+	fanOutFailed     int                        // -fan-out 时有请求失败的轮数
+	itls             []time.Duration            // -stream 时所有相邻 token 的间隔
+	resourceMetrics  []monitor.Metrics
+	promptsUsed      map[int]bool // -dataset 时使用过的提示词编号
+}
+
+// since 返回自 prev 之后新增的统计, 用于计算时间段快照
+func (s cellStats) since(prev cellStats) cellStats {
+	return cellStats{
+		totalRequests:    s.totalRequests - prev.totalRequests,
+		successCount:     s.successCount - prev.successCount,
+		connErrors:       s.connErrors - prev.connErrors,
+		oversizedCount:   s.oversizedCount - prev.oversizedCount,
+		oomErrors:        s.oomErrors - prev.oomErrors,
+		timeouts:         s.timeouts - prev.timeouts,
+		errorCounts:      subCounts(s.errorCounts, prev.errorCounts),
+		bytesSaved:       s.bytesSaved - prev.bytesSaved,
+		bytesReceived:    s.bytesReceived - prev.bytesReceived,
+		emptyResponses:   s.emptyResponses - prev.emptyResponses,
+		schemaMismatches: s.schemaMismatches - prev.schemaMismatches,
+		tokenSamples:     s.tokenSamples - prev.tokenSamples,
+		estimatedTokens:  s.estimatedTokens - prev.estimatedTokens,
+		completionTokens: s.completionTokens - prev.completionTokens,
+		cappedCount:      s.cappedCount - prev.cappedCount,
+		stoppedWorkers:   s.stoppedWorkers - prev.stoppedWorkers,
+		firstError:       s.windowError,
+		firstErrorAt:     s.windowErrorAt,
+		schemaError:      s.windowSchema,
+		busy:             s.busy - prev.busy,
+		responseTimes:    s.responseTimes[len(prev.responseTimes):],
+		doneAt:           s.doneAt[len(prev.doneAt):],
+		ttfts:            s.ttfts[len(prev.ttfts):],
+		fanOutTimes:      s.fanOutTimes[len(prev.fanOutTimes):],
+		fanOutFailed:     s.fanOutFailed - prev.fanOutFailed,
+		itls:             s.itls[len(prev.itls):],
+		resourceMetrics:  s.resourceMetrics[len(prev.resourceMetrics):],
+	}
+}
+
+// snapshot 返回自 last 之后新增的统计, 并将 last 更新为当前统计, 开始下一个时间段
+func (s *cellStats) snapshot(last *cellStats) cellStats {
+	window := s.since(*last)
+	*last = *s
+	last.errorCounts = subCounts(s.errorCounts, nil)
+	s.windowError, s.windowSchema = "", ""
+	return window
+}
+
+// merge 将一个并发单独记录的统计累加到 s 中; 切片会被复制, 合并后的 responseTimes 按并发排列, 需要完成顺序时调用 sortByCompletion
+func (s *cellStats) merge(o cellStats) {
+	s.totalRequests += o.totalRequests
+	s.successCount += o.successCount
+	s.connErrors += o.connErrors
+	s.oversizedCount += o.oversizedCount
+	s.oomErrors += o.oomErrors
+	s.timeouts += o.timeouts
+	for k, v := range o.errorCounts {
+		s.errorCounts[k] += v
+	}
+	s.bytesSaved += o.bytesSaved
+	s.bytesReceived += o.bytesReceived
+	s.emptyResponses += o.emptyResponses
+	s.schemaMismatches += o.schemaMismatches
+	if s.schemaError == "" {
+		s.schemaError = o.schemaError
+	}
+	s.tokenSamples += o.tokenSamples
+	s.estimatedTokens += o.estimatedTokens
+	s.completionTokens += o.completionTokens
+	s.cappedCount += o.cappedCount
+	s.stoppedWorkers += o.stoppedWorkers
+	s.busy += o.busy
+	if o.firstError != "" && (s.firstError == "" || o.firstErrorAt.Before(s.firstErrorAt)) {
+		s.firstError, s.firstErrorAt = o.firstError, o.firstErrorAt
+	}
+	if s.responseMeta == nil {
+		s.responseMeta = o.responseMeta
+	}
+	s.responseTimes = append(s.responseTimes, o.responseTimes...)
+	s.doneAt = append(s.doneAt, o.doneAt...)
+	for w, times := range o.workerTimes {
+		if s.workerTimes == nil {
+			s.workerTimes = make(map[int][]time.Duration)
+		}
+		s.workerTimes[w] = append(s.workerTimes[w], times...)
+	}
+	for image, times := range o.imageTimes {
+		if s.imageTimes == nil {
+			s.imageTimes = make(map[string][]time.Duration)
+		}
+		s.imageTimes[image] = append(s.imageTimes[image], times...)
+	}
+	s.ttfts = append(s.ttfts, o.ttfts...)
+	s.fanOutTimes = append(s.fanOutTimes, o.fanOutTimes...)
+	s.fanOutFailed += o.fanOutFailed
+	s.itls = append(s.itls, o.itls...)
+	for id := range o.promptsUsed {
+		if s.promptsUsed == nil {
+			s.promptsUsed = make(map[int]bool)
+		}
+		s.promptsUsed[id] = true
+	}
+	s.resourceMetrics = append(s.resourceMetrics, o.resourceMetrics...)
+}
+
+// sortByCompletion 将 responseTimes 恢复为完成顺序, -warmup-split 和逐请求输出依赖该顺序
+func (s *cellStats) sortByCompletion() {
+	order := make([]int, len(s.responseTimes))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(s.doneAt[a], s.doneAt[b]) })
+	times, done := make([]time.Duration, len(order)), make([]time.Duration, len(order))
+	for i, j := range order {
+		times[i], done[i] = s.responseTimes[j], s.doneAt[j]
+	}
+	s.responseTimes, s.doneAt = times, done
+}
+
+// subCounts 返回 cur 相对 prev 新增的计数, 只保留非零项
+func subCounts(cur, prev map[string]int) map[string]int {
+	diff := make(map[string]int)
+	for k, v := range cur {
+		if d := v - prev[k]; d > 0 {
+			diff[k] = d
+		}
+	}
+	return diff
+}
+
+func (s cellStats) result(backend Backend, model string, concurrency int, elapsed time.Duration) TestResult {
+	// 计算统计指标
+	avg, max, min := calculateStats(s.responseTimes)
+	successRate := 0.0
+	if s.totalRequests > 0 {
+		successRate = float64(s.successCount) / float64(s.totalRequests) * 100
+	}
+
+	// 获取资源使用峰值
+	maxMetrics := calculateMaxResources(s.resourceMetrics)
+
+	avgTokens, cappedRate := 0.0, 0.0
+	if s.tokenSamples > 0 {
+		avgTokens = float64(s.completionTokens) / float64(s.tokenSamples)
+		cappedRate = float64(s.cappedCount) / float64(s.tokenSamples) * 100
+	}
+
+	itemsPerSecond, tokensPerSecond := 0.0, 0.0
+	if elapsed > 0 {
+		itemsPerSecond = float64(s.successCount**batchSize) / elapsed.Seconds()
+		tokensPerSecond = float64(s.completionTokens) / elapsed.Seconds()
+	}
+
+	result := TestResult{
+		Backend:               backend.Name(),
+		Model:                 model,
+		Concurrency:           concurrency,
+		FinishedAt:            time.Now(),
+		CPULoad:               maxMetrics.CPULoad,
+		GPULoad:               maxMetrics.GPULoad,
+		GPUMemoryUsed:         maxMetrics.GPUMemoryUsed,
+		MemoryUsed:            maxMetrics.MemoryUsed,
+		CustomMetrics:         maxMetrics.Custom,
+		UnavailableMetrics:    unavailableMetrics(s.resourceMetrics),
+		AvgResponseTime:       avg,
+		MaxResponseTime:       max,
+		MinResponseTime:       min,
+		P95ResponseTime:       percentileMs(s.responseTimes, 95),
+		Percentiles:           percentilesMs(s.responseTimes),
+		SuccessRate:           successRate,
+		Requests:              s.totalRequests,
+		Successes:             s.successCount,
+		BytesReceived:         s.bytesReceived,
+		ConnErrors:            s.connErrors,
+		OversizedCount:        s.oversizedCount,
+		OOMErrors:             s.oomErrors,
+		Timeouts:              s.timeouts,
+		RequestTimeoutSec:     timeouts.forModel(model).Seconds(),
+		ErrorCounts:           s.errorCounts,
+		BytesSaved:            s.bytesSaved,
+		EmptyResponses:        s.emptyResponses,
+		SchemaMismatches:      s.schemaMismatches,
+		SchemaError:           s.schemaError,
+		BatchSize:             *batchSize,
+		ItemResponseTime:      avg / float64(*batchSize),
+		ItemsPerSecond:        itemsPerSecond,
+		TokensPerSecond:       tokensPerSecond,
+		NumPredict:            *numPredict,
+		AvgCompletionTokens:   avgTokens,
+		EstimatedTokenSamples: s.estimatedTokens,
+		CappedRate:            cappedRate,
+		FirstError:            s.firstError,
+		StoppedWorkers:        s.stoppedWorkers,
+		ResponseMetadata:      s.responseMeta,
+		Histogram:             buildHistogram(s.responseTimes, histogramBounds),
+		UniquePrompts:         len(s.promptsUsed),
+	}
+	if *fanOut > 1 {
+		result.FanOut, result.FanOuts, result.FanOutFailed = *fanOut, len(s.fanOutTimes), s.fanOutFailed
+		result.FanOutAvgMs, _, _ = calculateStats(s.fanOutTimes)
+		result.FanOutP50Ms = percentileMs(s.fanOutTimes, 50)
+		result.FanOutP95Ms = percentileMs(s.fanOutTimes, 95)
+		result.FanOutP99Ms = percentileMs(s.fanOutTimes, 99)
+	}
+	if len(s.ttfts) > 0 {
+		result.TTFTAvgMs, _, _ = calculateStats(s.ttfts)
+		result.TTFTP95Ms = percentileMs(s.ttfts, 95)
+		result.ITLAvgMs, _, _ = calculateStats(s.itls)
+		result.ITLP95Ms = percentileMs(s.itls, 95)
+	}
+	result.ImageLatency = buildImageLatency(s.imageTimes)
+	drift, drifted := sampleDrift(s.resourceMetrics, resourceMonitor.Interval())
+	result.SampleDriftMaxMs, result.DriftedSamples = drift.Seconds()*1000, drifted
+	if len(s.workerTimes) > 0 {
+		result.WorkerAvgMs, result.Stragglers = workerLatency(s.workerTimes, concurrency)
+	}
+	if *warmupSplit > 0 {
+		split := *warmupSplit
+		if split > len(s.responseTimes) {
+			split = len(s.responseTimes)
+		}
+		result.WarmupAvgMs, _, _ = calculateStats(s.responseTimes[:split])
+		result.SteadyAvgMs, _, _ = calculateStats(s.responseTimes[split:])
+	}
+	var coreAvg []float64
+	result.CPUCoresBusy, result.CPUHottestCore, coreAvg = coreLoad(s.resourceMetrics, *coreBusyThreshold)
+	if *perCoreCPU {
+		result.CPUCoreAvg = coreAvg
+	}
+	if *resourceSamples || *outputDir != "" {
+		result.ResourceSamples = s.resourceMetrics
+	}
+	if *outputDir != "" {
+		result.ResponseTimesMs = make([]float64, len(s.responseTimes))
+		for i, d := range s.responseTimes {
+			result.ResponseTimesMs[i] = d.Seconds() * 1000
+		}
+	}
+	return result
+}
+
+// record 累计一个请求的结果
+func (s *cellStats) record(outcome requestOutcome, err error) {
+	s.totalRequests++
+	s.bytesSaved += outcome.bytesSaved
+	s.bytesReceived += outcome.bytesReceived
+	if err == nil {
+		s.successCount++
+		if outcome.empty {
+			s.emptyResponses++
+		}
+		if outcome.schemaError != "" {
+			s.schemaMismatches++
+			if s.schemaError == "" {
+				s.schemaError = outcome.schemaError
+			}
+			if s.windowSchema == "" {
+				s.windowSchema = outcome.schemaError
+			}
+		}
+		if !outcome.empty || *includeEmpty {
+			s.responseTimes = append(s.responseTimes, outcome.elapsed)
+			if *perWorker {
+				if s.workerTimes == nil {
+					s.workerTimes = make(map[int][]time.Duration)
+				}
+				s.workerTimes[outcome.worker] = append(s.workerTimes[outcome.worker], outcome.elapsed)
+			}
+		}
+		if s.responseMeta == nil {
+			s.responseMeta = outcome.metadata
+		}
+		if outcome.image != "" {
+			if s.imageTimes == nil {
+				s.imageTimes = make(map[string][]time.Duration)
+			}
+			s.imageTimes[outcome.image] = append(s.imageTimes[outcome.image], outcome.elapsed)
+		}
+		if outcome.ttft > 0 {
+			s.ttfts = append(s.ttfts, outcome.ttft)
+			s.itls = append(s.itls, outcome.itl...)
+		}
+		if outcome.tokensKnown {
+			s.tokenSamples++
+			s.completionTokens += outcome.tokens
+			if outcome.tokensEstimated {
+				// 估算值不可靠, 不用于判断是否达到生成上限
+				s.estimatedTokens++
+			} else if *numPredict > 0 && outcome.tokens >= *numPredict**batchSize {
+				// 批量请求的 token 数为合计, 上限按每条提示词计算
+				s.cappedCount++
+			}
+		}
+		return
+	}
+
+	if isConnectionError(err) {
+		s.connErrors++
+	} else if errors.Is(err, errResponseTooLarge) {
+		s.oversizedCount++
+	} else if isOOMError(err) {
+		s.oomErrors++
+	} else if isTimeoutError(err) {
+		s.timeouts++
+	}
+	s.errorCounts[err.Error()]++
+	if s.firstError == "" {
+		s.firstError, s.firstErrorAt = err.Error(), time.Now()
+	}
+	if s.windowError == "" {
+		s.windowError, s.windowErrorAt = err.Error(), time.Now()
+	}
 }
 
-const (
-	testDuration   = 30 * time.Second
-	apiEndpoint    = "http://localhost:11434/api/generate"
-	requestTimeout = 60 * time.Second
-	coolDownPeriod = 10 * time.Second
-)
-
-var prompts = []string{
-	"你好",
-	"三角函数是什么",
-	"用HTML写一个简单的webgl 三角型 3D 程序",
+func runTest(backend Backend, model string, concurrency int) TestResult {
+	return runTestFor(backend, model, concurrency, testDuration, 0, nil)
 }
 
-func main() {
-	models := []string{
-		"deepseek-r1:1.5b",
-		"deepseek-r1:7b",
-		"deepseek-r1:8b",
-		"deepseek-r1:14b",
-		"deepseek-r1:32b",
+// runTestFor 在 duration 内持续压测; snapshotEvery 大于 0 时每隔该时长用 onSnapshot 回调该时间段的结果
+func runTestFor(backend Backend, model string, concurrency int, duration, snapshotEvery time.Duration, onSnapshot func(TestResult)) TestResult {
+	if len(prompts) == 0 {
+		// 启动时已检查, 这里再防一次, 避免抽取提示词时 panic
+		fmt.Println(msg("no_prompts"))
+		return TestResult{Backend: backend.Name(), Model: model, Concurrency: concurrency, FirstError: msg("no_prompts")}
 	}
+	client := &http.Client{Timeout: timeouts.forModel(model), Transport: requestTransport()}
 
-	concurrencies := []int{1, 2, 3, 4, 5, 6}
-
-	var results []TestResult
-
-	for _, model := range models {
-		for _, concurrency := range concurrencies {
-			fmt.Printf("正在测试模型: %s, 并发数: %d\n", model, concurrency)
-			result := runTest(model, concurrency)
-			results = append(results, result)
-			time.Sleep(coolDownPeriod)
+	var warmupTook time.Duration
+	if *warmupUntilStable {
+		var stable bool
+		warmupTook, stable = warmUp(client, backend, model, concurrency)
+		if stable {
+			fmt.Printf(msg("warmup_stable"), backend.Name(), model, concurrency, warmupTook.Round(time.Millisecond))
+		} else {
+			fmt.Printf(msg("warmup_unstable"), backend.Name(), model, concurrency, warmupTook.Round(time.Millisecond))
 		}
 	}
 
-	printResults(results)
-}
-
-func runTest(model string, concurrency int) TestResult {
-	ctx, cancel := context.WithTimeout(context.Background(), testDuration)
+	ctx, cancel := context.WithCancel(shutdownCtx)
 	defer cancel()
+	// 稳定性测试按固定时长运行, 不做延长
+	extend := snapshotEvery == 0 && (*minSamples > 0 || *maxCIPct > 0)
 
+	// 每个并发单独记录统计, 请求完成时只锁自己的统计, 避免极快的模型下所有并发争用同一把锁;
+	// stats 只保存资源采样和停止的并发数, 需要整体统计时由 collect 合并
 	var (
-		mu              sync.Mutex
-		totalRequests   int
-		successCount    int
-		responseTimes   []time.Duration
-		resourceMetrics []ResourceMetrics
+		mu      sync.Mutex // 保护 stats 和 recent
+		stats   = cellStats{errorCounts: make(map[string]int)}
+		recent  []liveSample
+		issued  atomic.Int64 // 已发出的请求数, 用于 -max-requests
+		workers = make([]workerStats, concurrency)
 	)
+	for i := range workers {
+		workers[i].stats.errorCounts = make(map[string]int)
+	}
+	memBefore := readMemStats()
+	start := time.Now()
+
+	// collect 合并各并发的统计和资源采样
+	collect := func() cellStats {
+		merged := cellStats{errorCounts: make(map[string]int)}
+		for i := range workers {
+			workers[i].mu.Lock()
+			merged.merge(workers[i].stats)
+			workers[i].mu.Unlock()
+		}
+		mu.Lock()
+		merged.resourceMetrics = slices.Clone(stats.resourceMetrics)
+		merged.stoppedWorkers = stats.stoppedWorkers
+		mu.Unlock()
+		return merged
+	}
+	// counts 返回当前的请求数和成功数, 比 collect 轻量
+	counts := func() (requests, successes int) {
+		for i := range workers {
+			workers[i].mu.Lock()
+			requests += workers[i].stats.totalRequests
+			successes += workers[i].stats.successCount
+			workers[i].mu.Unlock()
+		}
+		return requests, successes
+	}
+
+	if dash != nil {
+		dash.startCell(backend.Name(), model, concurrency, duration)
+	}
 
 	// 资源监控
 	monitorCtx, stopMonitor := context.WithCancel(context.Background())
 	defer stopMonitor()
-	metricsChan := startMonitoring(monitorCtx)
+	metricsChan := resourceMonitor.Start(monitorCtx)
 
-	// 结果收集
+	// 结果收集; 监控停止后通道关闭, collectorDone 在最后一个采样写入后关闭
+	collectorDone := make(chan struct{})
 	go func() {
+		defer close(collectorDone)
 		for metric := range metricsChan {
+			if dash != nil {
+				requests, successes := counts()
+				dash.update(metric, requests, successes)
+			}
 			mu.Lock()
-			resourceMetrics = append(resourceMetrics, metric)
+			stats.resourceMetrics = append(stats.resourceMetrics, metric)
+			var line string
+			if *liveStatus {
+				recent = pruneLiveSamples(recent, time.Now().Add(-liveWindow))
+				line = liveLine(recent, min(time.Since(start), liveWindow))
+			}
 			mu.Unlock()
+
+			if line != "" {
+				fmt.Println(line)
+			}
 		}
 	}()
 
-	client := &http.Client{Timeout: requestTimeout}
-	var wg sync.WaitGroup
+	// 到达 duration 后结束测试; 需要延长时每秒检查一次样本, 直到足够稳定或达到 -max-cell-duration
+	go func() {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+		defer cancel()
+		if !extend {
+			return
+		}
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			stable := samplesStable(collect().responseTimes)
+			if stable || time.Since(start) >= *maxCellDur {
+				if time.Since(start) > duration+time.Second {
+					fmt.Printf(msg("cell_extended"), backend.Name(), model, concurrency, time.Since(start).Round(time.Second))
+				}
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-	for i := 0; i < concurrency; i++ {
-		wg.Add(1)
+	// 定期快照; 测试结束时等待正在进行的快照回调完成, 避免与调用方读取快照冲突
+	snapshotDone := make(chan struct{})
+	if snapshotEvery > 0 && onSnapshot != nil {
 		go func() {
-			defer wg.Done()
+			defer close(snapshotDone)
+			ticker := time.NewTicker(snapshotEvery)
+			defer ticker.Stop()
+
+			var last cellStats // 上次快照时的资源采样和停止的并发数
 			for {
 				select {
+				case <-ticker.C:
+					window := cellStats{errorCounts: make(map[string]int)}
+					for i := range workers {
+						w := &workers[i]
+						w.mu.Lock()
+						window.merge(w.stats.snapshot(&w.last))
+						w.mu.Unlock()
+					}
+					window.sortByCompletion()
+					mu.Lock()
+					window.resourceMetrics = slices.Clone(stats.resourceMetrics[len(last.resourceMetrics):])
+					window.stoppedWorkers = stats.stoppedWorkers - last.stoppedWorkers
+					last = cellStats{resourceMetrics: stats.resourceMetrics, stoppedWorkers: stats.stoppedWorkers}
+					mu.Unlock()
+					onSnapshot(window.result(backend, model, concurrency, snapshotEvery))
 				case <-ctx.Done():
 					return
-				default:
-					prompt := prompts[rand.Intn(len(prompts))]
-					duration, err := sendRequest(i, client, model, prompt)
+				}
+			}
+		}()
+	} else {
+		close(snapshotDone)
+	}
 
-					mu.Lock()
-					totalRequests++
+	var wg sync.WaitGroup
+
+	// reserve 占用一个请求名额, 达到 -max-requests 或测试已结束时返回 false
+	reserve := func() bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		return *maxRequests == 0 || issued.Add(1) <= int64(*maxRequests)
+	}
+
+	// issue 发送一个已占用名额的请求并记录结果, next 为 -fixed-prompt-sequence 时下一个要使用的提示词;
+	// 因中断而失败时返回 false, 否则同时返回请求的错误
+	sampler := newPromptSampler(*promptSampling, len(prompts))
+	issue := func(idx int, next *int) (bool, error) {
+		batch := make([]string, *batchSize)
+		picked := make([]int, *batchSize)
+		for j := range batch {
+			if *fixedPromptSequence {
+				picked[j] = *next % len(prompts)
+				*next++
+			} else {
+				picked[j] = sampler.pick()
+			}
+			batch[j] = withNonce(prompts[picked[j]])
+		}
+		sent := time.Now()
+		outcome, err := sendRequest(shutdownCtx, idx, client, backend, model, batch)
+		took := time.Since(sent)
+
+		if shutdownCtx.Err() != nil {
+			// 中断导致的失败不计入统计
+			return false, err
+		}
+		w := &workers[idx]
+		w.mu.Lock()
+		recorded := len(w.stats.responseTimes)
+		w.stats.record(outcome, err)
+		if len(w.stats.responseTimes) > recorded {
+			w.stats.doneAt = append(w.stats.doneAt, time.Since(start))
+		}
+		w.stats.busy += took
+		if *datasetFile != "" {
+			if w.stats.promptsUsed == nil {
+				w.stats.promptsUsed = make(map[int]bool)
+			}
+			for _, id := range picked {
+				w.stats.promptsUsed[id] = true
+			}
+		}
+		w.mu.Unlock()
+
+		if tracer != nil {
+			tracer.emit(newTraceRecord(backend, model, concurrency, sent, took, picked, outcome, err))
+		}
+		if err != nil && errorLog != nil {
+			errorLog.log(backend, model, concurrency, sent, picked, outcome, err)
+		}
+		if err != nil && *strictMode {
+			cancel()
+		}
+		if *liveStatus {
+			mu.Lock()
+			recent = append(recent, liveSample{at: time.Now(), elapsed: outcome.elapsed, ok: err == nil})
+			mu.Unlock()
+		}
+		return true, err
+	}
+
+	// issueFanOut 同时发送 -fan-out 个请求 (第一个的名额已由调用方占用), 全部完成后记录整轮耗时;
+	// 因 -max-requests 只能发出部分请求的一轮不计入扇出统计
+	issueFanOut := func(idx int, next *int) (bool, error) {
+		n := 1
+		for n < *fanOut && reserve() {
+			n++
+		}
+		base := *next
+		*next += n * *batchSize
+		took, ok, err := fanOutRequests(n, func(j int) (bool, error) {
+			seq := base + j**batchSize
+			return issue(idx, &seq)
+		})
+		if !ok {
+			return false, err
+		}
+		if n == *fanOut {
+			w := &workers[idx]
+			w.mu.Lock()
+			w.stats.recordFanOut(took, err)
+			w.mu.Unlock()
+		}
+		return true, err
+	}
+	if *fanOut <= 1 {
+		issueFanOut = issue
+	}
+
+	if *inflightMode {
+		// 由调度循环维持 concurrency 个进行中的请求, 每完成一个立即补发一个; 请求使用空出的槽位编号,
+		// 同一槽位同时只有一个请求, 统计按槽位记录
+		slots := make(chan int, concurrency)
+		for i := 0; i < concurrency; i++ {
+			slots <- i
+		}
+	dispatch:
+		for n := 0; ; n++ {
+			var idx int
+			select {
+			case <-ctx.Done():
+				break dispatch
+			case idx = <-slots:
+			}
+			if !reserve() {
+				break
+			}
+			wg.Add(1)
+			go func(idx, seq int) {
+				defer wg.Done()
+				defer func() { slots <- idx }()
+				issue(idx, &seq)
+			}(idx, n**batchSize)
+		}
+	} else {
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				next, failures := 0, 0
+				for reserve() {
+					ok, err := issueFanOut(i, &next)
+					if !ok {
+						return
+					}
 					if err == nil {
-						successCount++
-						responseTimes = append(responseTimes, duration)
+						failures = 0
+						continue
+					}
+					failures++
+					if *workerMaxFailures > 0 && failures >= *workerMaxFailures {
+						mu.Lock()
+						stats.stoppedWorkers++
+						mu.Unlock()
+						fmt.Printf(msg("worker_stopped"), backend.Name(), model, concurrency, i, failures, err)
+						return
 					}
-					mu.Unlock()
 				}
-			}
-		}()
+			}()
+		}
+	}
+
+	var bgStats cellStats
+	var bgWG sync.WaitGroup
+	if backgroundStream {
+		bgStats.errorCounts = make(map[string]int)
+		startBackground(ctx, &bgWG, &mu, &bgStats, client, backend, model, concurrency)
 	}
 
 	wg.Wait()
+	// 前台请求可能因 -max-requests 提前结束, 此时后台请求也随之停止
+	cancel()
+	bgWG.Wait()
+	<-snapshotDone
 	stopMonitor()
+	// 等待收集协程退出, 避免计算统计后仍有采样追加到 resourceMetrics
+	<-collectorDone
+	if tracer != nil {
+		tracer.Sync()
+	}
 
-	// 计算统计指标
-	avg, max, min := calculateStats(responseTimes)
-	successRate := 0.0
-	if totalRequests > 0 {
-		successRate = float64(successCount) / float64(totalRequests) * 100
+	memAfter := readMemStats()
+	stats = collect()
+	stats.sortByCompletion()
+
+	if stats.oomErrors > 0 {
+		fmt.Printf(msg("oom_warning"), backend.Name(), model, concurrency, stats.oomErrors)
 	}
 
-	// 获取资源使用峰值
-	maxMetrics := calculateMaxResources(resourceMetrics)
+	if stats.emptyResponses > 0 {
+		state := msg("empty_excluded")
+		if *includeEmpty {
+			state = msg("empty_included")
+		}
+		fmt.Printf(msg("empty_warning"), backend.Name(), model, concurrency, stats.emptyResponses, state)
+	}
+	if stats.schemaMismatches > 0 {
+		fmt.Printf(msg("schema_warning"), backend.Name(), model, concurrency, stats.schemaMismatches, stats.successCount, stats.schemaError)
+	}
+
+	result := stats.result(backend, model, concurrency, time.Since(start))
+	result.DurationSec = time.Since(start).Seconds()
+	result.ClientGC = gcDelta(memBefore, memAfter)
+	warnSingleCore(result)
+	warnClientGC(result, time.Since(start))
+	if result.DriftedSamples > 0 {
+		fmt.Printf(msg("sample_drift_warning"), backend.Name(), model, concurrency, result.DriftedSamples, result.SampleDriftMaxMs)
+	}
+	result.WarmupDurationSec = warmupTook.Seconds()
+	if *datasetFile != "" {
+		result.DatasetPrompts = len(prompts)
+		fmt.Printf(msg("dataset_usage"), backend.Name(), model, concurrency, result.UniquePrompts, len(prompts), result.Requests**batchSize)
+	}
+	if backgroundStream {
+		bg := bgStats.result(backend, model, *backgroundConcurrency, time.Since(start))
+		result.Background = &bg
+	}
+	// 平均进行中的请求数即请求耗时之和除以测试时长 (Little 定律), 无需在每个请求开始和结束时加锁计数
+	result.AvgInFlight = stats.busy.Seconds() / time.Since(start).Seconds()
+	result.SampleRequest = sampleRequestBody(backend, model)
+	return result
+}
+
+// workerLatency 计算每个并发的平均响应, 并找出偏离所有并发中位数超过 -straggler-threshold 的并发
+func workerLatency(times map[int][]time.Duration, concurrency int) (avgs []float64, stragglers []int) {
+	avgs = make([]float64, concurrency)
+	var measured []float64
+	for i := range avgs {
+		if len(times[i]) == 0 {
+			continue
+		}
+		avgs[i], _, _ = calculateStats(times[i])
+		measured = append(measured, avgs[i])
+	}
+	if len(measured) < 2 {
+		return avgs, nil
+	}
+
+	median := medianOf(measured)
+	for i, avg := range avgs {
+		if avg > 0 && math.Abs(avg-median) > median**stragglerThreshold {
+			stragglers = append(stragglers, i)
+		}
+	}
+	return avgs, stragglers
+}
+
+// medianOf 返回 values 的中位数, values 会被排序
+func medianOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// samplesStable 判断响应时间样本是否满足 -min-samples 和 -max-ci-pct
+func samplesStable(durations []time.Duration) bool {
+	if len(durations) < max(*minSamples, 2) {
+		return false
+	}
+	if *maxCIPct <= 0 {
+		return true
+	}
+	return ciHalfWidthPct(durations) <= *maxCIPct
+}
+
+// ciHalfWidthPct 返回平均响应 95% 置信区间的半宽占平均值的百分比 (正态近似)
+func ciHalfWidthPct(durations []time.Duration) float64 {
+	n := float64(len(durations))
+	var sum, sumSq float64
+	for _, d := range durations {
+		v := d.Seconds()
+		sum += v
+		sumSq += v * v
+	}
+	mean := sum / n
+	if mean == 0 {
+		return 0
+	}
+	variance := max(0, (sumSq-n*mean*mean)/(n-1))
+	return 1.96 * math.Sqrt(variance/n) / mean * 100
+}
+
+// workerStats 为单个并发的统计, mu 只在该并发记录请求和合并统计时争用
+type workerStats struct {
+	mu    sync.Mutex
+	stats cellStats
+	last  cellStats // 上次快照时的统计
+}
+
+// sampleRequestBody 用 encodeRequestBody 生成一个与实际发送完全相同的请求体 (含 stream、随机串和图片), 用于复现测试;
+// 名称为敏感信息的字段 (见 isSensitiveName) 会被替换
+func sampleRequestBody(backend Backend, model string) json.RawMessage {
+	batch := make([]string, *batchSize)
+	for i := range batch {
+		batch[i] = withNonce(prompts[i%len(prompts)])
+	}
+	data := encodeRequestBody(backend, model, batch, pickImage())
+
+	var body interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		// 模板生成的请求体不一定是 JSON, 以字符串形式保存, 无法脱敏
+		data, _ = json.Marshal(string(data))
+		return data
+	}
+	data, err := json.Marshal(redactFields(body))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// redactFields 递归替换 JSON 中名称为敏感信息的字段的值
+func redactFields(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, field := range t {
+			if isSensitiveName(k) {
+				t[k] = redacted
+			} else {
+				t[k] = redactFields(field)
+			}
+		}
+	case []interface{}:
+		for i := range t {
+			t[i] = redactFields(t[i])
+		}
+	}
+	return v
+}
+
+// liveSample 记录一次请求的完成时间和结果, 用于计算实时状态
+type liveSample struct {
+	at      time.Time
+	elapsed time.Duration
+	ok      bool
+}
+
+// pruneLiveSamples 丢弃 cutoff 之前完成的请求, samples 按完成时间递增
+func pruneLiveSamples(samples []liveSample, cutoff time.Time) []liveSample {
+	i := 0
+	for i < len(samples) && samples[i].at.Before(cutoff) {
+		i++
+	}
+	return samples[i:]
+}
+
+// liveLine 根据滑动窗口内的请求生成一行实时状态, window 为窗口的实际长度
+func liveLine(samples []liveSample, window time.Duration) string {
+	var okCount int
+	var okTotal time.Duration
+	for _, s := range samples {
+		if s.ok {
+			okCount++
+			okTotal += s.elapsed
+		}
+	}
+
+	rps, avgMs, successRate := 0.0, 0.0, 0.0
+	if window > 0 {
+		rps = float64(len(samples)) / window.Seconds()
+	}
+	if okCount > 0 {
+		avgMs = okTotal.Seconds() / float64(okCount) * 1000
+	}
+	if len(samples) > 0 {
+		successRate = float64(okCount) / float64(len(samples)) * 100
+	}
 
-	return TestResult{
-		Model:           model,
-		Concurrency:     concurrency,
-		CPULoad:         maxMetrics.CPULoad,
-		GPULoad:         maxMetrics.GPULoad,
-		GPUMemoryUsed:   maxMetrics.GPUMemoryUsed,
-		MemoryUsed:      maxMetrics.MemoryUsed,
-		AvgResponseTime: avg,
-		MaxResponseTime: max,
-		MinResponseTime: min,
-		SuccessRate:     successRate,
+	return fmt.Sprintf(msg("live_status"), rps, avgMs, successRate)
+}
+
+// isConnectionError 判断错误是否为无法建立连接 (服务未启动、地址错误等)
+func isConnectionError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// oomSignatures 为 ollama、llama.cpp 和 vLLM 在显存不足或 CUDA 出错时返回的错误信息片段 (小写)
+var oomSignatures = []string{
+	"out of memory",
+	"cuda error",
+	"cudamalloc",
+	"cublas_status_alloc_failed",
+	"failed to allocate",
+	"requires more system memory",
+	"insufficient memory",
+}
+
+// isOOMError 判断服务端返回的错误是否为显存不足或 CUDA 错误
+func isOOMError(err error) bool {
+	s := strings.ToLower(err.Error())
+	for _, sig := range oomSignatures {
+		if strings.Contains(s, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// logRequest 输出单个请求的日志; text 为取出的生成内容, 可能为 nil 或类型不符, 这里只做安全的读取, 不会 panic;
+// 设置了 -error-log 时失败请求只写入该文件
+func logRequest(idx int, model, prompt string, elapsed time.Duration, text interface{}, response map[string]interface{}, err error) {
+	if err != nil {
+		if errorLog != nil {
+			return
+		}
+		fmt.Printf(msg("request_failed"), idx, model, prompt, elapsed, err)
+		return
 	}
+
+	switch t := text.(type) {
+	case string:
+		fmt.Printf(msg("request_done"), idx, model, prompt, elapsed, len(t))
+	case streamedText:
+		fmt.Printf(msg("request_done"), idx, model, prompt, elapsed, int(t))
+	case nil:
+		// 没有找到生成内容的字段, 输出整个响应便于排查
+		fmt.Printf(msg("request_raw"), idx, model, prompt, elapsed, fmt.Sprintf("%+v", response))
+	default:
+		// 部分后端的生成内容是嵌套对象或数组, 按通用格式输出
+		fmt.Printf(msg("request_raw"), idx, model, prompt, elapsed, fmt.Sprintf("%v", t))
+	}
+}
+
+// requestOutcome 为单个请求的测量结果
+type requestOutcome struct {
+	elapsed           time.Duration
+	worker            int                    // 发出请求的并发编号
+	bytesSaved        int64                  // gzip 节省的传输字节数, 未压缩时为 0
+	bytesReceived     int64                  // 读取的响应体字节数
+	empty             bool                   // 请求成功但生成的内容为空
+	tokens            int                    // 服务端报告的生成 token 数
+	tokensKnown       bool                   // 响应中是否包含生成 token 数 (或已由 -estimate-tokens 估算)
+	tokensEstimated   bool                   // tokens 为客户端估算值
+	promptTokens      int                    // 服务端报告的输入 token 数
+	promptTokensKnown bool                   // 响应中是否包含输入 token 数
+	metadata          map[string]interface{} // -response-metadata 时去掉生成文本后的原始响应
+	ttft              time.Duration          // -stream 时首个 token 的到达耗时
+	itl               []time.Duration        // -stream 时相邻 token 的间隔
+	image             string                 // -images-dir 时附带的图片文件名
+	status            int                    // HTTP 状态码, 未收到响应时为 0
+	schemaError       string                 // -strict-json 时响应与预期结构不符的原因
 }
 
-func sendRequest(idx int, client *http.Client, model, prompt string) (time.Duration, error) {
+// countingReader 统计实际从网络读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// sendRequest 发送一个请求, batch 为该请求包含的提示词 (通常只有一条); ctx 取消时中止请求
+func sendRequest(ctx context.Context, idx int, client *http.Client, backend Backend, model string, batch []string) (requestOutcome, error) {
+	return sendRequestImage(ctx, idx, client, backend, model, batch, pickImage())
+}
+
+// sendRequestImage 与 sendRequest 相同, 但附带指定的图片 (nil 时不带图片)
+func sendRequestImage(ctx context.Context, idx int, client *http.Client, backend Backend, model string, batch []string, image *requestImage) (outcome requestOutcome, err error) {
 	start := time.Now()
+	outcome.worker = idx
 	var response map[string]interface{}
+	var text interface{}
 
 	defer func() {
-		if err := recover(); err != nil {
-			fmt.Println("发生错误:", err)
-		}
-		if response["response"] != nil {
-			fmt.Printf("[C-%d] [%s] [%s]请求耗时:%d  response size: %d\n",
-				idx, model, prompt, time.Since(start), len(response["response"].(string)))
-		} else {
-			rsp := fmt.Sprintf("%+v", response)
-			fmt.Printf("[C-%d] [%s] [%s]请求耗时:%d   response:\n%s\n", idx, model, prompt, time.Since(start), rsp)
+		if !*quietMode {
+			logRequest(idx, model, strings.Join(batch, " | "), time.Since(start), text, response, err)
 		}
 	}()
 
-	requestBody, _ := json.Marshal(map[string]interface{}{
-		"model":  model,
-		"prompt": prompt,
-		"stream": false,
-	})
+	if image != nil {
+		outcome.image = image.name
+	}
+	requestBody := encodeRequestBody(backend, model, batch, image)
+
+	var buf bytes.Buffer
+	if *compress {
+		zw := gzip.NewWriter(&buf)
+		zw.Write(requestBody)
+		zw.Close()
+		outcome.bytesSaved += int64(len(requestBody) - buf.Len())
+	} else {
+		buf.Write(requestBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, *httpMethod, requestURL(backend.Endpoint()), &buf)
+	if err != nil {
+		return outcome, err
+	}
+	req.Header.Set("Content-Type", *contentType)
+	if *compress {
+		// 手动设置 Accept-Encoding 后 Transport 不再自动解压, 以便统计实际传输字节数
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
 
-	resp, err := client.Post(apiEndpoint, "application/json", bytes.NewBuffer(requestBody))
+	resp, err := client.Do(req)
 	if err != nil {
-		return 0, err
+		return outcome, err
 	}
 	defer resp.Body.Close()
+	outcome.status = resp.StatusCode
+
+	var respBody io.Reader = resp.Body
+	var wire *countingReader
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		wire = &countingReader{r: resp.Body}
+		zr, err := gzip.NewReader(wire)
+		if err != nil {
+			return outcome, err
+		}
+		defer zr.Close()
+		respBody = zr
+	}
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("非200状态码: %d", resp.StatusCode)
+		// 尽量带上服务端的错误信息, 如 model not found
+		data, _ := io.ReadAll(io.LimitReader(respBody, 4096))
+		if serverMsg := backend.ErrorMessage(data); serverMsg != "" {
+			return outcome, fmt.Errorf(msg("status_error_msg"), resp.StatusCode, serverMsg)
+		}
+		return outcome, fmt.Errorf(msg("status_error"), resp.StatusCode)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return 0, err
+	// 限制读取量, 避免高并发下超大响应占用过多内存
+	body := respBody
+	if *maxResponseBytes > 0 {
+		body = io.LimitReader(respBody, *maxResponseBytes+1)
 	}
 
-	return time.Since(start), nil
-}
+	if *streamMode {
+		var stream streamResult
+		stream, err = readStream(body, backend, start)
+		outcome.bytesReceived = stream.bytes
+		// 超过限制时流在半行处被截断, 解析错误是截断造成的, 按超大响应计
+		if *maxResponseBytes > 0 && stream.bytes > *maxResponseBytes {
+			return outcome, errResponseTooLarge
+		}
+		if err != nil {
+			return outcome, err
+		}
+		if wire != nil {
+			outcome.bytesSaved += stream.bytes - wire.n
+		}
+		text, response = streamedText(stream.textLen), stream.last
+		outcome.ttft, outcome.itl = stream.ttft, stream.gaps
+		outcome.tokens, outcome.tokensKnown = stream.tokens, stream.tokensKnown
+		outcome.promptTokens, outcome.promptTokensKnown = promptTokens(backend, response)
+		if *responseMetadata && response != nil {
+			outcome.metadata = stripGeneratedText(response)
+		}
+		outcome.empty = stream.counter.blank()
+		applyTokenEstimate(&outcome, &stream.counter, len(batch))
+		outcome.elapsed = time.Since(start)
+		return outcome, nil
+	}
 
-func startMonitoring(ctx context.Context) <-chan ResourceMetrics {
-	metricsChan := make(chan ResourceMetrics)
-	go func() {
-		defer close(metricsChan)
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
+	data, err := io.ReadAll(body)
+	outcome.bytesReceived = int64(len(data))
+	if err != nil {
+		return outcome, err
+	}
+	if *maxResponseBytes > 0 && int64(len(data)) > *maxResponseBytes {
+		return outcome, errResponseTooLarge
+	}
+	if wire != nil {
+		outcome.bytesSaved += int64(len(data)) - wire.n
+	}
 
-		for {
-			select {
-			case <-ticker.C:
-				cpuPercent, _ := cpu.Percent(0, false)
-				memInfo, _ := mem.VirtualMemory()
-				gpuUtil, gpuMem, _ := getGPUInfo()
-
-				if len(cpuPercent) > 0 {
-					metricsChan <- ResourceMetrics{
-						CPULoad:       cpuPercent[0],
-						MemoryUsed:    memInfo.UsedPercent,
-						GPULoad:       gpuUtil,
-						GPUMemoryUsed: gpuMem,
-					}
-				}
-			case <-ctx.Done():
-				return
-			}
+	if *responseFormat == "text" {
+		text = string(data)
+	} else {
+		if err = json.Unmarshal(data, &response); err != nil {
+			return outcome, err
 		}
-	}()
-	return metricsChan
+		outcome.schemaError = checkSchema(backend, data)
+		text = backend.ResponseText(response)
+		outcome.tokens, outcome.tokensKnown = backend.CompletionTokens(response)
+		outcome.promptTokens, outcome.promptTokensKnown = promptTokens(backend, response)
+		if *responseMetadata {
+			outcome.metadata = stripGeneratedText(response)
+		}
+	}
+
+	if s, ok := text.(string); ok && strings.TrimSpace(s) == "" {
+		outcome.empty = true
+	}
+	estimateMissingTokens(&outcome, text, len(batch))
+
+	outcome.elapsed = time.Since(start)
+	return outcome, nil
 }
 
-func getGPUInfo() (float64, float64, error) {
-	cmd := exec.Command("nvidia-smi", "--query-gpu=utilization.gpu,memory.used", "--format=csv,noheader,nounits")
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, 0, err
+// promptTokens 返回响应中服务端报告的输入 token 数, 后端不支持或响应中没有时返回 false
+func promptTokens(backend Backend, response map[string]interface{}) (int, bool) {
+	c, ok := backend.(promptTokenCounter)
+	if !ok || response == nil {
+		return 0, false
+	}
+	return c.PromptTokens(response)
+}
+
+// estimateModelVRAM 根据模型标签中的参数量 (如 deepseek-r1:7b) 粗略估算所需显存(MB)
+func estimateModelVRAM(model string) (float64, bool) {
+	idx := strings.LastIndex(model, ":")
+	if idx < 0 {
+		return 0, false
+	}
+
+	tag := strings.ToLower(model[idx+1:])
+	// 兼容 7b-q4_K_M 这类带量化后缀的标签
+	tag = strings.SplitN(tag, "-", 2)[0]
+	if !strings.HasSuffix(tag, "b") {
+		return 0, false
+	}
+
+	params, err := strconv.ParseFloat(strings.TrimSuffix(tag, "b"), 64)
+	if err != nil || params <= 0 {
+		return 0, false
+	}
+
+	return params*vramPerBillionParamsMB + vramOverheadMB, true
+}
+
+// vramPercent 返回峰值显存占总显存的百分比, 总显存或显存占用无法获取时返回 0
+func vramPercent(r TestResult, totalVRAM float64) float64 {
+	if totalVRAM <= 0 || slices.Contains(r.UnavailableMetrics, monitor.NameGPUMemoryUsed) {
+		return 0
 	}
+	return r.GPUMemoryUsed / totalVRAM * 100
+}
 
-	fields := strings.Split(strings.TrimSpace(string(output)), ",")
-	if len(fields) != 2 {
-		return 0, 0, fmt.Errorf("invalid GPU data")
+// isLikelyCPUOffloaded 判断模型是否可能因显存不足而部分运行在 CPU 上:
+// 估算大小超过总显存, 或实测峰值显存已接近显卡上限
+func isLikelyCPUOffloaded(model string, peakVRAM, totalVRAM float64) bool {
+	if totalVRAM <= 0 {
+		return false
 	}
 
-	util, _ := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
-	mem, _ := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	if estimated, ok := estimateModelVRAM(model); ok && estimated > totalVRAM {
+		return true
+	}
 
-	return util, mem, nil
+	return peakVRAM >= totalVRAM*vramFullRatio
 }
 
 func calculateStats(durations []time.Duration) (avg, max, min float64) {
@@ -268,9 +2390,51 @@ func calculateStats(durations []time.Duration) (avg, max, min float64) {
 	return avgMs, maxDur.Seconds() * 1000, minDur.Seconds() * 1000
 }
 
-func calculateMaxResources(metrics []ResourceMetrics) ResourceMetrics {
-	max := ResourceMetrics{}
+// percentileMs 返回 ds 的第 p 百分位数(ms), 使用最近秩法
+func percentileMs(ds []time.Duration, p float64) float64 {
+	if len(ds) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(ds)
+	slices.Sort(sorted)
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+	return sorted[rank].Seconds() * 1000
+}
+
+// unavailableMetrics 返回在所有采样中都缺失的指标, 没有采样时返回 nil
+func unavailableMetrics(metrics []monitor.Metrics) []string {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	missing := make(map[string]int)
+	for _, m := range metrics {
+		for _, name := range m.Missing {
+			missing[name]++
+		}
+	}
+
+	var names []string
+	for _, name := range []string{monitor.NameCPULoad, monitor.NameCPUCores, monitor.NameGPULoad, monitor.NameGPUMemoryUsed, monitor.NameMemoryUsed} {
+		if missing[name] == len(metrics) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func calculateMaxResources(metrics []monitor.Metrics) monitor.Metrics {
+	max := monitor.Metrics{}
 	for _, m := range metrics {
+		for name, v := range m.Custom {
+			if old, ok := max.Custom[name]; !ok || v > old {
+				if max.Custom == nil {
+					max.Custom = make(map[string]float64)
+				}
+				max.Custom[name] = v
+			}
+		}
 		if m.CPULoad > max.CPULoad {
 			max.CPULoad = m.CPULoad
 		}
@@ -286,25 +2450,3 @@ func calculateMaxResources(metrics []ResourceMetrics) ResourceMetrics {
 	}
 	return max
 }
-
-func printResults(results []TestResult) {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "模型\t并发数\tCPU负载(%)\tGPU负载(%)\t显存使用(MB)\t内存使用(%)\t平均响应(ms)\t最大响应(ms)\t最小响应(ms)\t成功率(%)\t")
-
-	for _, r := range results {
-		fmt.Fprintf(w, "%s\t%d\t%.1f\t%.1f\t%.0f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
-			r.Model,
-			r.Concurrency,
-			r.CPULoad,
-			r.GPULoad,
-			r.GPUMemoryUsed,
-			r.MemoryUsed,
-			r.AvgResponseTime,
-			r.MaxResponseTime,
-			r.MinResponseTime,
-			r.SuccessRate,
-		)
-	}
-
-	w.Flush()
-}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"model-test/internal/monitor"
+)
+
+func TestCalculateMaxResources(t *testing.T) {
+	samples := []monitor.Metrics{
+		{CPULoad: 20, GPULoad: 10, GPUMemoryUsed: 1000, MemoryUsed: 40, Custom: map[string]float64{"npu": 5}},
+		{CPULoad: 80, GPULoad: 95, GPUMemoryUsed: 3000, MemoryUsed: 35},
+		{CPULoad: 50, GPULoad: 60, GPUMemoryUsed: 2500, MemoryUsed: 45, Custom: map[string]float64{"npu": 7}},
+	}
+	peak := calculateMaxResources(samples)
+	if peak.CPULoad != 80 || peak.GPULoad != 95 || peak.GPUMemoryUsed != 3000 || peak.MemoryUsed != 45 {
+		t.Errorf("calculateMaxResources() = %+v, want CPU 80, GPU 95, VRAM 3000, memory 45", peak)
+	}
+	if peak.Custom["npu"] != 7 {
+		t.Errorf("custom peak = %v, want 7", peak.Custom["npu"])
+	}
+
+	if empty := calculateMaxResources(nil); empty.GPUMemoryUsed != 0 || empty.Custom != nil {
+		t.Errorf("calculateMaxResources(nil) = %+v, want zero value", empty)
+	}
+}
+
+func TestUnavailableMetrics(t *testing.T) {
+	samples := []monitor.Metrics{
+		{Missing: []string{monitor.NameGPULoad, monitor.NameGPUMemoryUsed}},
+		{Missing: []string{monitor.NameGPULoad, monitor.NameGPUMemoryUsed, monitor.NameCPULoad}},
+	}
+	// 只有每次采样都失败的指标才算无法采集
+	got := unavailableMetrics(samples)
+	want := []string{monitor.NameGPULoad, monitor.NameGPUMemoryUsed}
+	if !slices.Equal(got, want) {
+		t.Errorf("unavailableMetrics() = %v, want %v", got, want)
+	}
+}
+
+// BenchmarkRecord 对比每个请求完成时的统计记录: shared 为所有并发共用一份统计和一把锁 (改为按并发记录之前的做法),
+// per-worker 为每个并发只锁自己的统计, 结束时再合并; 可用 -cpu 1,4,16 查看不同 GOMAXPROCS 下的差异
+func BenchmarkRecord(b *testing.B) {
+	outcome := requestOutcome{elapsed: 5 * time.Millisecond, tokens: 20, tokensKnown: true, bytesReceived: 512}
+
+	b.Run("shared", func(b *testing.B) {
+		var mu sync.Mutex
+		stats := cellStats{errorCounts: make(map[string]int)}
+		b.SetParallelism(16)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				mu.Lock()
+				stats.record(outcome, nil)
+				mu.Unlock()
+			}
+		})
+	})
+
+	b.Run("per-worker", func(b *testing.B) {
+		var mu sync.Mutex
+		var workers []*workerStats
+		b.SetParallelism(16)
+		b.RunParallel(func(pb *testing.PB) {
+			w := &workerStats{stats: cellStats{errorCounts: make(map[string]int)}}
+			mu.Lock()
+			workers = append(workers, w)
+			mu.Unlock()
+			for pb.Next() {
+				w.mu.Lock()
+				w.stats.record(outcome, nil)
+				w.mu.Unlock()
+			}
+		})
+		// 合并的开销也计入, 与 runTestFor 结束时的 collect 相同
+		merged := cellStats{errorCounts: make(map[string]int)}
+		for _, w := range workers {
+			merged.merge(w.stats)
+		}
+		if merged.totalRequests != b.N {
+			b.Fatalf("merged %d requests, want %d", merged.totalRequests, b.N)
+		}
+	})
+}
+
+func TestExitCode(t *testing.T) {
+	ok := TestResult{SuccessRate: 100}
+	failed := TestResult{}
+	unreachable := TestResult{ConnErrors: 3}
+	slow := TestResult{SuccessRate: 100, Underperforming: true}
+	mismatched := TestResult{SuccessRate: 100, SchemaMismatches: 1}
+
+	tests := []struct {
+		name    string
+		results []TestResult
+		want    int
+	}{
+		{"no results", nil, exitNoResults},
+		{"all ok", []TestResult{ok, ok}, exitOK},
+		{"below target", []TestResult{ok, slow}, exitBelowTarget},
+		{"failed beats below target", []TestResult{slow, failed}, exitCellFailed},
+		{"schema mismatch beats below target", []TestResult{mismatched, slow}, exitCellFailed},
+		{"unreachable beats everything", []TestResult{slow, failed, unreachable}, exitUnreachable},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.results); got != tt.want {
+			t.Errorf("%s: exitCode() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseConcurrencySpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr bool
+	}{
+		{spec: "1,2,4", want: []int{1, 2, 4}},
+		{spec: "1..4", want: []int{1, 2, 3, 4}},
+		{spec: "1..10:3", want: []int{1, 4, 7, 10}},
+		{spec: "1..10:*2", want: []int{1, 2, 4, 8}},
+		{spec: "3..3", want: []int{3}},
+		{spec: " 8, 1..2 ", want: []int{8, 1, 2}},
+		{spec: "9223372036854775000..9223372036854775807:500", want: []int{9223372036854775000, 9223372036854775500}},
+		{spec: "4611686018427387904..9223372036854775807:*2", want: []int{4611686018427387904}},
+		{spec: "", wantErr: true},
+		{spec: "0", wantErr: true},
+		{spec: "4..1", wantErr: true},
+		{spec: "1..10:0", wantErr: true},
+		{spec: "1..10:-1", wantErr: true},
+		{spec: "1..10:*1", wantErr: true},
+		{spec: "1..10:*0", wantErr: true},
+		{spec: "1..99999999999999999999", wantErr: true},
+		{spec: "1..1000000000", wantErr: true},
+		{spec: "1..1001", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseConcurrencySpec(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseConcurrencySpec(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseConcurrencySpec(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+
+	if got, err := parseConcurrencySpec("1..1000"); err != nil || len(got) != maxConcurrencyLevels {
+		t.Errorf("parseConcurrencySpec(\"1..1000\") = %d levels, %v, want %d", len(got), err, maxConcurrencyLevels)
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr bool
+	}{
+		{spec: "0-3,8", want: []int{0, 1, 2, 3, 8}},
+		{spec: " 5 ", want: []int{5}},
+		{spec: "1020-1023", want: []int{1020, 1021, 1022, 1023}},
+		{spec: "", wantErr: true},
+		{spec: "3-", wantErr: true},
+		{spec: "-3", wantErr: true},
+		{spec: "3-1", wantErr: true},
+		{spec: "1-2-3", wantErr: true},
+		{spec: "a", wantErr: true},
+		{spec: "1024", wantErr: true},
+		{spec: "0-4000000000", wantErr: true},
+		{spec: "0-99999999999999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseCPUList(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCPUList(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseCPUList(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestForceExit(t *testing.T) {
+	if dir := os.Getenv("MODELTEST_FORCE_EXIT_DIR"); dir != "" {
+		// 子进程: 打开各输出文件并写入一些内容后强制退出
+		*errorLogFile = filepath.Join(dir, "errors.log")
+		if err := setupErrorLog(); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		if tracer, err = openTrace(filepath.Join(dir, "trace.jsonl")); err != nil {
+			t.Fatal(err)
+		}
+		if resultFile, err = openResultLog(filepath.Join(dir, "results.jsonl"), runMetadata{}, 0); err != nil {
+			t.Fatal(err)
+		}
+		backend := ollamaBackend{}
+		failure := errors.New("boom")
+		errorLog.log(backend, "m", 1, time.Now(), []int{0}, requestOutcome{}, failure)
+		tracer.emit(newTraceRecord(backend, "m", 1, time.Now(), time.Millisecond, []int{0}, requestOutcome{}, failure))
+		resultFile.Append(TestResult{Backend: "ollama", Model: "m", Concurrency: 1})
+		forceExit(exitTerminated)
+		return
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestForceExit$")
+	cmd.Env = append(os.Environ(), "MODELTEST_FORCE_EXIT_DIR="+dir)
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitTerminated {
+		t.Fatalf("child exited with %v, want code %d; output:\n%s", err, exitTerminated, out)
+	}
+
+	// 缓冲中的错误日志和请求记录必须在退出前写入文件
+	for file, want := range map[string]string{
+		"errors.log":    "# summary: 1 failed requests",
+		"trace.jsonl":   `"error":"boom"`,
+		"results.jsonl": `"model":"m"`,
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s = %q, want it to contain %q", file, data, want)
+		}
+	}
+}
+
+func TestWriteResultsNoResultsJSON(t *testing.T) {
+	savedFormat, savedFile := *outputFormat, *outputFile
+	*outputFormat, *outputFile = "json", ""
+	t.Cleanup(func() { *outputFormat, *outputFile = savedFormat, savedFile })
+
+	// 没有结果的原因写到标准错误, 标准输出仍是合法的 JSON
+	var err error
+	stderr := captureStderr(t, func() {
+		out := captureStdout(t, func() { err = writeResults(nil, nil, runMetadata{}) })
+		var doc resultsDocument
+		if jerr := json.Unmarshal([]byte(out), &doc); jerr != nil {
+			t.Errorf("stdout is not a JSON document: %v\n%s", jerr, out)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr, noResultsReason()) {
+		t.Errorf("stderr = %q, want the no-results reason", stderr)
+	}
+}
+
+func TestValidateSpecialMode(t *testing.T) {
+	savedSoak, savedMix, savedSwitch := *soakMode, *mixSpec, *modelSwitch
+	savedOnly, savedSkip, savedTPS, savedJSONL := *onlyCells, *skipCells, *expectedTPS, *jsonlFile
+	t.Cleanup(func() {
+		*soakMode, *mixSpec, *modelSwitch = savedSoak, savedMix, savedSwitch
+		*onlyCells, *skipCells, *expectedTPS, *jsonlFile = savedOnly, savedSkip, savedTPS, savedJSONL
+	})
+
+	tests := []struct {
+		soak, modelSwitch bool
+		mix               string
+		only, skip, tps   string
+		jsonl             string
+		wantErr           bool
+	}{
+		{only: "model=a"},
+		{soak: true},
+		{mix: "a=1"},
+		{soak: true, only: "model=a", wantErr: true},
+		{mix: "a=1", skip: "concurrency=4", wantErr: true},
+		{soak: true, tps: "a=45"},
+		{modelSwitch: true},
+		{modelSwitch: true, tps: "a=45", wantErr: true},
+		{jsonl: "results.jsonl"},
+		{mix: "a=1", jsonl: "results.jsonl", wantErr: true},
+	}
+	for _, tt := range tests {
+		*soakMode, *mixSpec, *modelSwitch = tt.soak, tt.mix, tt.modelSwitch
+		*onlyCells, *skipCells, *expectedTPS, *jsonlFile = tt.only, tt.skip, tt.tps, tt.jsonl
+		if err := validateSpecialMode(); (err != nil) != tt.wantErr {
+			t.Errorf("validateSpecialMode() with -soak=%v -model-switch=%v -mix=%q -only=%q -skip=%q -expected-tps=%q -jsonl=%q: error = %v, wantErr %v",
+				tt.soak, tt.modelSwitch, tt.mix, tt.only, tt.skip, tt.tps, tt.jsonl, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSnapshotFirstError(t *testing.T) {
+	stats := cellStats{errorCounts: make(map[string]int)}
+	var last cellStats
+
+	stats.record(requestOutcome{}, errors.New("connection refused"))
+	stats.record(requestOutcome{schemaError: "unknown field \"x\""}, nil)
+	if w := stats.snapshot(&last); w.firstError != "connection refused" || w.schemaError != "unknown field \"x\"" {
+		t.Errorf("first window: firstError = %q, schemaError = %q", w.firstError, w.schemaError)
+	}
+
+	stats.record(requestOutcome{}, nil)
+	if w := stats.snapshot(&last); w.firstError != "" || w.schemaError != "" {
+		t.Errorf("window without errors: firstError = %q, schemaError = %q, want none", w.firstError, w.schemaError)
+	}
+
+	stats.record(requestOutcome{}, errors.New("model not found"))
+	stats.record(requestOutcome{}, errors.New("connection refused"))
+	w := stats.snapshot(&last)
+	if w.firstError != "model not found" || w.errorCounts["model not found"] != 1 || w.errorCounts["connection refused"] != 1 {
+		t.Errorf("third window: firstError = %q, errorCounts = %v", w.firstError, w.errorCounts)
+	}
+	if stats.firstError != "connection refused" {
+		t.Errorf("whole test firstError = %q, want the first error of the run", stats.firstError)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runMerge 实现 merge 子命令: 合并多次运行的 JSON 结果, 每条结果用来源文件名标记所属的运行
+func runMerge(args []string) int {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := fs.String("o", "", "合并结果写入的文件, 为空时输出到标准输出")
+	dedupeCells := fs.Bool("dedupe", false, "相同 后端+模型+并发数 的结果只保留第一次出现的, 默认全部保留并以运行标记区分")
+
+	// 允许参数出现在文件名之后, 如 merge a.json b.json -o merged.json
+	var files []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(files) < 2 {
+		fmt.Println(msg("usage_error"), errors.New(msg("merge_usage")))
+		return exitUsage
+	}
+
+	merged, err := mergeResultFiles(files, *dedupeCells)
+	if err != nil {
+		fmt.Println(msg("usage_error"), err)
+		return exitUsage
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Println(msg("output_error"), err)
+			return exitOutput
+		}
+		defer f.Close()
+		out = f
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(merged); err != nil {
+		fmt.Println(msg("output_error"), err)
+		return exitOutput
+	}
+	return exitOK
+}
+
+func mergeResultFiles(files []string, dedupeCells bool) (resultsDocument, error) {
+	merged := resultsDocument{SchemaVersion: resultsSchemaVersion}
+	seen := make(map[resultKey]bool)
+
+	for _, file := range files {
+		doc, err := readResultsDocument(file)
+		if err != nil {
+			return merged, err
+		}
+
+		runID := doc.Metadata.RunID
+		if runID == "" {
+			runID = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+
+		if len(doc.Runs) > 0 {
+			// 已经合并过的文件, 保留其中各次运行的信息
+			merged.Runs = append(merged.Runs, doc.Runs...)
+		} else {
+			doc.Metadata.RunID = runID
+			merged.Runs = append(merged.Runs, doc.Metadata)
+		}
+		mergeMetadata(&merged.Metadata, doc.Metadata)
+
+		for _, r := range doc.Results {
+			if r.Run == "" {
+				r.Run = runID
+			}
+			key := keyOf(r)
+			if dedupeCells && seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged.Results = append(merged.Results, r)
+		}
+	}
+
+	return merged, nil
+}
+
+func readResultsDocument(file string) (resultsDocument, error) {
+	var doc resultsDocument
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return doc, err
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return doc, fmt.Errorf("%s: %w", file, err)
+	}
+	if doc.SchemaVersion != resultsSchemaVersion {
+		return doc, fmt.Errorf(msg("schema_mismatch"), file, doc.SchemaVersion, resultsSchemaVersion)
+	}
+	return doc, nil
+}
+
+// mergeMetadata 合并后的时间范围覆盖所有运行, 后端取并集
+func mergeMetadata(dst *runMetadata, src runMetadata) {
+	if dst.StartedAt.IsZero() || src.StartedAt.Before(dst.StartedAt) {
+		dst.StartedAt = src.StartedAt
+	}
+	if src.FinishedAt.After(dst.FinishedAt) {
+		dst.FinishedAt = src.FinishedAt
+	}
+	if dst.Hostname == "" {
+		dst.Hostname = src.Hostname
+	} else if dst.Hostname != src.Hostname {
+		dst.Hostname = "multiple"
+	}
+	backends, _ := dedupe(append(dst.Backends, src.Backends...))
+	dst.Backends = backends
+}
@@ -0,0 +1,718 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var langFlag = flag.String("lang", "auto", "输出语言: zh, en 或 auto (根据 LANG 环境变量自动选择)")
+
+// lang 为当前使用的语言, 由 setupLang 在解析参数后设置
+var lang = "zh"
+
+// messages 集中存放所有输出文本, 新增语言时只需在这里添加一组翻译;
+// 缺失的翻译回退到中文
+var messages = map[string]map[string]string{
+	"zh": {
+		"usage_error":            "参数错误:",
+		"unknown_backend":        "未知的后端: %s",
+		"no_backend":             "至少需要指定一个后端",
+		"unknown_lang":           "不支持的语言: %s",
+		"no_total_vram":          "无法获取显卡总显存, 跳过显存容量检查:",
+		"vram_warning":           "警告: 模型 %s 预计需要约 %.0fMB 显存, 超过显卡总显存 %.0fMB, 可能部分卸载到 CPU 运行\n",
+		"testing":                "正在测试后端: %s, 模型: %s, 并发数: %d\n",
+		"soak_start":             "开始稳定性测试: 后端 %s, 模型 %s, 并发数 %d, 时长 %s, 快照间隔 %s\n",
+		"soak_snapshot":          "\n快照 #%d (已运行 %s):\n",
+		"soak_summary":           "\n稳定性测试汇总:",
+		"request_failed":         "[C-%d] [%s] [%s]请求耗时:%d  请求失败: %v\n",
+		"request_done":           "[C-%d] [%s] [%s]请求耗时:%d  response size: %d\n",
+		"request_raw":            "[C-%d] [%s] [%s]请求耗时:%d   response:\n%s\n",
+		"status_error_msg":       "非200状态码: %d: %s",
+		"status_error":           "非200状态码: %d",
+		"response_too_big":       "响应体超过大小限制",
+		"results_header":         "后端\t模型\t并发数\tCPU负载(%)\tGPU负载(%)\t显存使用(MB)\t显存占比(%)\t显存增量(MB)\t内存使用(%)\t平均响应(ms)\t最大响应(ms)\t最小响应(ms)\t成功率(%)\t超大响应数\t可能卸载到CPU\t压缩节省(KB)\t冷启动(ms)\t空响应数\t平均输出token\t触顶率(%)\t估计排队(ms)\t",
+		"yes":                    "是",
+		"no":                     "否",
+		"error_detail":           "\n错误明细 [%s] %s 并发 %d:\n",
+		"error_count":            "  %d 次: %s\n",
+		"comparison_title":       "\n后端对比 (差值相对于 %s):\n",
+		"live_status":            "[实时] RPS: %.2f  平均响应(10s): %.1fms  成功率: %.1f%%",
+		"unknown_output":         "不支持的输出格式: %s",
+		"output_error":           "写入结果失败:",
+		"between_cmd_run":        "执行冷却期命令: %s\n",
+		"between_cmd_timeout":    "警告: 冷却期命令超时 (%s)\n",
+		"between_cmd_failed":     "警告: 冷却期命令执行失败:",
+		"comparison_header":      "模型\t并发数\t后端\t平均响应(ms)\t差值\t最大响应(ms)\t差值\t成功率(%)\t差值\tGPU负载(%)\t差值\t显存使用(MB)\t差值\t",
+		"cold_start_unsupported": "后端 %s 不支持卸载模型, 跳过冷启动测试\n",
+		"cold_start_failed":      "冷启动测试失败:",
+		"cold_start_done":        "[%s] %s 冷启动耗时: %.1fms\n",
+
+		"bad_concurrency_range": "无效的并发数范围: %s",
+		"no_concurrency":        "至少需要指定一个并发数",
+		"bad_positive_int":      "需要正整数: %q",
+
+		"bad_cpu_list":         "无效的 CPU 列表: %s",
+		"affinity_unsupported": "当前系统不支持设置 CPU 亲和性",
+		"affinity_failed":      "警告: 设置 CPU 亲和性失败:",
+
+		"empty_warning":  "警告: [%s] %s 并发 %d 有 %d 个请求返回了空响应, 其耗时%s计入响应时间统计\n",
+		"empty_included": "已",
+		"empty_excluded": "未",
+
+		"duplicate_models":      "已移除重复的模型: %s\n",
+		"duplicate_concurrency": "已移除重复的并发数: %v\n",
+		"no_models":             "至少需要指定一个模型",
+
+		"tui_title":   "模型压力测试  已完成 %d/%d 组\n\n",
+		"tui_current": "  当前: %s  请求 %d  成功 %d\n",
+
+		"batch_unsupported": "后端 %s 不支持批量请求 (-batch-size > 1)",
+		"batch_title":       "\n批量请求 (每个请求 %d 条提示词):\n",
+		"batch_header":      "后端\t模型\t并发数\t批响应(ms)\t摊销响应(ms)\t吞吐(条/秒)\t",
+
+		"merge_usage":     "用法: merge [-o merged.json] [-dedupe] a.json b.json ...",
+		"schema_mismatch": "%s 的结果版本为 %d, 当前版本为 %d, 无法合并",
+
+		"bad_method": "无效的 HTTP 方法: %s",
+		"bad_path":   "-path 必须以 / 开头: %s",
+
+		"strict_failed": "严格模式: [%s] %s 并发 %d 出现失败请求, 停止测试: %s\n",
+
+		"interrupted": "\n收到中断信号, 停止测试并输出已完成的结果 (再次按 Ctrl-C 强制退出)\n",
+
+		"bad_histogram_buckets": "无效的直方图分桶: %s",
+		"histogram_title":       "\n响应时间分布 [%s] %s 并发 %d:\n",
+
+		"untagged_prompt":    "-complexity tag 要求每条提示词都带 [标签], 缺少标签: %s",
+		"unknown_complexity": "不支持的难度分级方式: %s",
+		"complexity_testing": "正在测试后端: %s, 模型: %s, 难度: %s (%d 条提示词)\n",
+		"complexity_title":   "\n按难度分级:",
+		"complexity_header":  "后端\t模型\t难度\t平均长度(字符)\t平均响应(ms)\t最大响应(ms)\t成功率(%)\t平均输出token\t",
+
+		"warmup_title":  "\n预热与稳定阶段 (前 %d 个成功请求计为预热):\n",
+		"warmup_header": "后端\t模型\t并发数\t预热平均(ms)\t稳定平均(ms)\t整体平均(ms)\t",
+
+		"system_unsupported":   "后端 %s 不支持系统提示词 (-system 仅适用于 ollama-chat)",
+		"no_prompt_tokens":     "响应中没有 prompt_eval_count",
+		"system_tokens_failed": "警告: 统计系统提示词 token 数失败:",
+		"system_tokens_done":   "[%s] %s 系统提示词约 %d 个输入 token\n",
+
+		"metric_unavailable": "警告: 无法采集资源指标 %s, 该项将被跳过\n",
+
+		"bad_cell_filter": "无效的过滤条件: %q (支持 backend=, model=, concurrency=)",
+		"no_cells":        "没有匹配 -only/-skip 的测试组",
+
+		"oom_warning": "\n!!! 警告: [%s] %s 并发 %d 出现 %d 次显存不足/CUDA 错误, 该模型或配置可能超出硬件能力, 请减小模型、上下文或并发数 !!!\n\n",
+
+		"cell_extended": "[%s] %s 并发 %d 样本不足或置信区间过宽, 测试已延长到 %s\n",
+
+		"quant_title":  "\n量化版本对比 (速度以组内最快为 100%, 生成质量需自行评估):",
+		"quant_header": "基础模型\t后端\t并发数\t模型\t量化\t平均响应(ms)\t相对速度\t显存使用(MB)\t显存差值\t成功率(%)\t",
+
+		"terminated":    "\n收到 SIGTERM, 停止测试并输出已完成的结果 (最多等待 %s)\n",
+		"grace_expired": "\n超过退出宽限期 %s, 强制退出\n",
+
+		"bad_expected_tps":  "无效的预期吞吐: %q (格式为 模型=token每秒)",
+		"efficiency_title":  "\n实际与预期吞吐 (低于 %.0f%% 标记为性能不足):\n",
+		"efficiency_header": "后端\t模型\t并发数\t实际(token/s)\t预期(token/s)\t效率\t\t",
+		"underperforming":   "性能不足",
+
+		"straggler_title": "\n各并发的平均响应 [%s] %s 并发 %d (中位数 %.1fms):\n",
+		"straggler_row":   "  C-%d: %.1fms%s\n",
+		"straggler_mark":  "  <- 偏离中位数超过阈值",
+
+		"stdin_empty": "标准输入是管道但没有提示词 (空行会被忽略); 不想从标准输入读取时请重定向 < /dev/null",
+
+		"unknown_response_format": "不支持的响应格式: %s (json 或 text)",
+		"template_batch":          "-body-template 不支持批量请求 (-batch-size > 1)",
+
+		"totals": "\n合计: 请求 %d 个, 成功 %d 个, 接收 %s, 总耗时 %s\n",
+
+		"background_testing": "正在测试后端: %s, 模型: %s, 并发数: %d (后台批量请求 %d 个)\n",
+		"background_title":   "\n后台批量负载的影响 (后台并发 %d):\n",
+		"background_header":  "后端\t模型\t并发数\t前台基线(ms)\t前台混合(ms)\t延迟增幅\t后台平均(ms)\t后台成功率(%)\t后台吞吐(token/s)\t后台请求数\t",
+
+		"influx_failed": "推送到 InfluxDB 失败:",
+
+		"prompt_spread_title":    "\n提示词敏感度 (各提示词平均响应的离散程度):",
+		"prompt_spread_header":   "后端\t模型\t提示词数\t最小(ms)\t最大(ms)\t标准差(ms)\t变异系数(%)\t",
+		"complexity_and_compare": "-complexity 和 -compare-prompts 不能同时使用",
+
+		"worker_stopped":  "[%s] %s 并发 %d: C-%d 连续失败 %d 次, 停止该并发 (最后的错误: %v)\n",
+		"stopped_workers": "  因连续失败停止的并发: %d/%d\n",
+
+		"stream_conflict": "-stream 不能与 -batch-size、-body-template 或 -response-format text 同时使用",
+		"stream_title":    "\n流式输出 (首 token 延迟与 token 间隔):",
+		"stream_header":   "后端\t模型\t并发数\t平均TTFT(ms)\tTTFT p95(ms)\t平均ITL(ms)\tITL p95(ms)\t",
+
+		"bad_endpoint":   "%s 的接口地址无效: %q (需要 http:// 或 https:// 开头的完整地址)",
+		"bad_output_dir": "-output-file %s 所在的目录不存在",
+
+		"bad_sla":      "-sla-p95 和 -sla-max-concurrency 必须大于 0",
+		"sla_conflict": "-sla-p95 不能与 -complexity、-compare-prompts 或 -soak 同时使用",
+		"sla_testing":  "[%s] %s 搜索并发数: 测试并发 %d\n",
+		"sla_title":    "\n满足 p95 ≤ %v 的最佳并发数 (吞吐最高):\n",
+		"sla_header":   "后端\t模型\t最佳并发\tp95(ms)\t平均响应(ms)\t成功率(%)\t吞吐(请求/秒)\t吞吐(token/秒)\t已测组数\t",
+
+		"warmup_stable":     "[%s] %s 并发 %d: 预热 %v 后延迟已稳定\n",
+		"warmup_unstable":   "[%s] %s 并发 %d: 预热 %v 后延迟仍未稳定 (达到 -max-warmup 或出现失败请求), 开始正式测试\n",
+		"bad_warmup_stable": "-warmup-window 必须大于 0, -warmup-tolerance 必须为正数",
+
+		"unknown_token_estimator": "未知的 token 估算方式: %q (可选 words、approx)",
+		"tokens_estimated_note":   "~ 表示部分请求的 token 数由客户端估算 (-estimate-tokens %s), 服务端未报告\n",
+
+		"resume_mismatch":       "-resume 文件中的运行配置与当前参数不一致: %s",
+		"resume_empty":          "%s 中没有完整的首行运行配置",
+		"resume_jsonl_conflict": "-resume 会继续追加到原文件, 不能同时指定不同的 -jsonl",
+		"resume_skipping":       "续跑: 跳过已完成的 %d 组, 剩余 %d 组\n",
+		"jsonl_failed":          "警告: 写入 -jsonl 结果文件失败:",
+
+		"no_images":          "目录 %s 中没有图片 (png/jpg/jpeg/webp/gif)",
+		"images_conflict":    "-images-dir 不能与 -body-template 或 -batch-size > 1 同时使用",
+		"images_unsupported": "后端 %s 不支持附带图片 (-images-dir)",
+		"image_title":        "\n按图片统计 [%s] %s 并发 %d:\n",
+		"image_header":       "图片\t大小\t成功请求数\t平均响应(ms)\t",
+
+		"testing_cpu":     "  (num_gpu: 0, 只用 CPU)\n",
+		"cpu_template":    "-compare-against-cpu 不能与 -body-template 同时使用",
+		"cpu_unsupported": "后端 %s 不支持 -compare-against-cpu (仅 ollama)",
+		"cpu_title":       "\nGPU 与 CPU 对比 (num_gpu: 0):",
+		"cpu_header":      "后端\t模型\t并发数\tGPU平均响应(ms)\tCPU平均响应(ms)\t延迟倍数\tGPU吞吐(token/秒)\tCPU吞吐(token/秒)\t吞吐倍数\t",
+
+		"cooldown_vram": "  冷却 %v: 显存 %.0fMB (%+.0fMB)\n",
+
+		"bad_timeout":   "无效的请求超时设置: %q (应为 时长 或 模型=时长, 如 60s 或 deepseek-r1:32b=5m)",
+		"timeout_count": "  超时的请求: %d (超时时间 %v)\n",
+
+		"eta":     "进度: 已完成 %d/%d 组, 预计剩余 %s, 约 %s 完成\n",
+		"tui_eta": "预计剩余 %s, 约 %s 完成\n\n",
+
+		"trace_enabled": "逐个请求记录写入 %s (追加), 高吞吐时文件会很大\n",
+		"trace_failed":  "写入请求记录失败:",
+		"trace_dropped": "请求记录写入跟不上, 已丢弃 %d 条\n",
+
+		"switch_models":     "-model-switch 需要在 -models 中指定至少两个模型",
+		"bad_switch_rounds": "-switch-rounds 必须大于 0",
+		"switch_conflict":   "-model-switch 不能与 -complexity、-compare-prompts、-soak 或 -sla-p95 同时使用",
+		"switch_output":     "-model-switch 不支持 -output %s",
+		"switch_failed":     "模型切换测试请求失败:",
+		"switch_start":      "正在测试模型切换: 后端 %s, %d 个模型轮换 %d 轮\n",
+		"switch_title":      "\n模型切换开销:",
+		"switch_header":     "后端\t模型\t切换次数\t失败数\t切换后首个请求(ms)\t常驻时请求(ms)\t平均切换代价(ms)\t最大切换代价(ms)\t",
+		"switch_summary":    "后端 %s 平均每次切换增加 %.1fms (共 %d 次)\n",
+
+		"bad_percentile": "无效的百分位 %q: 必须是 (0,100) 之间的数",
+
+		"column_rps":     "吞吐(请求/秒)",
+		"unknown_column": "未知的列 %q, 可用的列: %s",
+
+		"ready_waiting": "等待后端 %s 就绪 (%s)...\n",
+		"ready_timeout": "后端 %s 在 %v 内未就绪\n",
+		"ready_done":    "后端已就绪, 等待 %v\n",
+
+		"sample_drift_warning": "警告: 后端 %s 模型 %s 并发 %d 有 %d 个资源采样间隔明显偏离 1s (最大偏差 %.0fms), 压测端可能占满了 CPU, 资源数据可能不可靠; 可用 -gomaxprocs 或 -cpu-affinity 为采样留出 CPU\n",
+
+		"capacity_title":  "\n容量上限 (成功率低于 %.1f%% 的第一个并发数):\n",
+		"capacity_header": "后端\t模型\t最高正常并发\t容量上限\t上限处成功率(%)\t",
+		"capacity_none":   "> %d (未达到)",
+
+		"bad_capacity_threshold": "-capacity-threshold 必须在 (0,100] 之间",
+
+		"bad_mix":      "无效的 -mix 项 %q, 应为 模型=权重 (权重为正整数, 模型不能重复)",
+		"mix_models":   "-mix 需要至少两个模型",
+		"mix_conflict": "-mix 不能与 -complexity、-compare-prompts、-soak、-sla-p95、-model-switch 或 -compare-against-cpu 同时使用",
+		"mix_testing":  "正在进行混合测试: 后端 %s, 总并发 %d, 模型 %s\n",
+		"mix_title":    "\n混合测试合计 (各模型同时运行):",
+		"mix_header":   "后端\t混合窗口\t模型数\t总并发\t请求数\t成功率(%)\t平均响应(ms)\t吞吐(请求/秒)\t吞吐(token/秒)\tCPU负载(%)\tGPU负载(%)\t显存使用(MB)\t",
+
+		"serve_unknown_flag":     "未知的参数 %q",
+		"serve_flag_not_allowed": "参数 %q 不能通过服务设置, 只能在启动服务的命令行中设置",
+		"serve_queue_full":       "排队的运行过多, 请稍后再试",
+		"serve_not_found":        "没有该运行",
+		"serve_not_finished":     "运行尚未结束 (%s)",
+		"serve_no_results":       "该运行没有生成结果",
+		"serve_listening":        "测试服务监听 %s\n",
+		"serve_failed":           "测试服务启动失败:",
+		"serve_run_start":        "开始运行 %s: %v\n",
+		"serve_run_done":         "运行 %s 结束, 退出码 %d, 用时 %v\n",
+
+		"dataset_bad_line":    "%s 第 %d 行不是有效的 JSON: %v",
+		"dataset_no_prompt":   "%s 第 %d 行缺少 prompt 字段或 prompt 为空",
+		"dataset_empty":       "%s 中没有提示词",
+		"dataset_and_prompts": "-dataset 不能与 -prompts-file 同时使用",
+		"sampling_and_fixed":  "-prompt-sampling cycle/shuffle 不能与 -fixed-prompt-sequence 同时使用",
+		"bad_prompt_sampling": "无效的 -prompt-sampling: %s, 可选 random、cycle、shuffle",
+		"dataset_usage":       "[%s] %s ×%d: 使用了 %d / %d 条不同的提示词 (共发送 %d 条)\n",
+
+		"single_core_warning": "[%s] %s ×%d: 负载集中在单个 CPU 核心 (该核心平均 %.1f%%, 整体峰值 %.1f%%), 可能存在单线程瓶颈\n",
+		"column_busy_cores":   "忙碌核心",
+		"column_hot_core":     "最忙核心(%)",
+
+		"bad_webhook":    "无效的 -webhook 地址: %s, 需要 http:// 或 https:// 开头",
+		"webhook_failed": "推送到 -webhook 失败:",
+
+		"bad_cache_requests": "-cache-check-requests 至少为 2",
+		"cache_check_failed": "缓存检测请求失败:",
+		"cache_suspected":    "[%s] %s: 疑似存在响应缓存: 相同请求中位耗时 %.1fms, 附加随机串时 %.1fms (相同请求耗时变异系数 %.1f%%); 结果可能偏好, 可使用 -prompt-nonce\n",
+		"cache_not_detected": "[%s] %s: 未发现响应缓存 (相同请求中位耗时 %.1fms, 附加随机串时 %.1fms)\n",
+
+		"predict_values":           "-predict-sweep 至少需要两个不同的 num_predict",
+		"bad_predict_concurrency":  "-predict-concurrency 必须大于 0",
+		"predict_conflict":         "-predict-sweep 不能与 -complexity、-compare-prompts、-soak、-sla-p95、-model-switch 或 -mix 同时使用",
+		"predict_testing":          "测试后端: %s, 模型: %s, num_predict: %d, 并发数: %d\n",
+		"predict_title":            "\n输出长度扫描:",
+		"predict_header":           "后端\t模型\tnum_predict\t平均输出token\t平均响应(ms)\t成功率(%)\t吞吐(token/s)\t",
+		"predict_no_fit":           "没有可拟合的结果 (需要至少两组成功且输出 token 数不同的测试)",
+		"predict_fit_title":        "\n响应时间与输出 token 数的线性拟合:",
+		"predict_fit_header":       "后端\t模型\t组数\t每token(ms)\t固定开销(ms)\tR²\t横轴\t",
+		"predict_source_reported":  "实际生成 token 数",
+		"predict_source_requested": "请求的 num_predict",
+
+		"no_results":             "没有任何测试组的结果: ",
+		"no_results_interrupted": "第一组测试完成前已被中断",
+		"no_results_filtered":    "所有测试组都被 -only/-skip 排除, 请检查过滤条件中的模型名、后端和并发数",
+		"no_results_unknown":     "没有运行任何测试组, 请检查 -models、-concurrency 和后端设置",
+
+		"bad_embed_concurrency":  "-embed-concurrency 必须大于 0",
+		"embed_conflict":         "-embed-model 不能与 -complexity、-compare-prompts、-soak、-sla-p95、-model-switch、-mix 或 -predict-sweep 同时使用",
+		"embed_request_conflict": "-embed-model 不能与 -body-template、-path、-stream、-images-dir 或 -response-format text 同时使用",
+		"embed_testing_alone":    "单独测试嵌入负载: %s, 模型: %s, 并发数: %d\n",
+		"embed_testing_mixed":    "混合负载测试: %s, 生成 %s ×%d 与嵌入 %s ×%d 同时运行\n",
+		"embed_title":            "\n嵌入与生成混合负载 (与单独运行对比):",
+		"embed_header":           "后端\t负载\t模型\t并发数\t同时运行\t单独平均(ms)\t混合平均(ms)\t延迟变化(%)\t单独p95(ms)\t混合p95(ms)\t单独RPS\t混合RPS\t",
+		"workload_generate":      "生成",
+		"workload_embed":         "嵌入",
+
+		"bad_matrix_duration":   "-max-matrix-duration 不能为负数",
+		"budget_exhausted":      "已用 %[2]s, 剩余时间不足以在 -max-matrix-duration %[1]s 内完成下一组测试, 不再开始新的组\n",
+		"budget_skipped_title":  "\n因 -max-matrix-duration 跳过了 %d 组测试 (上限 %s):\n",
+		"budget_skipped_header": "后端\t模型\t并发数\t",
+
+		"no_results_budget": "-max-matrix-duration 太短, 按预计耗时一组测试也来不及完成 (还没有完成的组时按每组的默认测试时长加冷却时间估算)",
+
+		"strict_json_conflict":    "-strict-json 不能与 -stream 或 -response-format text 同时使用",
+		"strict_json_unsupported": "-strict-json 不支持后端 %s: 没有定义其响应结构",
+		"strict_json_trailing":    "响应体在 JSON 对象之后还有其他内容",
+		"schema_warning":          "警告: [%s] %s 并发 %d 有 %d/%d 个成功响应与预期结构不符, 第一个原因: %s\n",
+
+		"client_gc_warning": "警告: [%s] %s 并发 %d 测试期间压测端 GC %d 次, 停顿共 %.2fms, 最长 %.3fms, 相对平均响应 %.1fms 不可忽略, 延迟数据可能受客户端影响\n",
+		"client_gc_title":   "\n压测端 GC 和内存 (runtime.MemStats, 每组测试前后之差):",
+		"client_gc_header":  "后端\t模型\t并发数\tGC次数\t停顿合计(ms)\t最长停顿(ms)\t堆大小(MB)\t堆变化(MB)\t累计分配(MB)\t",
+
+		"bad_fan_out":      "-fan-out 不能为负数",
+		"fan_out_inflight": "-fan-out 不能与 -inflight 同时使用",
+		"fan_out_title":    "\n扇出延迟 (每轮 %d 个并行请求, 以最慢的请求完成为准):\n",
+		"fan_out_header":   "后端\t模型\t并发数\t单请求平均(ms)\t单请求p95(ms)\t完成轮数\t失败轮数\t每轮平均(ms)\t每轮p50(ms)\t每轮p95(ms)\t每轮p99(ms)\t每轮/单请求\t",
+
+		"bad_cooldown": "-cooldown-concurrency 和 -cooldown-model 不能为负数",
+
+		"error_log_enabled": "失败请求写入 %s (追加)\n",
+		"error_log_failed":  "写入错误日志失败:",
+		"error_log_summary": "共 %d 个失败请求, 详情和各类错误的次数见 %s\n",
+
+		"bad_score_weight":       "-score-weights 中的 %q 无效, 格式为 指标=权重, 权重不能为负数",
+		"unknown_score_metric":   "-score-weights 中的指标 %q 不存在, 可用的指标: %s",
+		"duplicate_score_metric": "-score-weights 中的指标 %q 重复",
+		"score_weights_zero":     "-score-weights 的权重总和必须大于 0",
+		"leaderboard_title":      "\n排行榜 (-score-weights %s, 得分 0-100):\n",
+		"leaderboard_header":     "排名\t后端\t模型\t并发数\t得分\t",
+
+		"prompts_file_empty": "提示词文件 %s 中没有提示词 (空行会被忽略)",
+		"no_prompts":         "没有可用的提示词",
+
+		"testing_no_keepalive":   "  (不复用连接)\n",
+		"keepalive_conflict":     "-compare-keepalive 已包含不复用连接的测试, 不能与 -no-keepalive 同时使用",
+		"keepalive_cpu_conflict": "-compare-keepalive 不能与 -compare-against-cpu 同时使用",
+		"keepalive_title":        "\n复用连接与不复用连接对比 (差值为每个请求重新建立连接增加的耗时):",
+		"keepalive_header":       "后端\t模型\t并发数\t复用平均(ms)\t不复用平均(ms)\t差值(ms)\t复用p95(ms)\t不复用p95(ms)\t差值(ms)\t复用RPS\t不复用RPS\t",
+
+		"serve_unauthorized": "缺少或错误的 token",
+		"serve_no_token":     "警告: 测试服务监听 %s 且没有设置 -serve-token, 能访问该端口的任何人都可以提交运行\n",
+
+		"system_template": "-system 不能与 -body-template 同时使用: 模板替换了整个请求体, 系统提示词不会被发送",
+
+		"per_worker_inflight":          "-per-worker 不能与 -inflight 同时使用: 调度循环的槽位不是固定的并发",
+		"worker_max_failures_inflight": "-worker-max-failures 不能与 -inflight 同时使用: 调度循环的槽位不是固定的并发",
+
+		"too_many_concurrency_levels": "-concurrency %s 展开后超过 %d 个并发数, 请检查范围和步长",
+
+		"cpu_id_too_large": "无效的 CPU 列表 %s: CPU 编号 %d 超出范围, 最大为 %d",
+
+		"mix_shared_resources": "同一窗口的各模型共享一台机器, 结果表中各模型的资源占用都是整个窗口的数值 (JSON 中标记为 shared_resources), 不能用于比较模型",
+
+		"cell_filter_special_mode": "-only 和 -skip 只作用于按模型、后端和并发展开的测试组, 不能与 %s 同时使用",
+
+		"expected_tps_model_switch": "-model-switch 只测量模型切换代价, 不能与 -expected-tps 同时使用",
+
+		"result_log_special_mode": "-jsonl 和 -resume 按测试组记录和续跑, 不能与 %s 同时使用",
+	},
+	"en": {
+		"usage_error":            "Invalid arguments:",
+		"unknown_backend":        "unknown backend: %s",
+		"no_backend":             "at least one backend is required",
+		"unknown_lang":           "unsupported language: %s",
+		"no_total_vram":          "Cannot read total GPU memory, skipping VRAM capacity check:",
+		"vram_warning":           "Warning: model %s needs about %.0fMB of VRAM, more than the GPU's %.0fMB; it will likely be partially offloaded to CPU\n",
+		"testing":                "Testing backend: %s, model: %s, concurrency: %d\n",
+		"soak_start":             "Starting soak test: backend %s, model %s, concurrency %d, duration %s, snapshot interval %s\n",
+		"soak_snapshot":          "\nSnapshot #%d (elapsed %s):\n",
+		"soak_summary":           "\nSoak test summary:",
+		"request_failed":         "[C-%d] [%s] [%s] took:%d  request failed: %v\n",
+		"request_done":           "[C-%d] [%s] [%s] took:%d  response size: %d\n",
+		"request_raw":            "[C-%d] [%s] [%s] took:%d   response:\n%s\n",
+		"status_error_msg":       "non-200 status: %d: %s",
+		"status_error":           "non-200 status: %d",
+		"response_too_big":       "response body exceeds size limit",
+		"results_header":         "Backend\tModel\tConcurrency\tCPU(%)\tGPU(%)\tVRAM(MB)\tVRAM(%)\tVRAM delta(MB)\tMemory(%)\tAvg(ms)\tMax(ms)\tMin(ms)\tSuccess(%)\tOversized\tCPU offload\tSaved(KB)\tCold start(ms)\tEmpty\tAvg tokens\tCapped(%)\tEst. queue(ms)\t",
+		"yes":                    "yes",
+		"no":                     "no",
+		"error_detail":           "\nErrors [%s] %s concurrency %d:\n",
+		"error_count":            "  %d x %s\n",
+		"comparison_title":       "\nBackend comparison (deltas relative to %s):\n",
+		"live_status":            "[live] RPS: %.2f  avg latency (10s): %.1fms  success: %.1f%%",
+		"unknown_output":         "unsupported output format: %s",
+		"output_error":           "Failed to write results:",
+		"between_cmd_run":        "Running cooldown command: %s\n",
+		"between_cmd_timeout":    "Warning: cooldown command timed out (%s)\n",
+		"between_cmd_failed":     "Warning: cooldown command failed:",
+		"comparison_header":      "Model\tConcurrency\tBackend\tAvg(ms)\tDelta\tMax(ms)\tDelta\tSuccess(%)\tDelta\tGPU(%)\tDelta\tVRAM(MB)\tDelta\t",
+		"cold_start_unsupported": "Backend %s cannot unload models, skipping cold-start measurement\n",
+		"cold_start_failed":      "Cold-start measurement failed:",
+		"cold_start_done":        "[%s] %s cold start: %.1fms\n",
+
+		"bad_concurrency_range": "invalid concurrency range: %s",
+		"no_concurrency":        "at least one concurrency level is required",
+		"bad_positive_int":      "expected a positive integer: %q",
+
+		"bad_cpu_list":         "invalid CPU list: %s",
+		"affinity_unsupported": "CPU affinity is not supported on this platform",
+		"affinity_failed":      "Warning: failed to set CPU affinity:",
+
+		"empty_warning":  "Warning: [%s] %s concurrency %d got %d empty responses; their latencies are %s the response-time stats\n",
+		"empty_included": "included in",
+		"empty_excluded": "excluded from",
+
+		"duplicate_models":      "Removed duplicate models: %s\n",
+		"duplicate_concurrency": "Removed duplicate concurrency levels: %v\n",
+		"no_models":             "at least one model is required",
+
+		"tui_title":   "Model load test  %d/%d cells done\n\n",
+		"tui_current": "  Current: %s  requests %d  ok %d\n",
+
+		"batch_unsupported": "backend %s does not support batched requests (-batch-size > 1)",
+		"batch_title":       "\nBatched requests (%d prompts per request):\n",
+		"batch_header":      "Backend\tModel\tConcurrency\tBatch(ms)\tPer item(ms)\tItems/s\t",
+
+		"merge_usage":     "usage: merge [-o merged.json] [-dedupe] a.json b.json ...",
+		"schema_mismatch": "%s has schema version %d, expected %d; cannot merge",
+
+		"bad_method": "invalid HTTP method: %s",
+		"bad_path":   "-path must start with /: %s",
+
+		"strict_failed": "Strict mode: [%s] %s concurrency %d had a failed request, stopping: %s\n",
+
+		"interrupted": "\nInterrupted, stopping and writing completed results (press Ctrl-C again to force quit)\n",
+
+		"bad_histogram_buckets": "invalid histogram buckets: %s",
+		"histogram_title":       "\nResponse time distribution [%s] %s concurrency %d:\n",
+
+		"untagged_prompt":    "-complexity tag requires every prompt to have a [tag]; missing on: %s",
+		"unknown_complexity": "unsupported complexity mode: %s",
+		"complexity_testing": "Testing backend: %s, model: %s, tier: %s (%d prompts)\n",
+		"complexity_title":   "\nBy complexity tier:",
+		"complexity_header":  "Backend\tModel\tTier\tAvg length(chars)\tAvg(ms)\tMax(ms)\tSuccess(%)\tAvg tokens\t",
+
+		"warmup_title":  "\nWarm-up vs steady state (first %d successful requests count as warm-up):\n",
+		"warmup_header": "Backend\tModel\tConcurrency\tWarm-up avg(ms)\tSteady avg(ms)\tOverall avg(ms)\t",
+
+		"system_unsupported":   "backend %s does not support a system prompt (-system only applies to ollama-chat)",
+		"no_prompt_tokens":     "response has no prompt_eval_count",
+		"system_tokens_failed": "Warning: failed to measure system prompt tokens:",
+		"system_tokens_done":   "[%s] %s system prompt adds about %d input tokens\n",
+
+		"metric_unavailable": "Warning: cannot sample resource metric %s; it will be skipped\n",
+
+		"bad_cell_filter": "invalid cell filter: %q (supported: backend=, model=, concurrency=)",
+		"no_cells":        "no test cells match -only/-skip",
+
+		"oom_warning": "\n!!! Warning: [%s] %s concurrency %d hit %d out-of-memory/CUDA errors; the model or configuration likely does not fit this hardware. Try a smaller model, context or concurrency !!!\n\n",
+
+		"cell_extended": "[%s] %s concurrency %d had too few samples or too wide a confidence interval; extended to %s\n",
+
+		"quant_title":  "\nQuantization comparison (speed relative to the fastest variant in the group; judge output quality separately):",
+		"quant_header": "Base model\tBackend\tConcurrency\tModel\tQuant\tAvg(ms)\tRel. speed\tVRAM(MB)\tVRAM delta\tSuccess(%)\t",
+
+		"terminated":    "\nReceived SIGTERM, stopping and writing completed results (waiting at most %s)\n",
+		"grace_expired": "\nShutdown grace period of %s expired, exiting now\n",
+
+		"bad_expected_tps":  "invalid expected throughput: %q (use model=tokens_per_second)",
+		"efficiency_title":  "\nAchieved vs expected throughput (below %.0f%% is flagged):\n",
+		"efficiency_header": "Backend\tModel\tConcurrency\tAchieved(tok/s)\tExpected(tok/s)\tEfficiency\t\t",
+		"underperforming":   "UNDERPERFORMING",
+
+		"straggler_title": "\nPer-worker average latency [%s] %s concurrency %d (median %.1fms):\n",
+		"straggler_row":   "  C-%d: %.1fms%s\n",
+		"straggler_mark":  "  <- deviates from the median beyond the threshold",
+
+		"stdin_empty": "stdin is a pipe but contains no prompts (blank lines are ignored); redirect < /dev/null to not read prompts from stdin",
+
+		"unknown_response_format": "unsupported response format: %s (json or text)",
+		"template_batch":          "-body-template does not support batched requests (-batch-size > 1)",
+
+		"totals": "\nTotal: %d requests, %d succeeded, %s received, wall-clock %s\n",
+
+		"background_testing": "Testing backend: %s, model: %s, concurrency: %d (with %d background bulk requests)\n",
+		"background_title":   "\nImpact of background bulk load (%d background requests):\n",
+		"background_header":  "Backend\tModel\tConcurrency\tForeground alone(ms)\tForeground mixed(ms)\tImpact\tBackground avg(ms)\tBackground success(%)\tBackground tok/s\tBackground requests\t",
+
+		"influx_failed": "Failed to push to InfluxDB:",
+
+		"prompt_spread_title":    "\nPrompt sensitivity (spread of per-prompt average latency):",
+		"prompt_spread_header":   "Backend\tModel\tPrompts\tMin(ms)\tMax(ms)\tStddev(ms)\tCV(%)\t",
+		"complexity_and_compare": "-complexity and -compare-prompts cannot be used together",
+
+		"worker_stopped":  "[%s] %s concurrency %d: C-%d failed %d times in a row, stopping it (last error: %v)\n",
+		"stopped_workers": "  Workers stopped after consecutive failures: %d/%d\n",
+
+		"stream_conflict": "-stream cannot be combined with -batch-size, -body-template or -response-format text",
+		"stream_title":    "\nStreaming (time to first token and inter-token latency):",
+		"stream_header":   "Backend\tModel\tConcurrency\tAvg TTFT(ms)\tTTFT p95(ms)\tAvg ITL(ms)\tITL p95(ms)\t",
+
+		"bad_endpoint":   "invalid endpoint for %s: %q (expected a full http:// or https:// URL)",
+		"bad_output_dir": "directory of -output-file %s does not exist",
+
+		"bad_sla":      "-sla-p95 and -sla-max-concurrency must be positive",
+		"sla_conflict": "-sla-p95 cannot be combined with -complexity, -compare-prompts or -soak",
+		"sla_testing":  "[%s] %s concurrency search: testing concurrency %d\n",
+		"sla_title":    "\nBest concurrency meeting p95 <= %v (highest throughput):\n",
+		"sla_header":   "Backend\tModel\tBest concurrency\tp95(ms)\tAvg(ms)\tSuccess(%)\tThroughput(req/s)\tThroughput(token/s)\tCells tested\t",
+
+		"warmup_stable":     "[%s] %s concurrency %d: latency stable after %v of warmup\n",
+		"warmup_unstable":   "[%s] %s concurrency %d: latency not stable after %v of warmup (hit -max-warmup or a request failed), starting measurement\n",
+		"bad_warmup_stable": "-warmup-window must be at least 1 and -warmup-tolerance must be positive",
+
+		"unknown_token_estimator": "unknown token estimator: %q (expected words or approx)",
+		"tokens_estimated_note":   "~ marks token counts partly estimated on the client (-estimate-tokens %s) because the server did not report them\n",
+
+		"resume_mismatch":       "settings differ from the -resume file: %s",
+		"resume_empty":          "%s has no complete header line",
+		"resume_jsonl_conflict": "-resume appends to the same file; a different -jsonl cannot be given",
+		"resume_skipping":       "Resuming: skipping %d completed cells, %d remaining\n",
+		"jsonl_failed":          "Warning: failed to write the -jsonl results file:",
+
+		"no_images":          "no images (png/jpg/jpeg/webp/gif) in %s",
+		"images_conflict":    "-images-dir cannot be combined with -body-template or -batch-size > 1",
+		"images_unsupported": "backend %s does not support images (-images-dir)",
+		"image_title":        "\nPer-image latency [%s] %s concurrency %d:\n",
+		"image_header":       "Image\tSize\tSuccessful requests\tAvg(ms)\t",
+
+		"testing_cpu":     "  (num_gpu: 0, CPU only)\n",
+		"cpu_template":    "-compare-against-cpu cannot be combined with -body-template",
+		"cpu_unsupported": "backend %s does not support -compare-against-cpu (ollama only)",
+		"cpu_title":       "\nGPU vs CPU (num_gpu: 0):",
+		"cpu_header":      "Backend\tModel\tConcurrency\tGPU avg(ms)\tCPU avg(ms)\tLatency ratio\tGPU token/s\tCPU token/s\tThroughput ratio\t",
+
+		"cooldown_vram": "  Cooldown %v: VRAM %.0fMB (%+.0fMB)\n",
+
+		"bad_timeout":   "invalid request timeout: %q (expected a duration or model=duration, e.g. 60s or deepseek-r1:32b=5m)",
+		"timeout_count": "  Timed out requests: %d (timeout %v)\n",
+
+		"eta":     "Progress: %d/%d cells done, about %s left, ETA %s\n",
+		"tui_eta": "About %s left, ETA %s\n\n",
+
+		"trace_enabled": "Writing per-request trace to %s (appending); this file grows quickly at high throughput\n",
+		"trace_failed":  "Failed to write request trace:",
+		"trace_dropped": "Request trace could not keep up, dropped %d records\n",
+
+		"switch_models":     "-model-switch needs at least two models in -models",
+		"bad_switch_rounds": "-switch-rounds must be greater than 0",
+		"switch_conflict":   "-model-switch cannot be combined with -complexity, -compare-prompts, -soak or -sla-p95",
+		"switch_output":     "-model-switch does not support -output %s",
+		"switch_failed":     "Model switch request failed:",
+		"switch_start":      "Testing model switching: backend %s, rotating %d models for %d rounds\n",
+		"switch_title":      "\nModel switch overhead:",
+		"switch_header":     "Backend\tModel\tSwitches\tFailures\tAfter switch (ms)\tResident (ms)\tAvg penalty (ms)\tMax penalty (ms)\t",
+		"switch_summary":    "Backend %s: each switch adds %.1fms on average (%d switches)\n",
+
+		"bad_percentile": "Invalid percentile %q: must be a number in (0,100)",
+
+		"column_rps":     "Throughput (req/s)",
+		"unknown_column": "Unknown column %q, available columns: %s",
+
+		"ready_waiting": "Waiting for backend %s to be ready (%s)...\n",
+		"ready_timeout": "Backend %s was not ready within %v\n",
+		"ready_done":    "Backends ready after %v\n",
+
+		"sample_drift_warning": "Warning: backend %s model %s concurrency %d had %d resource sample intervals far from 1s (max drift %.0fms); the load generator may be saturating the CPU and resource numbers may be unreliable. Use -gomaxprocs or -cpu-affinity to leave CPU for sampling\n",
+
+		"capacity_title":  "\nCapacity limit (first concurrency with success rate below %.1f%%):\n",
+		"capacity_header": "Backend\tModel\tMax healthy concurrency\tCapacity limit\tSuccess at limit (%)\t",
+		"capacity_none":   "> %d (not reached)",
+
+		"bad_capacity_threshold": "-capacity-threshold must be in (0,100]",
+
+		"bad_mix":      "Invalid -mix entry %q, expected model=weight (positive integer weight, no duplicate models)",
+		"mix_models":   "-mix needs at least two models",
+		"mix_conflict": "-mix cannot be combined with -complexity, -compare-prompts, -soak, -sla-p95, -model-switch or -compare-against-cpu",
+		"mix_testing":  "Running mixed test: backend %s, total concurrency %d, models %s\n",
+		"mix_title":    "\nMixed test totals (models running at the same time):",
+		"mix_header":   "Backend\tWindow\tModels\tConcurrency\tRequests\tSuccess(%)\tAvg(ms)\tThroughput (req/s)\tThroughput (tokens/s)\tCPU(%)\tGPU(%)\tVRAM(MB)\t",
+
+		"serve_unknown_flag":     "Unknown flag %q",
+		"serve_flag_not_allowed": "Flag %q cannot be set through the server; set it on the server command line instead",
+		"serve_queue_full":       "Too many queued runs, try again later",
+		"serve_not_found":        "No such run",
+		"serve_not_finished":     "Run has not finished yet (%s)",
+		"serve_no_results":       "The run produced no results",
+		"serve_listening":        "Benchmark server listening on %s\n",
+		"serve_failed":           "Benchmark server failed:",
+		"serve_run_start":        "Starting run %s: %v\n",
+		"serve_run_done":         "Run %s finished with exit code %d in %v\n",
+
+		"dataset_bad_line":    "%s line %d is not valid JSON: %v",
+		"dataset_no_prompt":   "%s line %d has no prompt field or the prompt is empty",
+		"dataset_empty":       "%s contains no prompts",
+		"dataset_and_prompts": "-dataset cannot be combined with -prompts-file",
+		"sampling_and_fixed":  "-prompt-sampling cycle/shuffle cannot be combined with -fixed-prompt-sequence",
+		"bad_prompt_sampling": "invalid -prompt-sampling: %s (expected random, cycle or shuffle)",
+		"dataset_usage":       "[%s] %s ×%d: used %d of %d distinct prompts (%d sent)\n",
+
+		"single_core_warning": "[%s] %s ×%d: load is concentrated on one CPU core (%.1f%% average on that core, %.1f%% peak overall); likely a single-threaded bottleneck\n",
+		"column_busy_cores":   "Busy cores",
+		"column_hot_core":     "Hottest core(%)",
+
+		"bad_webhook":    "invalid -webhook URL: %s (must start with http:// or https://)",
+		"webhook_failed": "posting to -webhook failed:",
+
+		"bad_cache_requests": "-cache-check-requests must be at least 2",
+		"cache_check_failed": "cache check request failed:",
+		"cache_suspected":    "[%s] %s: response caching suspected: identical requests %.1fms median vs %.1fms with a nonce (identical CV %.1f%%); results may look too good, consider -prompt-nonce\n",
+		"cache_not_detected": "[%s] %s: no response caching detected (identical %.1fms median vs %.1fms with a nonce)\n",
+
+		"predict_values":           "-predict-sweep needs at least two distinct num_predict values",
+		"bad_predict_concurrency":  "-predict-concurrency must be greater than 0",
+		"predict_conflict":         "-predict-sweep cannot be combined with -complexity, -compare-prompts, -soak, -sla-p95, -model-switch or -mix",
+		"predict_testing":          "Testing backend: %s, model: %s, num_predict: %d, concurrency: %d\n",
+		"predict_title":            "\nOutput length sweep:",
+		"predict_header":           "Backend\tModel\tnum_predict\tAvg tokens\tAvg(ms)\tSuccess(%)\tThroughput(token/s)\t",
+		"predict_no_fit":           "no results to fit (needs at least two successful cells with different output token counts)",
+		"predict_fit_title":        "\nLinear fit of latency against output tokens:",
+		"predict_fit_header":       "Backend\tModel\tCells\tPer token(ms)\tOverhead(ms)\tR²\tX axis\t",
+		"predict_source_reported":  "reported tokens",
+		"predict_source_requested": "requested num_predict",
+
+		"no_results":             "no results: ",
+		"no_results_interrupted": "the run was interrupted before the first cell finished",
+		"no_results_filtered":    "every cell was excluded by -only/-skip; check the model names, backends and concurrency values in the filters",
+		"no_results_unknown":     "no cell was run; check -models, -concurrency and the backend settings",
+
+		"bad_embed_concurrency":  "-embed-concurrency must be greater than 0",
+		"embed_conflict":         "-embed-model cannot be combined with -complexity, -compare-prompts, -soak, -sla-p95, -model-switch, -mix or -predict-sweep",
+		"embed_request_conflict": "-embed-model cannot be combined with -body-template, -path, -stream, -images-dir or -response-format text",
+		"embed_testing_alone":    "Testing embeddings alone: %s, model: %s, concurrency: %d\n",
+		"embed_testing_mixed":    "Mixed load: %s, generation %s ×%d together with embeddings %s ×%d\n",
+		"embed_title":            "\nMixed embedding and generation load (vs running alone):",
+		"embed_header":           "Backend\tWorkload\tModel\tConcurrency\tShared with\tAlone avg(ms)\tMixed avg(ms)\tSlowdown(%)\tAlone p95(ms)\tMixed p95(ms)\tAlone RPS\tMixed RPS\t",
+		"workload_generate":      "generate",
+		"workload_embed":         "embed",
+
+		"bad_matrix_duration":   "-max-matrix-duration cannot be negative",
+		"budget_exhausted":      "%[2]s elapsed; not enough time left within -max-matrix-duration %[1]s for the next cell, no new cells will start\n",
+		"budget_skipped_title":  "\n%d cells skipped because of -max-matrix-duration (%s):\n",
+		"budget_skipped_header": "Backend\tModel\tConcurrency\t",
+
+		"no_results_budget": "-max-matrix-duration is too short to finish even one cell at the estimated duration (the default cell duration plus cooldown until a cell has completed)",
+
+		"strict_json_conflict":    "-strict-json cannot be combined with -stream or -response-format text",
+		"strict_json_unsupported": "-strict-json does not support backend %s: no response schema is defined for it",
+		"strict_json_trailing":    "the response body has extra data after the JSON object",
+		"schema_warning":          "Warning: [%s] %s concurrency %d: %d/%d successful responses did not match the expected schema; first reason: %s\n",
+
+		"client_gc_warning": "Warning: [%s] %s concurrency %d: the load generator ran %d GCs with %.2fms total pause (max %.3fms), not negligible next to the %.1fms average response; latencies may include client-side pauses\n",
+		"client_gc_title":   "\nLoad generator GC and memory (runtime.MemStats, delta per cell):",
+		"client_gc_header":  "Backend\tModel\tConcurrency\tGCs\tPause total(ms)\tMax pause(ms)\tHeap(MB)\tHeap delta(MB)\tAllocated(MB)\t",
+
+		"bad_fan_out":      "-fan-out cannot be negative",
+		"fan_out_inflight": "-fan-out cannot be combined with -inflight",
+		"fan_out_title":    "\nFan-out latency (%d parallel requests per round, until the slowest finishes):\n",
+		"fan_out_header":   "Backend\tModel\tConcurrency\tRequest avg(ms)\tRequest p95(ms)\tRounds\tFailed rounds\tRound avg(ms)\tRound p50(ms)\tRound p95(ms)\tRound p99(ms)\tRound/request\t",
+
+		"bad_cooldown": "-cooldown-concurrency and -cooldown-model cannot be negative",
+
+		"error_log_enabled": "Writing failed requests to %s (appending)\n",
+		"error_log_failed":  "Failed to write the error log:",
+		"error_log_summary": "%d failed requests; details and counts per error type are in %s\n",
+
+		"bad_score_weight":       "invalid -score-weights item %q; expected metric=weight with a non-negative weight",
+		"unknown_score_metric":   "unknown -score-weights metric %q; available: %s",
+		"duplicate_score_metric": "-score-weights metric %q is listed twice",
+		"score_weights_zero":     "-score-weights weights must add up to more than 0",
+		"leaderboard_title":      "\nLeaderboard (-score-weights %s, score 0-100):\n",
+		"leaderboard_header":     "Rank\tBackend\tModel\tConcurrency\tScore\t",
+
+		"prompts_file_empty": "prompts file %s contains no prompts (blank lines are ignored)",
+		"no_prompts":         "no prompts to send",
+
+		"testing_no_keepalive":   "  (no keep-alive)\n",
+		"keepalive_conflict":     "-compare-keepalive already runs without keep-alive; do not combine it with -no-keepalive",
+		"keepalive_cpu_conflict": "-compare-keepalive cannot be combined with -compare-against-cpu",
+		"keepalive_title":        "\nKeep-alive vs new connection per request (the difference is the added connection setup per request):",
+		"keepalive_header":       "Backend\tModel\tConcurrency\tKeep-alive avg(ms)\tNew conn avg(ms)\tDelta(ms)\tKeep-alive p95(ms)\tNew conn p95(ms)\tDelta(ms)\tKeep-alive RPS\tNew conn RPS\t",
+
+		"serve_unauthorized": "missing or invalid token",
+		"serve_no_token":     "Warning: the benchmark server listens on %s without -serve-token; anyone who can reach the port can submit runs\n",
+
+		"system_template": "-system cannot be combined with -body-template: the template replaces the whole request body, so the system prompt is never sent",
+
+		"per_worker_inflight":          "-per-worker cannot be combined with -inflight: dispatch slots are not fixed workers",
+		"worker_max_failures_inflight": "-worker-max-failures cannot be combined with -inflight: dispatch slots are not fixed workers",
+
+		"too_many_concurrency_levels": "-concurrency %s expands to more than %d levels; check the range and step",
+
+		"cpu_id_too_large": "invalid CPU list %s: CPU %d is out of range, the maximum is %d",
+
+		"mix_shared_resources": "Models in a window share one machine: the resource columns of each model in the results table are whole-window values (marked shared_resources in JSON) and cannot be compared between models",
+
+		"cell_filter_special_mode": "-only and -skip only apply to the model/backend/concurrency cells and cannot be combined with %s",
+
+		"expected_tps_model_switch": "-model-switch only measures model switch cost and cannot be combined with -expected-tps",
+
+		"result_log_special_mode": "-jsonl and -resume record and resume per cell and cannot be combined with %s",
+	},
+}
+
+// msg 返回当前语言下的文本
+func msg(key string) string {
+	if s, ok := messages[lang][key]; ok {
+		return s
+	}
+	return messages["zh"][key]
+}
+
+// localizedError 在输出时才翻译的错误, 用于包级别的哨兵错误
+type localizedError string
+
+func (e localizedError) Error() string { return msg(string(e)) }
+
+// setupLang 根据 -lang 参数设置输出语言, auto 时依次检查 LC_ALL、LANG, 无法识别时保持中文
+func setupLang(spec string) error {
+	if spec == "auto" {
+		spec = "zh"
+		for _, env := range []string{"LC_ALL", "LANG"} {
+			if v := os.Getenv(env); v != "" {
+				if strings.HasPrefix(v, "en") {
+					spec = "en"
+				}
+				break
+			}
+		}
+	}
+
+	if _, ok := messages[spec]; !ok {
+		return fmt.Errorf(msg("unknown_lang"), spec)
+	}
+	lang = spec
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMessagesComplete(t *testing.T) {
+	for key := range messages["zh"] {
+		if _, ok := messages["en"][key]; !ok {
+			t.Errorf("message %q has no English text", key)
+		}
+	}
+	for key := range messages["en"] {
+		if _, ok := messages["zh"][key]; !ok {
+			t.Errorf("message %q has no Chinese text", key)
+		}
+	}
+}
+
+// TestMessagesUsed 检查每条消息都以字面量的形式出现在代码中, 拼接出来的键无法被搜索到, 也容易在改名时漏掉
+func TestMessagesUsed(t *testing.T) {
+	var source strings.Builder
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if file == "messages.go" || strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source.Write(data)
+	}
+	// messages.go 中除消息表以外的代码 (如 setupLang) 也会使用消息
+	data, err := os.ReadFile("messages.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := regexp.MustCompile(`(?s)var messages = .*?\n}\n`).ReplaceAllString(string(data), "")
+	source.WriteString(code)
+
+	for key := range messages["zh"] {
+		if !strings.Contains(source.String(), `"`+key+`"`) {
+			t.Errorf("message %q is never used", key)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"model-test/internal/monitor"
+)
+
+var mixSpec = flag.String("mix", "", "混合部署测试: 多个模型在同一时间窗口内同时接受请求, 按权重分配 -concurrency 中的总并发, 如 deepseek-r1:7b=3,deepseek-r1:1.5b=1; 输出各模型在共享 GPU 时的结果和合计")
+
+// mixEntry 为 -mix 中的一个模型及其权重
+type mixEntry struct {
+	model  string
+	weight int
+}
+
+// parseMix 解析 -mix, 没有写权重的模型权重为 1
+func parseMix(spec string) ([]mixEntry, error) {
+	var entries []mixEntry
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		// 模型名本身可能带 : 但不会带 =
+		model, value, hasWeight := strings.Cut(item, "=")
+		model = strings.TrimSpace(model)
+		weight := 1
+		if hasWeight {
+			w, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || w < 1 {
+				return nil, fmt.Errorf(msg("bad_mix"), item)
+			}
+			weight = w
+		}
+		if model == "" || seen[model] {
+			return nil, fmt.Errorf(msg("bad_mix"), item)
+		}
+		seen[model] = true
+		entries = append(entries, mixEntry{model, weight})
+	}
+	if len(entries) < 2 {
+		return nil, errors.New(msg("mix_models"))
+	}
+	return entries, nil
+}
+
+// validateMix 检查 -mix 不与其他测试模式同时使用
+func validateMix() error {
+	if *mixSpec == "" {
+		return nil
+	}
+	if *complexityMode != "" || *comparePrompts || *soakMode || *slaP95 > 0 || *modelSwitch || *compareCPU {
+		return errors.New(msg("mix_conflict"))
+	}
+	_, err := parseMix(*mixSpec)
+	return err
+}
+
+// mixConcurrency 按权重把总并发分给各模型, 按最大余数法取整, 每个模型至少 1 个并发
+func mixConcurrency(entries []mixEntry, total int) []int {
+	sum := 0
+	for _, e := range entries {
+		sum += e.weight
+	}
+	shares := make([]int, len(entries))
+	remainders := make([]int, len(entries))
+	assigned := 0
+	for i, e := range entries {
+		shares[i] = total * e.weight / sum
+		remainders[i] = total * e.weight % sum
+		assigned += shares[i]
+	}
+	for ; assigned < total; assigned++ {
+		best := 0
+		for i := range remainders {
+			if remainders[i] > remainders[best] {
+				best = i
+			}
+		}
+		shares[best]++
+		remainders[best] = -1
+	}
+	for i := range shares {
+		shares[i] = max(shares[i], 1)
+	}
+	return shares
+}
+
+// runMix 对每个后端和 -concurrency 中的每个总并发, 让 -mix 中的模型同时运行一个测试窗口;
+// 每个模型使用独立的并发和统计, 结果中的 Mix 字段标记属于同一窗口的各组
+func runMix(backends []Backend, concurrencies []int, meta runMetadata) int {
+	entries, _ := parseMix(*mixSpec)
+
+	var results []TestResult
+mix:
+	for _, backend := range backends {
+		for _, total := range concurrencies {
+			if shutdownCtx.Err() != nil {
+				break mix
+			}
+			shares := mixConcurrency(entries, total)
+			label := fmt.Sprintf("%s×%d", *mixSpec, total)
+			fmt.Printf(msg("mix_testing"), backend.Name(), total, *mixSpec)
+
+			window := make([]TestResult, len(entries))
+			var wg sync.WaitGroup
+			for i, e := range entries {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					window[i] = runTest(backend, e.model, shares[i])
+				}()
+			}
+			wg.Wait()
+			for i := range window {
+				window[i].Mix = label
+				// 各模型同时运行, 采到的都是同一台机器的资源占用
+				window[i].SharedResources = true
+				applyExpectedTPS(&window[i])
+				notifyCell(window[i])
+			}
+			results = append(results, window...)
+			coolDown()
+		}
+	}
+
+	if err := writeResults(results, nil, meta); err != nil {
+		fmt.Println(msg("output_error"), err)
+		return exitOutput
+	}
+	if shutdownCtx.Err() != nil {
+		return interruptedExitCode()
+	}
+	return exitCode(results)
+}
+
+// printMix 汇总 -mix 中每个测试窗口所有模型的请求数、成功率和吞吐, 以及整个窗口的峰值资源占用;
+// 资源占用只在这里按窗口输出一次, 因为窗口内各模型的资源列是同一份整机采样
+func printMix(results []TestResult) {
+	if *mixSpec == "" {
+		return
+	}
+
+	type windowKey struct{ backend, mix string }
+	type windowTotals struct {
+		models, concurrency, requests, successes int
+		rps, tps, latencySum                     float64
+		cpu, gpu, vram                           float64
+		vramKnown                                bool
+	}
+	var order []windowKey
+	windows := make(map[windowKey]*windowTotals)
+	for _, r := range results {
+		if r.Mix == "" {
+			continue
+		}
+		key := windowKey{r.Backend, r.Mix}
+		t, ok := windows[key]
+		if !ok {
+			t = &windowTotals{}
+			windows[key] = t
+			order = append(order, key)
+		}
+		t.models++
+		t.concurrency += r.Concurrency
+		t.requests += r.Requests
+		t.successes += r.Successes
+		t.rps += requestsPerSecond(r)
+		t.tps += r.TokensPerSecond
+		t.latencySum += r.AvgResponseTime * float64(r.Successes)
+		t.cpu, t.gpu, t.vram = max(t.cpu, r.CPULoad), max(t.gpu, r.GPULoad), max(t.vram, r.GPUMemoryUsed)
+		if !slices.Contains(r.UnavailableMetrics, monitor.NameGPUMemoryUsed) {
+			t.vramKnown = true
+		}
+	}
+	if len(order) == 0 {
+		return
+	}
+
+	fmt.Println(msg("mix_title"))
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("mix_header"))
+	for _, key := range order {
+		t := windows[key]
+		successRate, avg := 0.0, 0.0
+		if t.requests > 0 {
+			successRate = float64(t.successes) / float64(t.requests) * 100
+		}
+		if t.successes > 0 {
+			avg = t.latencySum / float64(t.successes)
+		}
+		vram := "-"
+		if t.vramKnown {
+			vram = fmt.Sprintf("%.0f", t.vram)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%s\t\n",
+			key.backend, key.mix, t.models, t.concurrency, t.requests, successRate, avg, t.rps, t.tps, t.cpu, t.gpu, vram)
+	}
+	w.Flush()
+	fmt.Println(msg("mix_shared_resources"))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"model-test/internal/monitor"
+)
+
+func TestPrintMixSharedResources(t *testing.T) {
+	saved := *mixSpec
+	*mixSpec = "a=1,b=1"
+	t.Cleanup(func() { *mixSpec = saved })
+
+	window := "a=1,b=1×2"
+	results := []TestResult{
+		{Backend: "ollama", Model: "a", Concurrency: 1, Mix: window, SharedResources: true, Requests: 10, Successes: 10, CPULoad: 40, GPULoad: 70, GPUMemoryUsed: 5000},
+		{Backend: "ollama", Model: "b", Concurrency: 1, Mix: window, SharedResources: true, Requests: 10, Successes: 10, CPULoad: 45, GPULoad: 72, GPUMemoryUsed: 5100},
+	}
+	out := captureStdout(t, func() { printMix(results) })
+
+	// 资源占用按窗口输出一次, 取窗口内的峰值
+	if got := strings.Join(strings.Fields(out), " "); !strings.Contains(got, "45.0 72.0 5100") {
+		t.Errorf("printMix() = %q, want window peaks 45.0 72.0 5100", got)
+	}
+
+	results[0].UnavailableMetrics = []string{monitor.NameGPUMemoryUsed}
+	results[1].UnavailableMetrics = []string{monitor.NameGPUMemoryUsed}
+	results[0].GPUMemoryUsed, results[1].GPUMemoryUsed = 0, 0
+	out = strings.Join(strings.Fields(captureStdout(t, func() { printMix(results) })), " ")
+	if !strings.Contains(out, "45.0 72.0 -") {
+		t.Errorf("unavailable VRAM not shown as -: %q", out)
+	}
+}
+
+// captureStdout 返回 f 运行期间写到标准输出的内容
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	return capture(t, &os.Stdout, f)
+}
+
+// captureStderr 返回 f 运行期间写到标准错误的内容
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	return capture(t, &os.Stderr, f)
+}
+
+func capture(t *testing.T, file **os.File, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := *file
+	*file = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	f()
+	w.Close()
+	*file = saved
+	return <-done
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// sanitizeModelName 将模型名转换为可用作文件名的形式: 字母 (含中文等)、数字、. _ - 之外的字符
+// (如 deepseek-r1:7b 的 : 和 library/foo 的 /) 替换为 _, 开头的 . 同样替换, 避免生成隐藏文件或 ..;
+// 转换会丢失信息, 不用于 InfluxDB 标签 (见 influxTagEscaper)
+func sanitizeModelName(model string) string {
+	var b strings.Builder
+	for i, r := range model {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '_', r == '-':
+			b.WriteRune(r)
+		case r == '.' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// sanitizedNames 为一组模型名生成互不相同的安全名称; 不同模型转换后相同时 (如 a:b 和 a/b) 依次加上 -2、-3 后缀
+func sanitizedNames(models []string) map[string]string {
+	names := make(map[string]string, len(models))
+	used := make(map[string]bool, len(models))
+	for _, m := range models {
+		if _, ok := names[m]; ok {
+			continue
+		}
+		name := sanitizeModelName(m)
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%d", sanitizeModelName(m), n)
+		}
+		used[name] = true
+		names[m] = name
+	}
+	return names
+}
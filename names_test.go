@@ -0,0 +1,76 @@
+package main
+
+import "testing"
+
+func TestSanitizeModelName(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"deepseek-r1:7b", "deepseek-r1_7b"},
+		{"library/foo", "library_foo"},
+		{"hf.co/org/model:Q4_K_M", "hf.co_org_model_Q4_K_M"},
+		{"qwen2.5:0.5b", "qwen2.5_0.5b"},
+		{"通义千问:7b", "通义千问_7b"},
+		{"modèle:latest", "modèle_latest"},
+		{"llama🦙:8b", "llama__8b"},
+		{"a b\tc", "a_b_c"},
+		{`C:\models\x`, "C__models_x"},
+		{".hidden", "_hidden"},
+		{"..", "_."},
+		{"../../etc/passwd", "_._.._etc_passwd"}, // 不含路径分隔符, 只是一个普通文件名
+		{"", "_"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeModelName(tt.model); got != tt.want {
+			t.Errorf("sanitizeModelName(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizedNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		models []string
+		want   map[string]string
+	}{
+		{
+			name:   "no collision",
+			models: []string{"deepseek-r1:7b", "deepseek-r1:14b"},
+			want:   map[string]string{"deepseek-r1:7b": "deepseek-r1_7b", "deepseek-r1:14b": "deepseek-r1_14b"},
+		},
+		{
+			name:   "collision after sanitizing",
+			models: []string{"a:b", "a/b", "a b"},
+			want:   map[string]string{"a:b": "a_b", "a/b": "a_b-2", "a b": "a_b-3"},
+		},
+		{
+			name:   "suffix already taken by another model",
+			models: []string{"a:b", "a_b-2", "a/b"},
+			want:   map[string]string{"a:b": "a_b", "a_b-2": "a_b-2", "a/b": "a_b-3"},
+		},
+		{
+			name:   "repeated model keeps one name",
+			models: []string{"x:1", "x:1", "x/1"},
+			want:   map[string]string{"x:1": "x_1", "x/1": "x_1-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizedNames(tt.models)
+			if len(got) != len(tt.want) {
+				t.Fatalf("sanitizedNames(%q) = %v, want %v", tt.models, got, tt.want)
+			}
+			seen := make(map[string]string)
+			for model, want := range tt.want {
+				if got[model] != want {
+					t.Errorf("sanitizedNames(%q)[%q] = %q, want %q", tt.models, model, got[model], want)
+				}
+				if other, dup := seen[got[model]]; dup {
+					t.Errorf("%q and %q both map to %q", other, model, got[model])
+				}
+				seen[got[model]] = model
+			}
+		})
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	outputFormat = flag.String("output", "table", "结果输出格式: table、json、csv 或 influx (InfluxDB 行协议)")
+	outputFile   = flag.String("output-file", "", "结果写入的文件, 为空时输出到标准输出 (json 格式建议配合 -quiet 或写入文件)")
+	runNote      = flag.String("note", "", "记录在 JSON 结果元数据中的备注, 如 \"驱动升级后\" 或 \"PR #123\"")
+)
+
+// resultsSchemaVersion 为 JSON 结果的结构版本, 字段含义或结构变化时需要递增
+//
+//	1: 初始版本
+//	2: 增加 runs 和每条结果的 run, 用于合并多次运行
+const resultsSchemaVersion = 2
+
+// runMetadata 记录一次运行的环境信息
+type runMetadata struct {
+	StartedAt   time.Time       `json:"started_at"`
+	FinishedAt  time.Time       `json:"finished_at"`
+	Hostname    string          `json:"hostname"`
+	Backends    []string        `json:"backends"`
+	RunID       string          `json:"run_id,omitempty"`       // 合并结果时用于区分各次运行
+	Note        string          `json:"note,omitempty"`         // -note 给出的备注
+	GitCommit   string          `json:"git_commit,omitempty"`   // 运行目录所在 git 仓库的当前提交, 不在仓库中时为空
+	CommandLine []string        `json:"command_line,omitempty"` // 完整的命令行参数, 敏感参数的值已替换
+	Environment *runEnvironment `json:"environment,omitempty"`  // 运行环境和所有参数的生效值
+}
+
+// resultsDocument 为 JSON 输出的顶层结构
+type resultsDocument struct {
+	SchemaVersion int                `json:"schema_version"`
+	Metadata      runMetadata        `json:"metadata"`
+	Results       []TestResult       `json:"results"`
+	Snapshots     []TestResult       `json:"snapshots,omitempty"`      // 稳定性测试的时间段快照
+	Runs          []runMetadata      `json:"runs,omitempty"`           // merge 合并后各次运行的信息
+	Totals        *runTotals         `json:"totals,omitempty"`         // 所有测试组的合计, merge 的结果中没有
+	ModelDirs     map[string]string  `json:"model_dirs,omitempty"`     // -output-dir 的 summary.json 中模型名到目录名的映射
+	Capacity      []capacityLimit    `json:"capacity,omitempty"`       // 各模型成功率开始低于 -capacity-threshold 的并发数
+	PredictFit    []predictFit       `json:"predict_fit,omitempty"`    // -predict-sweep 时响应时间与输出 token 数的拟合
+	Contention    []contentionResult `json:"contention,omitempty"`     // -embed-model 时各负载在混合运行下的延迟变化
+	BudgetSkipped []skippedCell      `json:"budget_skipped,omitempty"` // 因 -max-matrix-duration 未运行的组
+	Leaderboard   []scoreEntry       `json:"leaderboard,omitempty"`    // -score-weights 时按加权得分排序的各组
+	Columns       *columnsView       `json:"columns,omitempty"`        // -columns 时只含所选列的结果, 完整结果仍在 results 中
+}
+
+func newRunMetadata(backends []Backend) runMetadata {
+	hostname, _ := os.Hostname()
+	meta := runMetadata{
+		StartedAt:   time.Now(),
+		Hostname:    hostname,
+		Note:        *runNote,
+		GitCommit:   gitCommit(),
+		CommandLine: redactArgs(os.Args),
+	}
+	env := currentEnvironment()
+	meta.Environment = &env
+	for _, b := range backends {
+		meta.Backends = append(meta.Backends, b.Name())
+	}
+	return meta
+}
+
+// gitCommit 返回当前目录所在 git 仓库的 HEAD 提交, 没有 git 或不在仓库中时返回空字符串
+func gitCommit() string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// validateOutputFile 检查 -output-file 所在的目录是否存在
+func validateOutputFile(file string) error {
+	if file == "" {
+		return nil
+	}
+	if info, err := os.Stat(filepath.Dir(file)); err != nil || !info.IsDir() {
+		return fmt.Errorf(msg("bad_output_dir"), file)
+	}
+	return nil
+}
+
+func validateOutputFormat(format string) error {
+	switch format {
+	case "table", "json", "csv", "influx":
+		return nil
+	}
+	return fmt.Errorf(msg("unknown_output"), format)
+}
+
+// writeResults 按 -output 指定的格式输出结果, snapshots 仅在稳定性测试时非空
+func writeResults(results, snapshots []TestResult, meta runMetadata) error {
+	meta.FinishedAt = time.Now()
+	closeErrorLog()
+	if len(results) == 0 {
+		// JSON、CSV 和 InfluxDB 输出可能写到标准输出, 提示写到标准错误以免破坏文档
+		w := os.Stdout
+		if *outputFormat != "table" {
+			w = os.Stderr
+		}
+		fmt.Fprintln(w, noResultsReason())
+	}
+
+	if *outputDir != "" {
+		if err := writeOutputDir(*outputDir, results, meta); err != nil {
+			return err
+		}
+		// 逐请求数据只写入单组文件
+		results = withoutSeries(results)
+	}
+
+	if *influxURL != "" {
+		// 推送失败不影响本地输出
+		if err := pushInflux(results, meta); err != nil {
+			fmt.Println(msg("influx_failed"), err)
+		}
+	}
+
+	totals := computeTotals(results, meta)
+	doc := resultsDocument{
+		SchemaVersion: resultsSchemaVersion,
+		Metadata:      meta,
+		Results:       results,
+		Snapshots:     snapshots,
+		Totals:        &totals,
+		Capacity:      capacityLimits(results),
+		PredictFit:    predictFits(results),
+		Contention:    embedContention(results),
+		BudgetSkipped: budgetSkipped,
+		Leaderboard:   leaderboard(results),
+		Columns:       newColumnsView(results, snapshots),
+	}
+	notifySummary(doc)
+
+	if *outputFormat == "table" && *outputFile == "" {
+		printResults(results)
+		printTotals(results, meta)
+		printMix(results)
+		if *verbose {
+			printHistograms(results)
+			printClientGC(results)
+		}
+		printTiers(results)
+		printPromptSpread(results)
+		printCapacity(results)
+		printSLASearch(results)
+		printPredictSweep(results)
+		printEmbedContention(results)
+		printQuantGroups(results)
+		printEfficiency(results)
+		printLeaderboard(results)
+		printWorkerLatency(results)
+		printBackground(results)
+		printWarmupStats(results)
+		printStreamStats(results)
+		printFanOut(results)
+		printImageLatency(results)
+		printCPUComparison(results)
+		printKeepAliveComparison(results)
+		printBatchStats(results)
+		printErrors(results)
+		printBudgetSkipped()
+		if len(meta.Backends) > 1 {
+			printComparison(results, meta.Backends[0])
+		}
+		return nil
+	}
+
+	var out io.Writer = os.Stdout
+	if *outputFile != "" {
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch *outputFormat {
+	case "table":
+		fprintResults(out, results, 0)
+		return nil
+	case "influx":
+		return writeInflux(out, results, meta)
+	case "csv":
+		return writeCSV(out, results)
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// writeSoakProgress 在 -soak 以 JSON 写入 -output-file 时, 每个快照后重写文件 (先写临时文件再改名),
+// 进程中途崩溃或被强制结束时已完成的快照不会丢失; 汇总结果在运行结束时才写入
+func writeSoakProgress(snapshots []TestResult, meta runMetadata) error {
+	if *outputFile == "" || *outputFormat != "json" {
+		return nil
+	}
+	meta.FinishedAt = time.Now()
+	data, err := json.MarshalIndent(resultsDocument{
+		SchemaVersion: resultsSchemaVersion, Metadata: meta, Snapshots: snapshots, Columns: newColumnsView(nil, snapshots),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(*outputFile), filepath.Base(*outputFile)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	// CreateTemp 创建的文件只有所有者可读, 与 os.Create 保持一致
+	f.Chmod(0o644)
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), *outputFile)
+}
+
+func printResults(results []TestResult) {
+	fprintResults(os.Stdout, results, terminalWidth())
+	for _, r := range results {
+		if r.EstimatedTokenSamples > 0 {
+			fmt.Printf(msg("tokens_estimated_note"), *tokenEstimator)
+			break
+		}
+	}
+}
+
+// metricCell 格式化一项资源指标, 整组都无法采集时显示 "-" 而不是误导性的 0
+func metricCell(r TestResult, name, format string, value float64) string {
+	if slices.Contains(r.UnavailableMetrics, name) {
+		return "-"
+	}
+	return fmt.Sprintf(format, value)
+}
+
+// runTotals 为整次运行所有测试组的合计
+type runTotals struct {
+	Requests      int     `json:"requests"`
+	Successes     int     `json:"successes"`
+	BytesReceived int64   `json:"bytes_received"`
+	WallClockSec  float64 `json:"wall_clock_s"`
+}
+
+func computeTotals(results []TestResult, meta runMetadata) runTotals {
+	t := runTotals{WallClockSec: meta.FinishedAt.Sub(meta.StartedAt).Seconds()}
+	for _, r := range results {
+		t.Requests += r.Requests
+		t.Successes += r.Successes
+		t.BytesReceived += r.BytesReceived
+	}
+	return t
+}
+
+// printTotals 输出一行整次运行的规模: 请求数、接收的数据量和总耗时.
+// 目前没有功耗采集, 因此不计算 GPU 时长/能耗
+func printTotals(results []TestResult, meta runMetadata) {
+	t := computeTotals(results, meta)
+	fmt.Printf(msg("totals"), t.Requests, t.Successes, formatBytes(t.BytesReceived),
+		time.Duration(t.WallClockSec*float64(time.Second)).Round(time.Second))
+}
+
+// formatBytes 以 B/KB/MB/GB 显示字节数
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value, suffix := float64(n)/unit, "KB"
+	for _, s := range []string{"MB", "GB", "TB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, s
+	}
+	return fmt.Sprintf("%.1f%s", value, suffix)
+}
+
+func printHistograms(results []TestResult) {
+	for _, r := range results {
+		if len(r.Histogram) == 0 {
+			continue
+		}
+		fmt.Printf(msg("histogram_title"), r.Backend, r.Model, r.Concurrency)
+		fprintHistogram(os.Stdout, r.Histogram)
+	}
+}
+
+// printWarmupStats 在 -warmup-split 时对比预热和稳定阶段的平均响应
+func printWarmupStats(results []TestResult) {
+	if len(results) == 0 || *warmupSplit == 0 {
+		return
+	}
+
+	fmt.Printf(msg("warmup_title"), *warmupSplit)
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("warmup_header"))
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%.1f\t\n",
+			r.Backend, r.Model, r.Concurrency, r.WarmupAvgMs, r.SteadyAvgMs, r.AvgResponseTime)
+	}
+	w.Flush()
+}
+
+// printWorkerLatency 在 -per-worker 时输出每个并发的平均响应, 并标出偏离中位数的并发
+func printWorkerLatency(results []TestResult) {
+	for _, r := range results {
+		measured := slices.DeleteFunc(slices.Clone(r.WorkerAvgMs), func(v float64) bool { return v == 0 })
+		if len(measured) < 2 {
+			continue
+		}
+		fmt.Printf(msg("straggler_title"), r.Backend, r.Model, r.Concurrency, medianOf(measured))
+		for i, avg := range r.WorkerAvgMs {
+			mark := ""
+			if slices.Contains(r.Stragglers, i) {
+				mark = msg("straggler_mark")
+			}
+			fmt.Printf(msg("straggler_row"), i, avg, mark)
+		}
+	}
+}
+
+// printBatchStats 在批量模式下输出批响应时间、摊销到每条提示词的响应时间和吞吐
+func printBatchStats(results []TestResult) {
+	if len(results) == 0 || results[0].BatchSize <= 1 {
+		return
+	}
+
+	fmt.Printf(msg("batch_title"), results[0].BatchSize)
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("batch_header"))
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%.2f\t\n",
+			r.Backend, r.Model, r.Concurrency, r.AvgResponseTime, r.ItemResponseTime, r.ItemsPerSecond)
+	}
+	w.Flush()
+}
+
+// printErrors 按组输出失败请求的错误信息及次数
+func printErrors(results []TestResult) {
+	for _, r := range results {
+		if len(r.ErrorCounts) == 0 {
+			continue
+		}
+
+		fmt.Printf(msg("error_detail"), r.Backend, r.Model, r.Concurrency)
+		if r.StoppedWorkers > 0 {
+			fmt.Printf(msg("stopped_workers"), r.StoppedWorkers, r.Concurrency)
+		}
+		if r.Timeouts > 0 {
+			fmt.Printf(msg("timeout_count"), r.Timeouts, time.Duration(r.RequestTimeoutSec*float64(time.Second)))
+		}
+		msgs := make([]string, 0, len(r.ErrorCounts))
+		for e := range r.ErrorCounts {
+			msgs = append(msgs, e)
+		}
+		sort.Strings(msgs)
+		for _, e := range msgs {
+			fmt.Printf(msg("error_count"), r.ErrorCounts[e], e)
+		}
+	}
+}
+
+// printComparison 以基准后端为参照, 输出同一模型和并发数下各后端的指标及差值
+func printComparison(results []TestResult, baseline string) {
+	type cellKey struct {
+		model       string
+		concurrency int
+	}
+
+	// 按 模型+并发数 分组, 使同一组的各后端结果相邻输出
+	var order []cellKey
+	groups := make(map[cellKey][]TestResult)
+	base := make(map[cellKey]TestResult)
+	for _, r := range results {
+		if comparisonMode(r.ExecutionMode) {
+			// 只用 CPU 和不复用连接的结果单独在 printCPUComparison 和 printKeepAliveComparison 中对比
+			continue
+		}
+		key := cellKey{r.Model, r.Concurrency}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], r)
+		if r.Backend == baseline {
+			base[key] = r
+		}
+	}
+
+	fmt.Printf(msg("comparison_title"), baseline)
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("comparison_header"))
+
+	for _, key := range order {
+		b, ok := base[key]
+		if !ok {
+			continue
+		}
+
+		for _, r := range groups[key] {
+			delta := func(v, baseV float64) string {
+				if r.Backend == baseline {
+					return "-"
+				}
+				return fmt.Sprintf("%+.1f", v-baseV)
+			}
+
+			fmt.Fprintf(w, "%s\t%d\t%s\t%.1f\t%s\t%.1f\t%s\t%.1f\t%s\t%.1f\t%s\t%.0f\t%s\t\n",
+				r.Model,
+				r.Concurrency,
+				r.Backend,
+				r.AvgResponseTime, delta(r.AvgResponseTime, b.AvgResponseTime),
+				r.MaxResponseTime, delta(r.MaxResponseTime, b.MaxResponseTime),
+				r.SuccessRate, delta(r.SuccessRate, b.SuccessRate),
+				r.GPULoad, delta(r.GPULoad, b.GPULoad),
+				r.GPUMemoryUsed, delta(r.GPUMemoryUsed, b.GPUMemoryUsed),
+			)
+		}
+	}
+
+	w.Flush()
+}
+
+// noResultsReason 返回没有任何结果时的提示及最可能的原因
+func noResultsReason() string {
+	reason := msg("no_results_unknown")
+	switch {
+	case shutdownCtx.Err() != nil:
+		reason = msg("no_results_interrupted")
+	case len(budgetSkipped) > 0:
+		reason = msg("no_results_budget")
+	case *onlyCells != "" || *skipCells != "":
+		reason = msg("no_results_filtered")
+	}
+	return msg("no_results") + reason
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var outputDir = flag.String("output-dir", "", "按测试组分别写入结果文件 (模型/并发数.json, 模型名转换为安全的目录名, 含逐个请求的响应时间和逐秒资源采样), 并在顶层写入 summary.json")
+
+// cellDocument 为 -output-dir 中单组测试的结果文件
+type cellDocument struct {
+	SchemaVersion int         `json:"schema_version"`
+	Metadata      runMetadata `json:"metadata"`
+	Result        TestResult  `json:"result"`
+}
+
+// cellFilePath 返回一组结果在 -output-dir 中的相对路径, dirs 为模型名到目录名的映射; 多个后端时文件名带上后端名
+func cellFilePath(r TestResult, dirs map[string]string, multiBackend bool) string {
+	var parts []string
+	if multiBackend {
+		parts = append(parts, r.Backend)
+	}
+	parts = append(parts, strconv.Itoa(r.Concurrency))
+	if r.Tier != "" {
+		parts = append(parts, sanitizeModelName(r.Tier))
+	}
+	if comparisonMode(r.ExecutionMode) {
+		parts = append(parts, r.ExecutionMode)
+	}
+	if *predictSweep != "" {
+		parts = append(parts, "n"+strconv.Itoa(r.NumPredict))
+	}
+	if r.Contended {
+		parts = append(parts, "shared")
+	}
+	if r.Mix != "" {
+		parts = append(parts, "mix", sanitizeModelName(r.Mix))
+	}
+	return filepath.Join(dirs[r.Model], strings.Join(parts, "-")+".json")
+}
+
+// writeOutputDir 将每组结果写入单独的文件, 并写入不含逐请求数据的 summary.json
+func writeOutputDir(dir string, results []TestResult, meta runMetadata) error {
+	models := make([]string, len(results))
+	for i, r := range results {
+		models[i] = r.Model
+	}
+	dirs := sanitizedNames(models)
+
+	multiBackend := len(meta.Backends) > 1
+	for _, r := range results {
+		path := filepath.Join(dir, cellFilePath(r, dirs, multiBackend))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := writeJSONFile(path, cellDocument{SchemaVersion: resultsSchemaVersion, Metadata: meta, Result: r}); err != nil {
+			return err
+		}
+	}
+
+	totals := computeTotals(results, meta)
+	return writeJSONFile(filepath.Join(dir, "summary.json"), resultsDocument{
+		SchemaVersion: resultsSchemaVersion,
+		Metadata:      meta,
+		Results:       withoutSeries(results),
+		Totals:        &totals,
+		ModelDirs:     dirs,
+		Capacity:      capacityLimits(results),
+	})
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// withoutSeries 返回去掉逐请求响应时间的结果副本, 未开启 -resource-samples 时同时去掉逐秒资源采样
+func withoutSeries(results []TestResult) []TestResult {
+	stripped := make([]TestResult, len(results))
+	for i, r := range results {
+		r.ResponseTimesMs = nil
+		if !*resourceSamples {
+			r.ResourceSamples = nil
+		}
+		stripped[i] = r
+	}
+	return stripped
+}
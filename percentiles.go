@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var percentileSpec = flag.String("percentiles", "50,90,95,99", "结果表和 JSON 中输出的响应时间百分位, 逗号分隔, 取值在 (0,100) 之间, 如 50,90,95,99,99.9; 空表示不输出")
+
+// reportedPercentiles 为 -percentiles 解析后的百分位, 按从小到大排列
+var reportedPercentiles []float64
+
+// parsePercentiles 解析 -percentiles, 去重并排序
+func parsePercentiles(spec string) ([]float64, error) {
+	var ps []float64
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(strings.TrimPrefix(item, "p"), 64)
+		if err != nil || p <= 0 || p >= 100 {
+			return nil, fmt.Errorf(msg("bad_percentile"), item)
+		}
+		ps = append(ps, p)
+	}
+	slices.Sort(ps)
+	return slices.Compact(ps), nil
+}
+
+// percentileLabel 返回百分位的名称, 如 p95、p99.9, 用作表头和 JSON 键
+func percentileLabel(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// percentilesMs 计算 -percentiles 中每个百分位的响应时间(ms), 没有样本时返回 nil
+func percentilesMs(ds []time.Duration) map[string]float64 {
+	if len(ds) == 0 || len(reportedPercentiles) == 0 {
+		return nil
+	}
+	values := make(map[string]float64, len(reportedPercentiles))
+	for _, p := range reportedPercentiles {
+		values[percentileLabel(p)] = percentileMs(ds, p)
+	}
+	return values
+}
+
+// percentileCell 返回一组结果某个百分位的单元格, 结果中没有该百分位时 (如合并的旧文件) 显示 -
+func percentileCell(r TestResult, p float64) string {
+	if v, ok := r.Percentiles[percentileLabel(p)]; ok {
+		return fmt.Sprintf("%.1f", v)
+	}
+	return "-"
+}
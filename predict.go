@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+var (
+	predictSweep       = flag.String("predict-sweep", "", "按输出长度测量生成开销: 以固定并发依次使用列出的 num_predict (如 16,64,256,1024) 测试每个模型, 对 响应时间-输出 token 数 做线性拟合, 输出每 token 耗时 (斜率)、固定开销 (截距) 和 R²")
+	predictConcurrency = flag.Int("predict-concurrency", 1, "-predict-sweep 使用的固定并发数")
+)
+
+// 拟合所用输出 token 数的来源
+const (
+	tokenSourceReported  = "reported"
+	tokenSourceRequested = "requested"
+)
+
+// tokenSourceName 返回输出 token 数来源在表格中的名称
+func tokenSourceName(source string) string {
+	if source == tokenSourceRequested {
+		return msg("predict_source_requested")
+	}
+	return msg("predict_source_reported")
+}
+
+// predictFit 为 -predict-sweep 中一个 后端+模型 的拟合结果
+type predictFit struct {
+	Backend     string  `json:"backend"`
+	Model       string  `json:"model"`
+	Points      int     `json:"points"`       // 参与拟合的测试组数
+	MsPerToken  float64 `json:"ms_per_token"` // 斜率: 每多生成一个 token 增加的响应时间
+	OverheadMs  float64 `json:"overhead_ms"`  // 截距: 与输出长度无关的固定开销 (排队、预填充、网络等)
+	R2          float64 `json:"r2"`
+	TokenSource string  `json:"token_source"` // reported 为服务端报告的平均生成 token 数, requested 为请求的 num_predict
+}
+
+// parsePredictSweep 解析 -predict-sweep, 至少需要两个不同的值才能拟合
+func parsePredictSweep(spec string) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		n, err := parsePositiveInt(item)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, n)
+	}
+	values, _ = dedupe(values)
+	if len(values) < 2 {
+		return nil, errors.New(msg("predict_values"))
+	}
+	return values, nil
+}
+
+// validatePredictSweep 检查 -predict-sweep 的参数, 以及不与其他测试模式同时使用
+func validatePredictSweep() error {
+	if *predictSweep == "" {
+		return nil
+	}
+	if *predictConcurrency < 1 {
+		return errors.New(msg("bad_predict_concurrency"))
+	}
+	if *complexityMode != "" || *comparePrompts || *soakMode || *slaP95 > 0 || *modelSwitch || *mixSpec != "" {
+		return errors.New(msg("predict_conflict"))
+	}
+	_, err := parsePredictSweep(*predictSweep)
+	return err
+}
+
+// runPredictSweep 对每个 后端+模型 依次以 -predict-sweep 中的每个 num_predict 运行一组测试
+func runPredictSweep(backends []Backend, models []string, meta runMetadata) int {
+	values, _ := parsePredictSweep(*predictSweep)
+	original := *numPredict
+	defer func() { *numPredict = original }()
+
+	var results []TestResult
+sweep:
+	for _, model := range models {
+		for _, backend := range backends {
+			for _, n := range values {
+				if shutdownCtx.Err() != nil {
+					break sweep
+				}
+				fmt.Printf(msg("predict_testing"), backend.Name(), model, n, *predictConcurrency)
+				*numPredict = n
+				result := runTest(backend, model, *predictConcurrency)
+				applyExpectedTPS(&result)
+				results = append(results, result)
+				notifyCell(result)
+				coolDown()
+			}
+		}
+	}
+
+	if err := writeResults(results, nil, meta); err != nil {
+		fmt.Println(msg("output_error"), err)
+		return exitOutput
+	}
+	if shutdownCtx.Err() != nil {
+		return interruptedExitCode()
+	}
+	return exitCode(results)
+}
+
+// predictFits 对每个 后端+模型 的结果做最小二乘拟合; 所有组都报告了生成 token 数时以其为横轴,
+// 否则以请求的 num_predict 为横轴 (模型提前结束时会高估每 token 耗时)
+func predictFits(results []TestResult) []predictFit {
+	if *predictSweep == "" {
+		return nil
+	}
+
+	type modelKey struct{ backend, model string }
+	var order []modelKey
+	groups := make(map[modelKey][]TestResult)
+	for _, r := range results {
+		if r.Successes == 0 {
+			continue
+		}
+		key := modelKey{r.Backend, r.Model}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], r)
+	}
+
+	var fits []predictFit
+	for _, key := range order {
+		group := groups[key]
+		source := tokenSourceReported
+		for _, r := range group {
+			if r.AvgCompletionTokens == 0 {
+				source = tokenSourceRequested
+				break
+			}
+		}
+		xs, ys := make([]float64, len(group)), make([]float64, len(group))
+		for i, r := range group {
+			xs[i], ys[i] = r.AvgCompletionTokens, r.AvgResponseTime
+			if source == tokenSourceRequested {
+				xs[i] = float64(r.NumPredict)
+			}
+		}
+		slope, intercept, r2, ok := linearFit(xs, ys)
+		if !ok {
+			continue
+		}
+		fits = append(fits, predictFit{
+			Backend: key.backend, Model: key.model, Points: len(group),
+			MsPerToken: slope, OverheadMs: intercept, R2: r2, TokenSource: source,
+		})
+	}
+	return fits
+}
+
+// linearFit 返回 y = slope*x + intercept 的最小二乘拟合及 R²; 少于两个点或 x 全部相同时 ok 为 false
+func linearFit(xs, ys []float64) (slope, intercept, r2 float64, ok bool) {
+	n := float64(len(xs))
+	if len(xs) < 2 {
+		return 0, 0, 0, false
+	}
+	var sx, sy, sxx, sxy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+		sxx += xs[i] * xs[i]
+		sxy += xs[i] * ys[i]
+	}
+	denom := n*sxx - sx*sx
+	if math.Abs(denom) < 1e-9 {
+		return 0, 0, 0, false
+	}
+	slope = (n*sxy - sx*sy) / denom
+	intercept = (sy - slope*sx) / n
+
+	mean := sy / n
+	var ssRes, ssTot float64
+	for i := range xs {
+		d := ys[i] - (slope*xs[i] + intercept)
+		ssRes += d * d
+		ssTot += (ys[i] - mean) * (ys[i] - mean)
+	}
+	r2 = 1.0
+	if ssTot > 0 {
+		r2 = 1 - ssRes/ssTot
+	}
+	return slope, intercept, r2, true
+}
+
+// printPredictSweep 输出 -predict-sweep 各组的输出长度和响应时间, 以及每个模型的拟合结果
+func printPredictSweep(results []TestResult) {
+	if *predictSweep == "" || len(results) == 0 {
+		return
+	}
+
+	fmt.Println(msg("predict_title"))
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("predict_header"))
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%.1f\t%.1f\t%.1f\t\n",
+			r.Backend, r.Model, r.NumPredict, tokenCell(r), r.AvgResponseTime, r.SuccessRate, r.TokensPerSecond)
+	}
+	w.Flush()
+
+	fits := predictFits(results)
+	if len(fits) == 0 {
+		fmt.Println(msg("predict_no_fit"))
+		return
+	}
+	fmt.Println(msg("predict_fit_title"))
+	w = newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("predict_fit_header"))
+	for _, f := range fits {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.2f\t%.1f\t%.3f\t%s\t\n",
+			f.Backend, f.Model, f.Points, f.MsPerToken, f.OverheadMs, f.R2, tokenSourceName(f.TokenSource))
+	}
+	w.Flush()
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// terminateProcess 在不支持 SIGTERM 的系统上直接结束子进程
+func terminateProcess(p *os.Process) error {
+	return p.Kill()
+}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// terminateProcess 向子进程发送 SIGTERM, 让它像收到 Ctrl-C 一样输出已完成的结果后退出
+func terminateProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
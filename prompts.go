@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var promptsFile = flag.String("prompts-file", "", "从文件读取提示词, 每行一条, 忽略空行; 行首可用 [标签] 标注难度, 如 [3] 证明勾股定理 (没有指定本参数和 -dataset 时也可以通过管道从标准输入传入)")
+
+// promptTags 为 prompts 中每条提示词的难度标签 (下标一一对应), 没有标注时为空字符串
+var promptTags = make([]string, len(prompts))
+
+// loadPromptsFile 读取提示词文件, 返回去掉标签后的提示词及对应的标签; 文件中没有提示词时返回错误,
+// 因为后面的测试都假定至少有一条提示词
+func loadPromptsFile(path string) (texts, tags []string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	if texts, tags, err = readPrompts(f); err != nil {
+		return nil, nil, err
+	}
+	if len(texts) == 0 {
+		return nil, nil, fmt.Errorf(msg("prompts_file_empty"), path)
+	}
+	return texts, tags, nil
+}
+
+// stdinPrompts 在标准输入不是终端 (管道或重定向) 时从中读取提示词, 格式与 -prompts-file 相同, 一直读到 EOF;
+// 标准输入为终端或 /dev/null 时返回空. 管道中没有提示词时返回错误而不是改用内置提示词, 以免误测了另一组提示词
+func stdinPrompts() (texts, tags []string, err error) {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice != 0 {
+		return nil, nil, nil
+	}
+	if texts, tags, err = readPrompts(os.Stdin); err != nil {
+		return nil, nil, err
+	}
+	if len(texts) == 0 {
+		return nil, nil, errors.New(msg("stdin_empty"))
+	}
+	return texts, tags, nil
+}
+
+// readPrompts 逐行读取提示词, 忽略空行
+func readPrompts(r io.Reader) (texts, tags []string, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		tag, text := splitPromptTag(line)
+		texts = append(texts, text)
+		tags = append(tags, tag)
+	}
+	return texts, tags, scanner.Err()
+}
+
+// splitPromptTag 拆分行首的 [标签], 没有标签时返回空标签和原文
+func splitPromptTag(line string) (tag, text string) {
+	if !strings.HasPrefix(line, "[") {
+		return "", line
+	}
+	end := strings.Index(line, "]")
+	if end < 0 {
+		return "", line
+	}
+	return strings.TrimSpace(line[1:end]), strings.TrimSpace(line[end+1:])
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writePromptsFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "prompts.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPromptsFileEmpty(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty", ""},
+		{"newlines", "\n\n\n"},
+		{"whitespace", "  \t\n \r\n\t\t\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writePromptsFile(t, tt.content)
+			texts, tags, err := loadPromptsFile(path)
+			if err == nil {
+				t.Fatalf("loadPromptsFile() = %q, %q, want error", texts, tags)
+			}
+			if !strings.Contains(err.Error(), path) {
+				t.Errorf("error %q does not name the file", err)
+			}
+		})
+	}
+}
+
+func TestLoadPromptsFile(t *testing.T) {
+	path := writePromptsFile(t, "\n  你好  \n[3] 证明勾股定理\n\n[hard]\tsummarize this\n")
+	texts, tags, err := loadPromptsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"你好", "证明勾股定理", "summarize this"}; !slices.Equal(texts, want) {
+		t.Errorf("texts = %q, want %q", texts, want)
+	}
+	if want := []string{"", "3", "hard"}; !slices.Equal(tags, want) {
+		t.Errorf("tags = %q, want %q", tags, want)
+	}
+
+	if _, _, err := loadPromptsFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("loadPromptsFile() on a missing file returned no error")
+	}
+}
+
+func TestRunTestForNoPrompts(t *testing.T) {
+	saved := prompts
+	prompts = nil
+	t.Cleanup(func() { prompts = saved })
+
+	// 没有提示词时返回失败的结果, 不能在抽取提示词时 panic
+	r := runTestFor(ollamaBackend{}, "m", 1, 0, 0, nil)
+	if r.Successes != 0 || r.FirstError == "" {
+		t.Errorf("runTestFor() = %+v, want a failed result", r)
+	}
+}
+
+func TestStdinPrompts(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "prompts", input: "第一条\n\n[2] 第二条\n", want: []string{"第一条", "第二条"}},
+		{name: "empty pipe", input: "", wantErr: true},
+		{name: "blank lines", input: "\n \n\t\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			saved := os.Stdin
+			os.Stdin = r
+			t.Cleanup(func() { os.Stdin = saved })
+
+			// 写完后关闭, stdinPrompts 读到 EOF 才返回
+			go func() {
+				w.WriteString(tt.input)
+				w.Close()
+			}()
+			texts, _, err := stdinPrompts()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("stdinPrompts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(texts, tt.want) {
+				t.Errorf("stdinPrompts() = %q, want %q", texts, tt.want)
+			}
+		})
+	}
+}
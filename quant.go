@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// quantPattern 匹配 ollama 模型标签末尾的量化后缀, 如 q4_K_M、q8_0、fp16
+var quantPattern = regexp.MustCompile(`(?i)^(q\d+(_[a-z0-9]+)*|iq\d+(_[a-z0-9]+)*|fp16|f16|bf16|fp32|f32)$`)
+
+// modelGroups 记录每个模型所属的基础模型分组, 由 splitModelGroups 设置
+var modelGroups = map[string]string{}
+
+// splitModelGroups 解析 -models 中的 分组=模型 写法, 返回去掉分组后的模型名;
+// 没有显式分组的模型按去掉量化后缀后的名称分组, 如 qwen2.5:7b-q4_K_M 和 qwen2.5:7b-q8_0 同属 qwen2.5:7b
+func splitModelGroups(models []string) []string {
+	names := make([]string, len(models))
+	for i, m := range models {
+		group, name, ok := strings.Cut(m, "=")
+		if !ok {
+			name = m
+			group, _ = splitQuantization(m)
+		}
+		name, group = strings.TrimSpace(name), strings.TrimSpace(group)
+		names[i] = name
+		modelGroups[name] = group
+	}
+	return names
+}
+
+// splitQuantization 拆分模型标签中的量化后缀, 没有时返回原名和空字符串
+func splitQuantization(model string) (base, quant string) {
+	idx := strings.LastIndex(model, "-")
+	if idx < 0 || idx < strings.LastIndex(model, ":") {
+		return model, ""
+	}
+	if suffix := model[idx+1:]; quantPattern.MatchString(suffix) {
+		return model[:idx], suffix
+	}
+	return model, ""
+}
+
+// printQuantGroups 按基础模型分组对比各量化版本, 只输出包含多个模型的分组;
+// 速度以组内最快的版本为 100%, 显存差值相对组内占用最少的版本. 生成质量无法自动测量, 需自行评估
+func printQuantGroups(results []TestResult) {
+	type groupKey struct {
+		group, backend string
+		concurrency    int
+	}
+
+	var order []groupKey
+	groups := make(map[groupKey][]TestResult)
+	for _, r := range results {
+		if r.ModelGroup == "" {
+			continue
+		}
+		key := groupKey{r.ModelGroup, r.Backend, r.Concurrency}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], r)
+	}
+
+	printed := false
+	w := newTableWriter(os.Stdout)
+	for _, key := range order {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+		if !printed {
+			fmt.Println(msg("quant_title"))
+			fmt.Fprintln(w, msg("quant_header"))
+			printed = true
+		}
+
+		fastest, leastVRAM := group[0].AvgResponseTime, group[0].GPUMemoryUsed
+		for _, r := range group {
+			if r.AvgResponseTime > 0 && (fastest == 0 || r.AvgResponseTime < fastest) {
+				fastest = r.AvgResponseTime
+			}
+			leastVRAM = min(leastVRAM, r.GPUMemoryUsed)
+		}
+
+		for _, r := range group {
+			speed := 0.0
+			if r.AvgResponseTime > 0 {
+				speed = fastest / r.AvgResponseTime * 100
+			}
+			quant := r.Quantization
+			if quant == "" {
+				quant = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%.1f\t%.0f%%\t%.0f\t%+.0f\t%.1f\t\n",
+				key.group, key.backend, key.concurrency, r.Model, quant,
+				r.AvgResponseTime, speed, r.GPUMemoryUsed, r.GPUMemoryUsed-leastVRAM, r.SuccessRate)
+		}
+	}
+	w.Flush()
+}
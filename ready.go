@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var waitForReady = flag.Duration("wait-for-ready", 0, "开始测试前轮询各后端接口, 直到能够连接 (状态码低于 500) 或超过该时长, 用于与 ollama 同时启动的脚本; 0 表示不等待")
+
+// readyPollInterval 为 -wait-for-ready 的轮询间隔
+const readyPollInterval = time.Second
+
+// waitUntilReady 依次等待每个后端就绪, 超时或被中断时返回 false
+func waitUntilReady(backends []Backend, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(shutdownCtx, timeout)
+	defer cancel()
+	client := &http.Client{Timeout: readyPollInterval}
+
+	start := time.Now()
+	for _, b := range backends {
+		fmt.Printf(msg("ready_waiting"), b.Name(), b.Endpoint())
+		for !probeEndpoint(ctx, client, b.Endpoint()) {
+			timer := time.NewTimer(readyPollInterval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				if shutdownCtx.Err() == nil {
+					fmt.Printf(msg("ready_timeout"), b.Name(), timeout)
+				}
+				return false
+			}
+		}
+	}
+	fmt.Printf(msg("ready_done"), time.Since(start).Round(time.Millisecond))
+	return true
+}
+
+// probeEndpoint 向接口所在服务的根路径发送 GET 请求; 收到响应且不是 5xx (如模型仍在加载时的 503) 即认为就绪
+func probeEndpoint(ctx context.Context, client *http.Client, endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	root := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, root.String(), nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < http.StatusInternalServerError
+}
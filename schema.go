@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+var strictJSON = flag.Bool("strict-json", false, "契约测试: 按各后端接口文档定义的结构严格解析响应, 出现未知字段或字段类型不符时计为结构不符 (请求仍计为成功), 用于发现服务端接口的变化; 不能与 -stream 或 -response-format text 同时使用")
+
+// schemaBackend 由能给出响应结构的后端实现, responseSchema 返回用于严格解码的结构体指针
+type schemaBackend interface {
+	responseSchema() interface{}
+}
+
+// ollamaTimings 为 ollama 各接口共有的耗时和 token 计数字段
+type ollamaTimings struct {
+	TotalDuration      int64 `json:"total_duration"`
+	LoadDuration       int64 `json:"load_duration"`
+	PromptEvalCount    int   `json:"prompt_eval_count"`
+	PromptEvalDuration int64 `json:"prompt_eval_duration"`
+	EvalCount          int   `json:"eval_count"`
+	EvalDuration       int64 `json:"eval_duration"`
+}
+
+func (b ollamaBackend) responseSchema() interface{} {
+	return &struct {
+		Model      string `json:"model"`
+		CreatedAt  string `json:"created_at"`
+		Response   string `json:"response"`
+		Thinking   string `json:"thinking"`
+		Done       bool   `json:"done"`
+		DoneReason string `json:"done_reason"`
+		Context    []int  `json:"context"`
+		ollamaTimings
+	}{}
+}
+
+func (b ollamaChatBackend) responseSchema() interface{} {
+	return &struct {
+		Model     string `json:"model"`
+		CreatedAt string `json:"created_at"`
+		Message   struct {
+			Role      string            `json:"role"`
+			Content   string            `json:"content"`
+			Thinking  string            `json:"thinking"`
+			Images    []string          `json:"images"`
+			ToolCalls []json.RawMessage `json:"tool_calls"`
+		} `json:"message"`
+		Done       bool   `json:"done"`
+		DoneReason string `json:"done_reason"`
+		ollamaTimings
+	}{}
+}
+
+// openAIUsage 为 OpenAI 兼容接口的 usage 字段
+type openAIUsage struct {
+	PromptTokens        int             `json:"prompt_tokens"`
+	CompletionTokens    int             `json:"completion_tokens"`
+	TotalTokens         int             `json:"total_tokens"`
+	PromptTokensDetails json.RawMessage `json:"prompt_tokens_details"`
+}
+
+func (b openAIBackend) responseSchema() interface{} {
+	return &struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Created int64  `json:"created"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Index          int             `json:"index"`
+			Text           string          `json:"text"`
+			Logprobs       json.RawMessage `json:"logprobs"`
+			FinishReason   string          `json:"finish_reason"`
+			StopReason     json.RawMessage `json:"stop_reason"`
+			PromptLogprobs json.RawMessage `json:"prompt_logprobs"`
+		} `json:"choices"`
+		Usage             openAIUsage     `json:"usage"`
+		SystemFingerprint string          `json:"system_fingerprint"`
+		ServiceTier       string          `json:"service_tier"`
+		KVTransferParams  json.RawMessage `json:"kv_transfer_params"`
+	}{}
+}
+
+func (b embedBackend) responseSchema() interface{} {
+	if b.openAI {
+		return &struct {
+			Object string `json:"object"`
+			Data   []struct {
+				Object    string    `json:"object"`
+				Embedding []float64 `json:"embedding"`
+				Index     int       `json:"index"`
+			} `json:"data"`
+			Model string      `json:"model"`
+			Usage openAIUsage `json:"usage"`
+		}{}
+	}
+	return &struct {
+		Model      string      `json:"model"`
+		Embeddings [][]float64 `json:"embeddings"`
+		ollamaTimings
+	}{}
+}
+
+// validateStrictJSON 检查 -strict-json 不与改变响应格式的参数同时使用, 且每个后端都定义了响应结构
+func validateStrictJSON(backends []Backend) error {
+	if !*strictJSON {
+		return nil
+	}
+	if *streamMode || *responseFormat != "json" {
+		return errors.New(msg("strict_json_conflict"))
+	}
+	for _, b := range backends {
+		if _, ok := b.(schemaBackend); !ok {
+			return fmt.Errorf(msg("strict_json_unsupported"), b.Name())
+		}
+	}
+	return nil
+}
+
+// checkSchema 以 DisallowUnknownFields 将响应体解码到后端定义的结构中, 不符时返回原因, 相符或未开启 -strict-json 时返回空字符串
+func checkSchema(backend Backend, data []byte) string {
+	if !*strictJSON {
+		return ""
+	}
+	sb, ok := backend.(schemaBackend)
+	if !ok {
+		return ""
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(sb.responseSchema()); err != nil {
+		return err.Error()
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return msg("strict_json_trailing")
+	}
+	return ""
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"model-test/internal/monitor"
+)
+
+var scoreWeights = flag.String("score-weights", "", "按加权得分为各组测试排名, 如 latency=0.4,throughput=0.4,vram=0.2; 可用的指标: latency (平均响应)、p95、throughput (token/秒)、rps、vram (峰值显存)、success (成功率), 各指标在所有组之间归一化到 0-1 (最好为 1) 后按权重加权; 为空时不排名")
+
+// scoreMetric 为 -score-weights 中可用的一项指标
+type scoreMetric struct {
+	get         func(TestResult) (float64, bool) // 取值, 指标无法采集时返回 false
+	lowerBetter bool
+}
+
+var scoreMetrics = map[string]scoreMetric{
+	"latency":    {get: func(r TestResult) (float64, bool) { return r.AvgResponseTime, true }, lowerBetter: true},
+	"p95":        {get: func(r TestResult) (float64, bool) { return r.P95ResponseTime, true }, lowerBetter: true},
+	"throughput": {get: func(r TestResult) (float64, bool) { return r.TokensPerSecond, r.TokensPerSecond > 0 }},
+	"rps":        {get: func(r TestResult) (float64, bool) { return requestsPerSecond(r), true }},
+	"vram": {get: func(r TestResult) (float64, bool) {
+		return r.GPUMemoryUsed, !slices.Contains(r.UnavailableMetrics, monitor.NameGPUMemoryUsed)
+	}, lowerBetter: true},
+	"success": {get: func(r TestResult) (float64, bool) { return r.SuccessRate, true }},
+}
+
+// scoreWeight 为 -score-weights 中的一项, 保持用户给出的顺序
+type scoreWeight struct {
+	metric string
+	weight float64
+}
+
+// parseScoreWeights 解析 -score-weights, 权重不能为负且总和必须大于 0
+func parseScoreWeights(spec string) ([]scoreWeight, error) {
+	var weights []scoreWeight
+	total := 0.0
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		w, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil || w < 0 {
+			return nil, fmt.Errorf(msg("bad_score_weight"), item)
+		}
+		if _, known := scoreMetrics[name]; !known {
+			keys := make([]string, 0, len(scoreMetrics))
+			for k := range scoreMetrics {
+				keys = append(keys, k)
+			}
+			slices.Sort(keys)
+			return nil, fmt.Errorf(msg("unknown_score_metric"), name, strings.Join(keys, ","))
+		}
+		if slices.ContainsFunc(weights, func(sw scoreWeight) bool { return sw.metric == name }) {
+			return nil, fmt.Errorf(msg("duplicate_score_metric"), name)
+		}
+		weights = append(weights, scoreWeight{name, w})
+		total += w
+	}
+	if total <= 0 {
+		return nil, errors.New(msg("score_weights_zero"))
+	}
+	return weights, nil
+}
+
+// validateScoreWeights 检查 -score-weights 的格式
+func validateScoreWeights() error {
+	if *scoreWeights == "" {
+		return nil
+	}
+	_, err := parseScoreWeights(*scoreWeights)
+	return err
+}
+
+// scoreEntry 为排行榜中的一组测试
+type scoreEntry struct {
+	Rank          int                `json:"rank"`
+	Backend       string             `json:"backend"`
+	Model         string             `json:"model"`
+	Concurrency   int                `json:"concurrency"`
+	ExecutionMode string             `json:"execution_mode,omitempty"`
+	Score         float64            `json:"score"`      // 0-100, 各项归一化得分按权重的加权平均
+	Components    map[string]float64 `json:"components"` // 各指标归一化后的得分 (0-1), 不含无法采集的指标
+}
+
+// leaderboard 按 -score-weights 为有成功请求的组打分并从高到低排序; 每项指标按所有组的最小、最大值归一化,
+// 所有组取值相同时都得 1; 某组无法采集的指标不参与该组的加权, 其余指标的权重按比例放大
+func leaderboard(results []TestResult) []scoreEntry {
+	if *scoreWeights == "" {
+		return nil
+	}
+	weights, err := parseScoreWeights(*scoreWeights)
+	if err != nil {
+		return nil
+	}
+	var scored []TestResult
+	for _, r := range results {
+		if r.Successes > 0 {
+			scored = append(scored, r)
+		}
+	}
+	if len(scored) == 0 {
+		return nil
+	}
+
+	entries := make([]scoreEntry, len(scored))
+	used := make([]float64, len(scored)) // 各组参与加权的权重之和
+	for i, r := range scored {
+		entries[i] = scoreEntry{
+			Backend: r.Backend, Model: r.Model, Concurrency: r.Concurrency, ExecutionMode: r.ExecutionMode,
+			Components: make(map[string]float64, len(weights)),
+		}
+	}
+	for _, w := range weights {
+		m := scoreMetrics[w.metric]
+		lo, hi, seen := 0.0, 0.0, false
+		for _, r := range scored {
+			v, ok := m.get(r)
+			if !ok {
+				continue
+			}
+			if !seen {
+				lo, hi, seen = v, v, true
+			}
+			lo, hi = min(lo, v), max(hi, v)
+		}
+		for i, r := range scored {
+			v, ok := m.get(r)
+			if !ok {
+				continue
+			}
+			norm := 1.0
+			switch {
+			case hi == lo:
+			case m.lowerBetter:
+				norm = (hi - v) / (hi - lo)
+			default:
+				norm = (v - lo) / (hi - lo)
+			}
+			entries[i].Components[w.metric] = norm
+			entries[i].Score += norm * w.weight
+			used[i] += w.weight
+		}
+	}
+	for i := range entries {
+		if used[i] > 0 {
+			entries[i].Score = entries[i].Score / used[i] * 100
+		}
+	}
+
+	slices.SortStableFunc(entries, func(a, b scoreEntry) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+	return entries
+}
+
+// printLeaderboard 输出按加权得分排序的排行榜, 最后几列为各指标的归一化得分
+func printLeaderboard(results []TestResult) {
+	entries := leaderboard(results)
+	if len(entries) == 0 {
+		return
+	}
+	weights, _ := parseScoreWeights(*scoreWeights)
+
+	fmt.Printf(msg("leaderboard_title"), *scoreWeights)
+	w := newTableWriter(os.Stdout)
+	fmt.Fprint(w, msg("leaderboard_header"))
+	for _, sw := range weights {
+		fmt.Fprintf(w, "%s\t", sw.metric)
+	}
+	fmt.Fprintln(w)
+	for _, e := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%.1f\t", e.Rank, e.Backend, e.Model+modeSuffix(e.ExecutionMode), e.Concurrency, e.Score)
+		for _, sw := range weights {
+			if v, ok := e.Components[sw.metric]; ok {
+				fmt.Fprintf(w, "%.2f\t", v)
+			} else {
+				fmt.Fprint(w, "-\t")
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	slaP95            = flag.Duration("sla-p95", 0, "按 p95 响应时间目标为每个模型自动搜索最佳并发数 (先倍增再二分), 代替固定的 -concurrency 列表; 0 表示不启用")
+	slaMaxConcurrency = flag.Int("sla-max-concurrency", 64, "-sla-p95 搜索的最大并发数")
+)
+
+// slaMinSuccessRate 为满足目标所需的最低成功率(%), 失败请求不计入 p95, 否则大量失败反而显得延迟很低
+const slaMinSuccessRate = 99.0
+
+// validateSLASearch 检查 -sla-p95 相关参数
+func validateSLASearch() error {
+	if *slaP95 == 0 {
+		return nil
+	}
+	if *slaP95 < 0 || *slaMaxConcurrency < 1 {
+		return errors.New(msg("bad_sla"))
+	}
+	if *complexityMode != "" || *comparePrompts || *soakMode {
+		return errors.New(msg("sla_conflict"))
+	}
+	return nil
+}
+
+// meetsSLA 判断一组结果是否满足 -sla-p95
+func meetsSLA(r TestResult) bool {
+	return r.Successes > 0 && r.SuccessRate >= slaMinSuccessRate &&
+		r.P95ResponseTime <= float64(*slaP95)/float64(time.Millisecond)
+}
+
+// requestsPerSecond 返回一组结果每秒完成的成功请求数
+func requestsPerSecond(r TestResult) float64 {
+	if r.DurationSec <= 0 {
+		return 0
+	}
+	return float64(r.Successes) / r.DurationSec
+}
+
+// runSLASearch 对每个 后端+模型 从并发 1 开始倍增, 直到不满足目标或达到 -sla-max-concurrency,
+// 再在最后一个满足和第一个不满足的并发之间二分; 所有测过的组都会输出, 吞吐最高的满足目标的组标记为最佳
+func runSLASearch(backends []Backend, models []string, meta runMetadata) int {
+	var results []TestResult
+search:
+	for _, model := range models {
+		for _, backend := range backends {
+			tested := make(map[int]int) // 并发数 -> results 下标
+			try := func(concurrency int) bool {
+				if i, ok := tested[concurrency]; ok {
+					return meetsSLA(results[i])
+				}
+				fmt.Printf(msg("sla_testing"), backend.Name(), model, concurrency)
+				result := runTest(backend, model, concurrency)
+				applyExpectedTPS(&result)
+				coolDown()
+				tested[concurrency] = len(results)
+				results = append(results, result)
+				notifyCell(result)
+				return meetsSLA(result)
+			}
+
+			// pass 为已知满足目标的最大并发, fail 为已知不满足的最小并发, 0 表示尚未找到
+			pass, fail := 0, 0
+			for c := 1; shutdownCtx.Err() == nil; c = min(c*2, *slaMaxConcurrency) {
+				if !try(c) {
+					fail = c
+					break
+				}
+				pass = c
+				if c == *slaMaxConcurrency {
+					break
+				}
+			}
+			for fail > 0 && fail-pass > 1 && shutdownCtx.Err() == nil {
+				mid := (pass + fail) / 2
+				if try(mid) {
+					pass = mid
+				} else {
+					fail = mid
+				}
+			}
+			if shutdownCtx.Err() != nil {
+				break search
+			}
+
+			best := -1
+			for _, i := range tested {
+				if meetsSLA(results[i]) && (best < 0 || requestsPerSecond(results[i]) > requestsPerSecond(results[best])) {
+					best = i
+				}
+			}
+			if best >= 0 {
+				results[best].SLABest = true
+			}
+		}
+	}
+
+	if err := writeResults(results, nil, meta); err != nil {
+		fmt.Println(msg("output_error"), err)
+		return exitOutput
+	}
+	if shutdownCtx.Err() != nil {
+		return interruptedExitCode()
+	}
+	return exitCode(results)
+}
+
+// printSLASearch 在 -sla-p95 时输出每个 后端+模型 的最佳并发数, 没有满足目标的并发时显示 -
+func printSLASearch(results []TestResult) {
+	if *slaP95 == 0 || len(results) == 0 {
+		return
+	}
+
+	type modelKey struct{ backend, model string }
+	var order []modelKey
+	best := make(map[modelKey]TestResult)
+	tested := make(map[modelKey]int)
+	for _, r := range results {
+		key := modelKey{r.Backend, r.Model}
+		if tested[key] == 0 {
+			order = append(order, key)
+		}
+		tested[key]++
+		if r.SLABest {
+			best[key] = r
+		}
+	}
+
+	fmt.Printf(msg("sla_title"), *slaP95)
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("sla_header"))
+	for _, key := range order {
+		r, ok := best[key]
+		if !ok {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t-\t-\t%d\t\n", key.backend, key.model, tested[key])
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%.1f\t%.2f\t%.1f\t%d\t\n",
+			key.backend, key.model, r.Concurrency, r.P95ResponseTime, r.AvgResponseTime, r.SuccessRate,
+			requestsPerSecond(r), r.TokensPerSecond, tested[key])
+	}
+	w.Flush()
+}
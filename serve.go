@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	serveAddr  = flag.String("serve", "", "以 HTTP 服务方式运行, 如 :8080 (未指定主机时只监听 127.0.0.1, 需要对外提供时写明如 0.0.0.0:8080): POST /runs 提交测试参数, GET /runs/{id} 查看状态和进度, GET /runs/{id}/results 获取 JSON 结果; 测试依次排队执行")
+	serveToken = flag.String("serve-token", "", "-serve 时要求每个请求带 Authorization: Bearer <token>, 建议用环境变量 MODELTEST_SERVE_TOKEN 设置; 为空时不验证")
+)
+
+// serveLogLines 为运行状态中保留的最近输出行数
+const serveLogLines = 20
+
+// serveAllowedFlags 为提交的运行可以设置的参数, 只包括决定测试内容的参数; 文件路径、后端地址、
+// 执行命令和推送地址等只能在启动服务的命令行中设置
+var serveAllowedFlags = []string{
+	"backend", "models", "concurrency", "max-requests", "max-cell-duration", "max-matrix-duration",
+	"max-warmup", "warmup-until-stable", "warmup-window", "warmup-split", "min-samples",
+	"num-predict", "batch-size", "stream", "system", "prompt-sampling", "fixed-prompt-sequence", "prompt-nonce",
+	"timeout-per-request", "cooldown-concurrency", "cooldown-model", "cold-start",
+	"complexity", "complexity-concurrency", "complexity-tiers", "fan-out", "inflight",
+	"no-keepalive", "compare-keepalive", "compare-against-cpu", "compare-prompts", "percentiles",
+	"expected-tps", "sla-p95", "sla-max-concurrency",
+	"soak", "soak-concurrency", "soak-duration", "soak-interval", "soak-model", "mix", "only", "skip", "note",
+	"per-worker", "strict", "strict-json", "score-weights", "estimate-tokens", "include-empty", "worker-max-failures",
+	"histogram-buckets", "model-switch", "switch-rounds", "predict-sweep", "predict-concurrency",
+	"cache-check", "cache-check-requests", "embed-model", "embed-concurrency",
+	"background-concurrency", "background-prompt", "response-metadata", "resource-samples", "per-core-cpu",
+	"verbose", "quiet",
+}
+
+// serveOwnFlags 为服务自身使用的参数, 不传给子进程; 启动服务时设置的其他参数作为每次运行的默认值
+var serveOwnFlags = []string{"serve", "serve-token", "output", "output-file", "tui", "validate", "lang"}
+
+// runRequest 为 POST /runs 的请求体, flags 的键为不带 - 的参数名
+type runRequest struct {
+	Flags map[string]string `json:"flags"`
+}
+
+// runStatus 为一次排队运行的状态
+type runStatus struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"` // queued、running、done、failed
+	Args       []string   `json:"args"`
+	QueuedAt   time.Time  `json:"queued_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Log        []string   `json:"log,omitempty"` // 最近的输出, 包括每组测试后的进度和剩余时间
+
+	resultsFile string
+}
+
+// runServer 保存所有运行的状态, 由单个协程依次执行排队的运行
+type runServer struct {
+	mu    sync.Mutex
+	runs  map[string]*runStatus
+	queue chan *runStatus
+	dir   string // 存放各次运行结果文件的临时目录
+	next  int
+	done  chan struct{} // worker 退出 (当前运行的子进程已结束) 后关闭
+}
+
+// serveArgs 将提交的参数转换为命令行, 拒绝未知参数和不在 serveAllowedFlags 中的参数
+func serveArgs(flags map[string]string) ([]string, error) {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		if flag.Lookup(name) == nil {
+			return nil, fmt.Errorf(msg("serve_unknown_flag"), name)
+		}
+		if !slices.Contains(serveAllowedFlags, name) {
+			return nil, fmt.Errorf(msg("serve_flag_not_allowed"), name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := make([]string, len(names))
+	for i, name := range names {
+		args[i] = "-" + name + "=" + flags[name]
+	}
+	return args, nil
+}
+
+// inheritedArgs 返回启动服务时设置的参数 (命令行或环境变量), 作为每次运行的默认值, 提交的参数在其后可以覆盖
+func inheritedArgs() []string {
+	var args []string
+	flag.Visit(func(f *flag.Flag) {
+		if !slices.Contains(serveOwnFlags, f.Name) {
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	return args
+}
+
+// serveListenAddr 为没有主机部分的地址 (如 :8080 或 8080) 补上 127.0.0.1
+func serveListenAddr(addr string) (string, error) {
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// authorized 在设置了 -serve-token 时检查请求的 Bearer token
+func authorized(r *http.Request) bool {
+	if *serveToken == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(*serveToken)) == 1
+}
+
+// requireToken 拒绝没有正确 token 的请求
+func requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, errors.New(msg("serve_unauthorized")))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serve 启动 HTTP 服务, 收到中断信号后停止接受请求, 等待正在进行的运行输出已完成的结果后退出
+func serve(addr string) int {
+	addr, err := serveListenAddr(addr)
+	if err != nil {
+		fmt.Println(msg("usage_error"), err)
+		return exitUsage
+	}
+	dir, err := os.MkdirTemp("", "model-test-serve-")
+	if err != nil {
+		fmt.Println(msg("output_error"), err)
+		return exitOutput
+	}
+	defer os.RemoveAll(dir)
+
+	s := &runServer{runs: make(map[string]*runStatus), queue: make(chan *runStatus, 100), dir: dir, done: make(chan struct{})}
+	go s.worker()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /runs", s.handleSubmit)
+	mux.HandleFunc("GET /runs", s.handleList)
+	mux.HandleFunc("GET /runs/{id}", s.handleStatus)
+	mux.HandleFunc("GET /runs/{id}/results", s.handleResults)
+	srv := &http.Server{Addr: addr, Handler: requireToken(mux)}
+
+	go func() {
+		<-shutdownCtx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	fmt.Printf(msg("serve_listening"), addr)
+	if host, _, _ := net.SplitHostPort(addr); *serveToken == "" && !isLoopback(host) {
+		fmt.Printf(msg("serve_no_token"), addr)
+	}
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Println(msg("serve_failed"), err)
+		return exitUsage
+	}
+	<-s.done
+	return interruptedExitCode()
+}
+
+// isLoopback 判断监听的主机是否只能从本机访问
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *runServer) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req runRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	args, err := serveArgs(req.Flags)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	s.next++
+	run := &runStatus{ID: strconv.Itoa(s.next), Status: "queued", Args: args, QueuedAt: time.Now()}
+	run.resultsFile = filepath.Join(s.dir, run.ID+".json")
+	s.runs[run.ID] = run
+	status := *run
+	s.mu.Unlock()
+
+	select {
+	case s.queue <- run:
+	default:
+		s.mu.Lock()
+		delete(s.runs, run.ID)
+		s.mu.Unlock()
+		writeJSONError(w, http.StatusServiceUnavailable, errors.New(msg("serve_queue_full")))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (s *runServer) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	list := make([]runStatus, 0, len(s.runs))
+	for _, run := range s.runs {
+		status := *run
+		status.Log = nil // 列表中不含输出, 需要时查看单个运行
+		list = append(list, status)
+	}
+	s.mu.Unlock()
+	slices.SortFunc(list, func(a, b runStatus) int { return a.QueuedAt.Compare(b.QueuedAt) })
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *runServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	run, ok := s.runs[r.PathValue("id")]
+	var status runStatus
+	if ok {
+		status = *run
+		status.Log = slices.Clone(run.Log)
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, errors.New(msg("serve_not_found")))
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleResults 返回与命令行 -output json 相同结构的结果; 运行尚未结束时返回 409
+func (s *runServer) handleResults(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	run, ok := s.runs[r.PathValue("id")]
+	var state, file string
+	if ok {
+		state, file = run.Status, run.resultsFile
+	}
+	s.mu.Unlock()
+	switch {
+	case !ok:
+		writeJSONError(w, http.StatusNotFound, errors.New(msg("serve_not_found")))
+		return
+	case state == "queued" || state == "running":
+		writeJSONError(w, http.StatusConflict, fmt.Errorf(msg("serve_not_finished"), state))
+		return
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, errors.New(msg("serve_no_results")))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// worker 依次执行排队的运行, 同一时间只运行一个, 避免相互干扰
+func (s *runServer) worker() {
+	defer close(s.done)
+	for {
+		select {
+		case run := <-s.queue:
+			s.execute(run)
+		case <-shutdownCtx.Done():
+			return
+		}
+	}
+}
+
+// execute 以子进程运行本程序, 结果以 JSON 写入临时文件, 输出逐行保存为进度
+func (s *runServer) execute(run *runStatus) {
+	started := time.Now()
+	s.mu.Lock()
+	run.Status, run.StartedAt = "running", &started
+	args := append(inheritedArgs(), run.Args...)
+	args = append(args, "-output=json", "-output-file="+run.resultsFile, "-lang="+*langFlag)
+	s.mu.Unlock()
+	fmt.Printf(msg("serve_run_start"), run.ID, redactArgs(append([]string{""}, args...))[1:])
+
+	code, err := s.runChild(run, args)
+	if err != nil {
+		s.appendLog(run, err.Error())
+	}
+
+	finished := time.Now()
+	s.mu.Lock()
+	run.FinishedAt, run.ExitCode = &finished, &code
+	run.Status = "done"
+	if code != exitOK && code != exitCellFailed && code != exitStrict && code != exitBelowTarget {
+		run.Status = "failed"
+	}
+	s.mu.Unlock()
+	fmt.Printf(msg("serve_run_done"), run.ID, code, finished.Sub(started).Round(time.Second))
+}
+
+// runChild 运行子进程并逐行收集标准输出和标准错误, 返回退出码; 无法启动时返回 exitUsage 和错误。
+// 服务退出时先向子进程发送 SIGTERM, 让它输出已完成的结果, 超过 -shutdown-grace 仍未退出才强制结束
+func (s *runServer) runChild(run *runStatus, args []string) (int, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return exitUsage, err
+	}
+	cmd := exec.CommandContext(shutdownCtx, self, args...)
+	cmd.Cancel = func() error { return terminateProcess(cmd.Process) }
+	cmd.WaitDelay = *shutdownGrace
+	// 子进程不能继承 MODELTEST_SERVE 和 MODELTEST_SERVE_TOKEN, 否则会再次启动服务
+	cmd.Env = slices.DeleteFunc(os.Environ(), func(kv string) bool {
+		return strings.HasPrefix(kv, envName("serve")+"=") || strings.HasPrefix(kv, envName("serve-token")+"=")
+	})
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return exitUsage, err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return exitUsage, err
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		s.appendLog(run, scanner.Text())
+	}
+	if err := cmd.Wait(); err != nil && cmd.ProcessState == nil {
+		return exitUsage, err
+	}
+	return cmd.ProcessState.ExitCode(), nil
+}
+
+func (s *runServer) appendLog(run *runStatus, line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run.Log = append(run.Log, line)
+	if len(run.Log) > serveLogLines {
+		run.Log = run.Log[len(run.Log)-serveLogLines:]
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
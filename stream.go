@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var streamMode = flag.Bool("stream", false, "以流式接口发送请求, 统计首 token 延迟 (TTFT) 和 token 间隔 (ITL); 不能与 -batch-size、-body-template 和 -response-format text 同时使用")
+
+// streamResult 为读取完一个流式响应后的结果, 每个带文本的分块视为一个 token;
+// 不保留生成的文本, 只记录长度和估算 token 数需要的计数
+type streamResult struct {
+	textLen     int
+	counter     tokenCounter
+	last        map[string]interface{} // 最后一个分块, 带有服务端报告的 token 数等统计
+	ttft        time.Duration
+	gaps        []time.Duration // 相邻两个 token 之间的间隔
+	bytes       int64
+	tokens      int
+	tokensKnown bool
+}
+
+// enableStreaming 将请求体改为流式; OpenAI 兼容接口需要 include_usage 才会在最后返回 token 数
+func enableStreaming(backend Backend, body map[string]interface{}) {
+	body["stream"] = true
+	if _, ok := backend.(openAIBackend); ok {
+		body["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+}
+
+// streamedText 为流式响应生成文本的字节数, 代替文本本身传给 logRequest
+type streamedText int
+
+// readStream 逐行读取 ollama 的 NDJSON 或 OpenAI 兼容接口的 SSE 流, 记录每个 token 的到达时间
+func readStream(r io.Reader, backend Backend, start time.Time) (streamResult, error) {
+	var res streamResult
+	counter := &countingReader{r: r}
+	scanner := bufio.NewScanner(counter)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var lastToken time.Time
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		line = bytes.TrimPrefix(line, []byte("data:"))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if string(line) == "[DONE]" {
+			break
+		}
+
+		// 流中途出错时 ollama 会发送 {"error": "..."}
+		if serverMsg := backend.ErrorMessage(line); serverMsg != "" {
+			res.bytes = counter.n
+			return res, errors.New(serverMsg)
+		}
+
+		var chunk map[string]interface{}
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			res.bytes = counter.n
+			return res, err
+		}
+		res.last = chunk
+		if n, ok := backend.CompletionTokens(chunk); ok {
+			res.tokens, res.tokensKnown = n, true
+		}
+
+		piece, _ := backend.ResponseText(chunk).(string)
+		if piece == "" {
+			continue
+		}
+		now := time.Now()
+		if lastToken.IsZero() {
+			res.ttft = now.Sub(start)
+		} else {
+			res.gaps = append(res.gaps, now.Sub(lastToken))
+		}
+		lastToken = now
+		res.textLen += len(piece)
+		res.counter.add(piece)
+	}
+
+	res.bytes = counter.n
+	return res, scanner.Err()
+}
+
+// validateStream 检查 -stream 与其他参数的冲突
+func validateStream() error {
+	if !*streamMode {
+		return nil
+	}
+	if *batchSize > 1 || *bodyTemplate != "" || *responseFormat != "json" {
+		return errors.New(msg("stream_conflict"))
+	}
+	return nil
+}
+
+// printStreamStats 在 -stream 时输出首 token 延迟和 token 间隔
+func printStreamStats(results []TestResult) {
+	if !*streamMode || len(results) == 0 {
+		return
+	}
+
+	fmt.Println(msg("stream_title"))
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("stream_header"))
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			r.Backend, r.Model, r.Concurrency, r.TTFTAvgMs, r.TTFTP95Ms, r.ITLAvgMs, r.ITLP95Ms)
+	}
+	w.Flush()
+}
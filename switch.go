@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+var (
+	modelSwitch  = flag.Bool("model-switch", false, "测量模型切换开销: 在 -models 的各模型之间轮流发送请求, 以切换后第一个请求与紧接着的第二个请求的耗时差作为切换代价 (显存中的模型被换出后重新加载)")
+	switchRounds = flag.Int("switch-rounds", 5, "-model-switch 轮换的轮数")
+)
+
+// modelSwitchResult 为 -model-switch 中一个 后端+模型 的结果
+type modelSwitchResult struct {
+	Backend      string  `json:"backend"`
+	Model        string  `json:"model"`
+	Switches     int     `json:"switches"`       // 成功测量的切换次数
+	Failures     int     `json:"failures"`       // 失败的请求数
+	SwitchAvgMs  float64 `json:"switch_avg_ms"`  // 切换后第一个请求的平均耗时
+	WarmAvgMs    float64 `json:"warm_avg_ms"`    // 紧接着的第二个请求的平均耗时
+	PenaltyAvgMs float64 `json:"penalty_avg_ms"` // 平均切换代价
+	PenaltyMaxMs float64 `json:"penalty_max_ms"`
+}
+
+// modelSwitchDocument 为 -model-switch 的 JSON 输出
+type modelSwitchDocument struct {
+	SchemaVersion int                 `json:"schema_version"`
+	Metadata      runMetadata         `json:"metadata"`
+	Results       []modelSwitchResult `json:"model_switch"`
+}
+
+// validateModelSwitch 检查 -model-switch 的参数
+func validateModelSwitch(models []string) error {
+	if !*modelSwitch {
+		return nil
+	}
+	if len(models) < 2 {
+		return errors.New(msg("switch_models"))
+	}
+	if *switchRounds < 1 {
+		return errors.New(msg("bad_switch_rounds"))
+	}
+	if *complexityMode != "" || *comparePrompts || *soakMode || *slaP95 > 0 {
+		return errors.New(msg("switch_conflict"))
+	}
+	if *outputFormat == "influx" || *outputFormat == "csv" {
+		return fmt.Errorf(msg("switch_output"), *outputFormat)
+	}
+	return nil
+}
+
+// runModelSwitch 对每个后端先让每个模型各处理一个请求 (排除首次加载), 再按顺序轮换 -switch-rounds 轮,
+// 每次切换到一个模型后连续发送两个相同的请求, 两者的耗时差即为换出其他模型并重新加载该模型的代价
+func runModelSwitch(backends []Backend, models []string, meta runMetadata) int {
+	var results []modelSwitchResult
+	for _, backend := range backends {
+		stats := make([]modelSwitchResult, len(models))
+		clients := make([]*http.Client, len(models))
+		for i, model := range models {
+			stats[i] = modelSwitchResult{Backend: backend.Name(), Model: model}
+			clients[i] = &http.Client{Timeout: timeouts.forModel(model), Transport: requestTransport()}
+			if _, err := sendRequest(shutdownCtx, 0, clients[i], backend, model, prompts[:1]); err != nil {
+				fmt.Println(msg("switch_failed"), err)
+			}
+		}
+
+		fmt.Printf(msg("switch_start"), backend.Name(), len(models), *switchRounds)
+	rounds:
+		for round := 0; round < *switchRounds; round++ {
+			for i, model := range models {
+				if shutdownCtx.Err() != nil {
+					break rounds
+				}
+				first, err := sendRequest(shutdownCtx, 0, clients[i], backend, model, prompts[:1])
+				if err != nil {
+					stats[i].Failures++
+					fmt.Println(msg("switch_failed"), err)
+					continue
+				}
+				warm, err := sendRequest(shutdownCtx, 0, clients[i], backend, model, prompts[:1])
+				if err != nil {
+					stats[i].Failures++
+					fmt.Println(msg("switch_failed"), err)
+					continue
+				}
+				s := &stats[i]
+				// 模型都能常驻显存时代价接近 0, 可能因抖动为负, 不截断以免平均值偏大
+				penalty := (first.elapsed - warm.elapsed).Seconds() * 1000
+				s.Switches++
+				s.SwitchAvgMs += first.elapsed.Seconds() * 1000
+				s.WarmAvgMs += warm.elapsed.Seconds() * 1000
+				s.PenaltyAvgMs += penalty
+				s.PenaltyMaxMs = max(s.PenaltyMaxMs, penalty)
+			}
+		}
+
+		for _, s := range stats {
+			if s.Switches > 0 {
+				n := float64(s.Switches)
+				s.SwitchAvgMs /= n
+				s.WarmAvgMs /= n
+				s.PenaltyAvgMs /= n
+			}
+			results = append(results, s)
+		}
+	}
+
+	if *outputFormat == "json" {
+		meta.FinishedAt = time.Now()
+		if err := writeModelSwitch(results, meta); err != nil {
+			fmt.Println(msg("output_error"), err)
+			return exitOutput
+		}
+	} else {
+		printModelSwitch(results)
+	}
+	if shutdownCtx.Err() != nil {
+		return interruptedExitCode()
+	}
+	for _, s := range results {
+		if s.Switches == 0 {
+			return exitCellFailed
+		}
+	}
+	return exitOK
+}
+
+// printModelSwitch 输出每个模型的切换代价, 以及每个后端所有切换的平均代价
+func printModelSwitch(results []modelSwitchResult) {
+	fmt.Println(msg("switch_title"))
+	w := newTableWriter(os.Stdout)
+	fmt.Fprintln(w, msg("switch_header"))
+	for _, s := range results {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			s.Backend, s.Model, s.Switches, s.Failures,
+			s.SwitchAvgMs, s.WarmAvgMs, s.PenaltyAvgMs, s.PenaltyMaxMs)
+	}
+	w.Flush()
+
+	var order []string
+	total := make(map[string]float64)
+	count := make(map[string]int)
+	for _, s := range results {
+		if _, ok := count[s.Backend]; !ok {
+			order = append(order, s.Backend)
+		}
+		total[s.Backend] += s.PenaltyAvgMs * float64(s.Switches)
+		count[s.Backend] += s.Switches
+	}
+	for _, b := range order {
+		if count[b] > 0 {
+			fmt.Printf(msg("switch_summary"), b, total[b]/float64(count[b]), count[b])
+		}
+	}
+}
+
+// writeModelSwitch 将 -model-switch 的结果以 JSON 写入 -output-file 或标准输出
+func writeModelSwitch(results []modelSwitchResult, meta runMetadata) error {
+	var out io.Writer = os.Stdout
+	if *outputFile != "" {
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(modelSwitchDocument{SchemaVersion: resultsSchemaVersion, Metadata: meta, Results: results})
+}
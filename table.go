@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// tablePadding 为相邻两列之间的最少空格数
+const tablePadding = 2
+
+// tableWriter 与 tabwriter 的用法相同 (单元格以 \t 结尾, Flush 时对齐输出),
+// 但按终端显示宽度而不是字符数计算列宽, 中文等宽字符占两列, 表头和内容才能对齐
+type tableWriter struct {
+	out io.Writer
+	buf bytes.Buffer
+}
+
+func newTableWriter(out io.Writer) *tableWriter {
+	return &tableWriter{out: out}
+}
+
+func (t *tableWriter) Write(p []byte) (int, error) {
+	return t.buf.Write(p)
+}
+
+// Flush 对齐并输出缓冲的所有行; 与 tabwriter 一样, 行尾没有以 \t 结尾的文本不参与对齐
+func (t *tableWriter) Flush() error {
+	text := strings.TrimSuffix(t.buf.String(), "\n")
+	t.buf.Reset()
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.Split(text, "\n")
+	rows := make([][]string, len(lines))
+	var widths []int
+	for i, line := range lines {
+		rows[i] = strings.Split(line, "\t")
+		for j, cell := range rows[i][:len(rows[i])-1] {
+			if j == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[j] = max(widths[j], runewidth.StringWidth(cell))
+		}
+	}
+
+	var b strings.Builder
+	for _, row := range rows {
+		last := len(row) - 1
+		for j, cell := range row[:last] {
+			b.WriteString(cell)
+			b.WriteString(strings.Repeat(" ", widths[j]-runewidth.StringWidth(cell)+tablePadding))
+		}
+		b.WriteString(row[last])
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(t.out, b.String())
+	return err
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// ttyWidth 在不支持的平台上返回 0, 只能通过 COLUMNS 环境变量指定终端宽度
+func ttyWidth(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+var timeoutSpec = flag.String("timeout-per-request", requestTimeout.String(), "单个请求的超时时间, 可按模型分别设置, 如 60s,deepseek-r1:32b=5m,deepseek-r1:1.5b=20s (不带模型名的一项为默认值); 超时的请求单独计数")
+
+// requestTimeouts 为 -timeout-per-request 解析后的结果
+type requestTimeouts struct {
+	fallback time.Duration
+	perModel map[string]time.Duration
+}
+
+// timeouts 为当前生效的请求超时设置
+var timeouts = requestTimeouts{fallback: requestTimeout}
+
+// parseTimeouts 解析 -timeout-per-request
+func parseTimeouts(spec string) (requestTimeouts, error) {
+	t := requestTimeouts{fallback: requestTimeout, perModel: make(map[string]time.Duration)}
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		// 模型名本身可能带 : 但不会带 =
+		model, value, hasModel := strings.Cut(item, "=")
+		if !hasModel {
+			model, value = "", item
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d <= 0 || hasModel && strings.TrimSpace(model) == "" {
+			return t, fmt.Errorf(msg("bad_timeout"), item)
+		}
+		if hasModel {
+			t.perModel[strings.TrimSpace(model)] = d
+		} else {
+			t.fallback = d
+		}
+	}
+	return t, nil
+}
+
+// forModel 返回模型的请求超时时间
+func (t requestTimeouts) forModel(model string) time.Duration {
+	if d, ok := t.perModel[model]; ok {
+		return d
+	}
+	return t.fallback
+}
+
+// isTimeoutError 判断请求是否因超过 -timeout-per-request 而失败
+func isTimeoutError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
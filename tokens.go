@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"unicode"
+)
+
+var tokenEstimator = flag.String("estimate-tokens", "", "服务端没有报告生成 token 数时在客户端估算: words 按空白分词计数, approx 按每个中日韩字符 1 个、其余约 4 个字符 1 个估算; 为空时不估算")
+
+// validateTokenEstimator 检查 -estimate-tokens 参数
+func validateTokenEstimator(name string) error {
+	switch name {
+	case "", "words", "approx":
+		return nil
+	}
+	return fmt.Errorf(msg("unknown_token_estimator"), name)
+}
+
+// tokenCounter 逐段累计估算 token 数需要的计数, 流式响应不必保留完整文本
+type tokenCounter struct {
+	words  int
+	cjk    int
+	other  int // 非空白、非中日韩字符数
+	inWord bool
+}
+
+// add 累计一段文本, 分段的边界落在单词中间时不会多计
+func (c *tokenCounter) add(text string) {
+	for _, r := range text {
+		space := unicode.IsSpace(r)
+		if !space && !c.inWord {
+			c.words++
+		}
+		c.inWord = !space
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			c.cjk++
+		case !space:
+			c.other++
+		}
+	}
+}
+
+// blank 判断累计的文本是否全为空白
+func (c *tokenCounter) blank() bool {
+	return c.words == 0
+}
+
+// count 按 -estimate-tokens 选择的方式返回估算的 token 数
+func (c *tokenCounter) count() int {
+	if *tokenEstimator == "words" {
+		return c.words
+	}
+	// 接近 BPE 分词器的经验值: 中日韩字符大多单独成 token, 英文等约 4 个字符一个 token
+	return c.cjk + (c.other+3)/4
+}
+
+// estimateTokens 按 -estimate-tokens 选择的方式估算文本的 token 数
+func estimateTokens(text string) int {
+	var c tokenCounter
+	c.add(text)
+	return c.count()
+}
+
+// estimateMissingTokens 在响应没有 token 数时用生成的文本估算; 批量请求的文本只有第一条, 不做估算
+func estimateMissingTokens(outcome *requestOutcome, text interface{}, batchLen int) {
+	s, ok := text.(string)
+	if !ok {
+		return
+	}
+	var c tokenCounter
+	c.add(s)
+	applyTokenEstimate(outcome, &c, batchLen)
+}
+
+// applyTokenEstimate 在响应没有 token 数且启用了 -estimate-tokens 时使用 c 的估算值
+func applyTokenEstimate(outcome *requestOutcome, c *tokenCounter, batchLen int) {
+	if *tokenEstimator == "" || outcome.tokensKnown || batchLen > 1 {
+		return
+	}
+	outcome.tokens = c.count()
+	outcome.tokensKnown, outcome.tokensEstimated = true, true
+}
+
+// tokenCell 格式化平均输出 token 数, 含有客户端估算的值时加 ~ 前缀
+func tokenCell(r TestResult) string {
+	cell := fmt.Sprintf("%.1f", r.AvgCompletionTokens)
+	if r.EstimatedTokenSamples > 0 {
+		cell = "~" + cell
+	}
+	return cell
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var traceFile = flag.String("trace-file", "", "将每个请求的开始时间、耗时、状态码、错误、提示词编号和并发编号逐行 (JSON Lines) 追加到该文件, 用于自定义分析; 高吞吐时文件会很大")
+
+// traceBuffer 为等待写入的记录数, 写入跟不上时丢弃新记录而不阻塞请求
+const traceBuffer = 4096
+
+// traceRecord 为 -trace-file 中的一行
+type traceRecord struct {
+	Backend     string    `json:"backend"`
+	Model       string    `json:"model"`
+	Concurrency int       `json:"concurrency"`
+	Worker      int       `json:"worker"`
+	Start       time.Time `json:"start"`
+	LatencyMs   float64   `json:"latency_ms"`
+	Status      int       `json:"status,omitempty"` // 未收到响应时为空
+	Error       string    `json:"error,omitempty"`
+	Prompts     []int     `json:"prompts"` // 使用的提示词在提示词列表中的编号
+}
+
+// traceEntry 为写入协程收到的一项, flushed 不为 nil 时表示刷新请求, 写入协程通过它返回此前的第一个写入错误
+type traceEntry struct {
+	record  traceRecord
+	flushed chan error
+}
+
+// traceWriter 由单独的协程写入 -trace-file, 请求协程只做非阻塞发送
+type traceWriter struct {
+	entries chan traceEntry
+	dropped atomic.Int64 // 因缓冲区满丢弃的记录数
+}
+
+// tracer 为当前的请求记录, 未设置 -trace-file 时为 nil
+var tracer *traceWriter
+
+// openTrace 以追加方式打开 -trace-file 并启动写入协程
+func openTrace(path string) (*traceWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	t := &traceWriter{entries: make(chan traceEntry, traceBuffer)}
+	go func() {
+		w := bufio.NewWriter(f)
+		enc := json.NewEncoder(w)
+		var writeErr error
+		for e := range t.entries {
+			if e.flushed != nil {
+				if err := w.Flush(); writeErr == nil {
+					writeErr = err
+				}
+				e.flushed <- writeErr
+				writeErr = nil
+				continue
+			}
+			if err := enc.Encode(e.record); writeErr == nil {
+				writeErr = err
+			}
+		}
+	}()
+	return t, nil
+}
+
+// emit 提交一条记录, 缓冲区已满时丢弃; 可在多个协程中并发调用
+func (t *traceWriter) emit(r traceRecord) {
+	select {
+	case t.entries <- traceEntry{record: r}:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// Sync 等待已提交的记录写入文件, 并报告写入错误和丢弃的记录数
+func (t *traceWriter) Sync() {
+	flushed := make(chan error)
+	t.entries <- traceEntry{flushed: flushed}
+	if err := <-flushed; err != nil {
+		fmt.Println(msg("trace_failed"), err)
+	}
+	if n := t.dropped.Swap(0); n > 0 {
+		fmt.Printf(msg("trace_dropped"), n)
+	}
+}
+
+// setupTrace 在设置了 -trace-file 时打开文件
+func setupTrace() error {
+	if *traceFile == "" {
+		return nil
+	}
+	t, err := openTrace(*traceFile)
+	if err != nil {
+		return err
+	}
+	tracer = t
+	fmt.Printf(msg("trace_enabled"), *traceFile)
+	return nil
+}
+
+// newTraceRecord 根据一个请求的结果生成记录
+func newTraceRecord(backend Backend, model string, concurrency int, start time.Time, took time.Duration, picked []int, outcome requestOutcome, err error) traceRecord {
+	r := traceRecord{
+		Backend:     backend.Name(),
+		Model:       model,
+		Concurrency: concurrency,
+		Worker:      outcome.worker,
+		Start:       start,
+		LatencyMs:   float64(took.Microseconds()) / 1000,
+		Status:      outcome.status,
+		Prompts:     picked,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
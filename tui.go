@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"model-test/internal/monitor"
+)
+
+var tuiMode = flag.Bool("tui", false, "以终端仪表盘显示进度、已完成结果和实时资源占用 (非终端时自动使用普通输出)")
+
+// dash 为当前的终端仪表盘, 未开启 -tui 时为 nil
+var dash *dashboard
+
+// dashboard 用 ANSI 转义序列每秒重绘整个屏幕
+type dashboard struct {
+	mu        sync.Mutex
+	totalVRAM float64
+	cells     int // 矩阵中的总组数
+	completed []TestResult
+	current   string
+	cellStart time.Time
+	cellDur   time.Duration
+	metrics   monitor.Metrics
+	requests  int
+	successes int
+	eta       string // 整体预计剩余时间, 第一组完成前为空
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// isTerminal 判断标准输出是否连接到终端
+func isTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func newDashboard(cells int, totalVRAM float64) *dashboard {
+	d := &dashboard{
+		cells:     cells,
+		totalVRAM: totalVRAM,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	// 切换到备用屏幕并隐藏光标
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	go d.loop()
+	return d
+}
+
+func (d *dashboard) loop() {
+	defer close(d.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		d.render()
+		select {
+		case <-ticker.C:
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// Close 停止重绘并恢复终端; 可以重复调用, 宽限期结束强制退出时可能与正常结束同时关闭
+func (d *dashboard) Close() {
+	d.closeOnce.Do(func() {
+		close(d.stop)
+		<-d.done
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+	})
+}
+
+func (d *dashboard) startCell(backend, model string, concurrency int, duration time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.current = fmt.Sprintf("[%s] %s ×%d", backend, model, concurrency)
+	d.cellStart = time.Now()
+	d.cellDur = duration
+	d.requests, d.successes = 0, 0
+}
+
+func (d *dashboard) update(metric monitor.Metrics, requests, successes int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.metrics = metric
+	d.requests, d.successes = requests, successes
+}
+
+func (d *dashboard) finishCell(result TestResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.completed = append(d.completed, result)
+	d.current = ""
+}
+
+func (d *dashboard) setETA(eta string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.eta = eta
+}
+
+func (d *dashboard) render() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&b, msg("tui_title"), len(d.completed), d.cells)
+	if d.eta != "" {
+		b.WriteString(d.eta)
+	}
+
+	if d.current != "" {
+		progress := 0.0
+		if d.cellDur > 0 {
+			progress = min(time.Since(d.cellStart).Seconds()/d.cellDur.Seconds(), 1)
+		}
+		fmt.Fprintf(&b, msg("tui_current"), d.current, d.requests, d.successes)
+		fmt.Fprintf(&b, "  %s %5.1f%%\n\n", bar(progress), progress*100)
+	}
+
+	vramRatio := 0.0
+	if d.totalVRAM > 0 {
+		vramRatio = d.metrics.GPUMemoryUsed / d.totalVRAM
+	}
+	fmt.Fprintf(&b, "  CPU  %s %5.1f%%\n", bar(d.metrics.CPULoad/100), d.metrics.CPULoad)
+	fmt.Fprintf(&b, "  MEM  %s %5.1f%%\n", bar(d.metrics.MemoryUsed/100), d.metrics.MemoryUsed)
+	fmt.Fprintf(&b, "  GPU  %s %5.1f%%\n", bar(d.metrics.GPULoad/100), d.metrics.GPULoad)
+	fmt.Fprintf(&b, "  VRAM %s %6.0fMB\n\n", bar(vramRatio), d.metrics.GPUMemoryUsed)
+
+	fprintResults(&b, d.completed, terminalWidth())
+	fmt.Print(b.String())
+}
+
+// bar 将 0~1 的比例渲染为固定宽度的进度条
+func bar(ratio float64) string {
+	const width = 30
+	ratio = max(0, min(ratio, 1))
+	filled := int(ratio * width)
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
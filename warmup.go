@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	warmupUntilStable = flag.Bool("warmup-until-stable", false, "每组测试前持续发送预热请求, 直到相邻两个窗口的平均响应相差不超过 -warmup-tolerance 或预热达到 -max-warmup, 预热请求不计入统计")
+	warmupWindow      = flag.Int("warmup-window", 10, "-warmup-until-stable 每个窗口包含的成功请求数")
+	warmupTolerance   = flag.Float64("warmup-tolerance", 10, "-warmup-until-stable 相邻两个窗口平均响应的最大差异(%)")
+	maxWarmup         = flag.Duration("max-warmup", 2*time.Minute, "-warmup-until-stable 每组最长的预热时间")
+)
+
+// windowsStable 判断最近两个窗口的平均响应相差是否在 tolerance% 以内
+func windowsStable(times []time.Duration, window int, tolerance float64) bool {
+	if len(times) < 2*window {
+		return false
+	}
+	prev, _, _ := calculateStats(times[len(times)-2*window : len(times)-window])
+	last, _, _ := calculateStats(times[len(times)-window:])
+	if prev == 0 {
+		return false
+	}
+	return math.Abs(last-prev)/prev*100 <= tolerance
+}
+
+// warmUp 以 concurrency 个并发发送预热请求, 直到延迟稳定、达到 -max-warmup 或出现失败请求,
+// 返回预热耗时和延迟是否已稳定; 出现失败时不再预热, 由正式测试统计错误
+func warmUp(client *http.Client, backend Backend, model string, concurrency int) (time.Duration, bool) {
+	ctx, cancel := context.WithTimeout(shutdownCtx, *maxWarmup)
+	defer cancel()
+	start := time.Now()
+
+	var (
+		mu     sync.Mutex
+		times  []time.Duration
+		stable bool
+		wg     sync.WaitGroup
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				batch := make([]string, *batchSize)
+				for j := range batch {
+					batch[j] = withNonce(prompts[rand.Intn(len(prompts))])
+				}
+				outcome, err := sendRequest(ctx, i, client, backend, model, batch)
+
+				mu.Lock()
+				if err == nil {
+					times = append(times, outcome.elapsed)
+					stable = stable || windowsStable(times, *warmupWindow, *warmupTolerance)
+				}
+				if err != nil || stable {
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return time.Since(start), stable
+}
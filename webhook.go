@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var webhookURL = flag.String("webhook", "", "每完成一组测试将该组的结果 (TestResult) 以 JSON POST 到该地址, 全部结束后再 POST 一次与 -output json 相同结构的汇总; 发送失败只输出警告, 不影响测试")
+
+// webhookTimeout 为每次 POST 的超时时间, 避免无响应的接收方拖慢测试
+const webhookTimeout = 10 * time.Second
+
+// validateWebhook 检查 -webhook 是否为 http(s) 地址
+func validateWebhook() error {
+	if *webhookURL == "" {
+		return nil
+	}
+	u, err := url.Parse(*webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf(msg("bad_webhook"), *webhookURL)
+	}
+	return nil
+}
+
+// notifyCell 在一组测试完成后推送该组结果, 逐请求数据不推送
+func notifyCell(result TestResult) {
+	if *webhookURL == "" {
+		return
+	}
+	if err := postWebhook("cell", withoutSeries([]TestResult{result})[0]); err != nil {
+		fmt.Println(msg("webhook_failed"), err)
+	}
+}
+
+// notifySummary 在所有测试结束后推送汇总
+func notifySummary(doc resultsDocument) {
+	if *webhookURL == "" {
+		return
+	}
+	if err := postWebhook("summary", doc); err != nil {
+		fmt.Println(msg("webhook_failed"), err)
+	}
+}
+
+// postWebhook 以 JSON POST v, X-Model-Test-Event 头为 cell 或 summary, 便于接收方区分
+func postWebhook(event string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, *webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Model-Test-Event", event)
+
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf(msg("status_error_msg"), resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}